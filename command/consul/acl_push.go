@@ -0,0 +1,427 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// ACLPush ...
+func ACLPush(c *cli.Context) error {
+	config, err := config.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	return ACLPushWithConfig(c, config)
+}
+
+// ACLPushWithConfig ...
+func ACLPushWithConfig(c *cli.Context, config *config.Config) error {
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	return PushACL(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// PushACL will create or update Consul ACL policies, roles, tokens and binding rules,
+// matching existing objects by name (or description for tokens and binding rules)
+func PushACL(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
+	if len(config.ConsulACLPolicies) == 0 && len(config.ConsulACLRoles) == 0 && len(config.ConsulACLTokens) == 0 && len(config.ConsulACLBindingRules) == 0 {
+		return nil
+	}
+
+	acl := client.ACL()
+	failures := opts.failures()
+
+	if err := failures.Add(pushACLPolicies(ctx, acl, config.ConsulACLPolicies, opts)); err != nil {
+		return err
+	}
+
+	if err := failures.Add(pushACLRoles(ctx, acl, config.ConsulACLRoles, opts)); err != nil {
+		return err
+	}
+
+	if err := failures.Add(pushACLTokens(ctx, acl, config.ConsulACLTokens, opts)); err != nil {
+		return err
+	}
+
+	if err := failures.Add(pushACLBindingRules(ctx, acl, config.ConsulACLBindingRules, opts)); err != nil {
+		return err
+	}
+
+	return failures.Err()
+}
+
+func pushACLPolicies(ctx context.Context, acl *api.ACL, policies config.ConsulACLPolicies, opts PushOptions) error {
+	exec := opts.executor()
+
+	for _, policy := range policies {
+		policy := policy
+		exec.Add("consul_acl_policy", policy.Name, func() error {
+			return pushACLPolicy(ctx, acl, policy, opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
+
+func pushACLPolicy(ctx context.Context, acl *api.ACL, policy *config.ConsulACLPolicy, opts PushOptions) error {
+	desired := &api.ACLPolicy{
+		Name:        policy.Name,
+		Description: policy.Description,
+		Datacenters: policy.Datacenters,
+		Rules:       policy.Rules,
+	}
+
+	r := report.Start("consul_acl_policy", policy.Name)
+
+	existing, _, err := acl.PolicyReadByName(policy.Name, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return r.Fail(fmt.Errorf("Could not read consul ACL policy %s: %s", policy.Name, err))
+	}
+
+	if existing == nil {
+		log.Infof("Creating consul ACL policy %s", policy.Name)
+		if err := opts.Snapshot.CaptureConsulACLPolicy(policy.Name, nil); err != nil {
+			return r.Fail(err)
+		}
+
+		if _, _, err := acl.PolicyCreate(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+			return r.Fail(fmt.Errorf("Could not create consul ACL policy %s: %s", policy.Name, err))
+		}
+		r.Done(report.ActionCreated)
+		return nil
+	}
+
+	if existing.Description == desired.Description &&
+		strings.TrimSpace(existing.Rules) == strings.TrimSpace(desired.Rules) &&
+		equalStrings(existing.Datacenters, desired.Datacenters) {
+		log.Debugf("Consul ACL policy %s is up to date", policy.Name)
+		r.Done(report.ActionUnchanged)
+		return nil
+	}
+
+	log.Infof("Updating consul ACL policy %s", policy.Name)
+	if err := opts.Snapshot.CaptureConsulACLPolicy(policy.Name, existing); err != nil {
+		return r.Fail(err)
+	}
+
+	desired.ID = existing.ID
+	if _, _, err := acl.PolicyUpdate(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return r.Fail(fmt.Errorf("Could not update consul ACL policy %s: %s", policy.Name, err))
+	}
+	r.Done(report.ActionUpdated)
+	return nil
+}
+
+func pushACLRoles(ctx context.Context, acl *api.ACL, roles config.ConsulACLRoles, opts PushOptions) error {
+	exec := opts.executor()
+
+	for _, role := range roles {
+		role := role
+		exec.Add("consul_acl_role", role.Name, func() error {
+			return pushACLRole(ctx, acl, role, opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
+
+func pushACLRole(ctx context.Context, acl *api.ACL, role *config.ConsulACLRole, opts PushOptions) error {
+	desired := &api.ACLRole{
+		Name:              role.Name,
+		Description:       role.Description,
+		Policies:          make([]*api.ACLRolePolicyLink, 0),
+		ServiceIdentities: serviceIdentities(role.ServiceIdentities),
+	}
+
+	for _, name := range role.Policies {
+		desired.Policies = append(desired.Policies, &api.ACLRolePolicyLink{Name: name})
+	}
+
+	r := report.Start("consul_acl_role", role.Name)
+
+	existing, _, err := acl.RoleReadByName(role.Name, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return r.Fail(fmt.Errorf("Could not read consul ACL role %s: %s", role.Name, err))
+	}
+
+	if existing == nil {
+		log.Infof("Creating consul ACL role %s", role.Name)
+		if err := opts.Snapshot.CaptureConsulACLRole(role.Name, nil); err != nil {
+			return r.Fail(err)
+		}
+
+		if _, _, err := acl.RoleCreate(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+			return r.Fail(fmt.Errorf("Could not create consul ACL role %s: %s", role.Name, err))
+		}
+		r.Done(report.ActionCreated)
+		return nil
+	}
+
+	existingPolicies := make([]string, 0, len(existing.Policies))
+	for _, link := range existing.Policies {
+		existingPolicies = append(existingPolicies, link.Name)
+	}
+
+	if existing.Description == desired.Description &&
+		equalStrings(existingPolicies, role.Policies) &&
+		equalStrings(serviceIdentityNames(existing.ServiceIdentities), role.ServiceIdentities) {
+		log.Debugf("Consul ACL role %s is up to date", role.Name)
+		r.Done(report.ActionUnchanged)
+		return nil
+	}
+
+	log.Infof("Updating consul ACL role %s", role.Name)
+	if err := opts.Snapshot.CaptureConsulACLRole(role.Name, existing); err != nil {
+		return r.Fail(err)
+	}
+
+	desired.ID = existing.ID
+	if _, _, err := acl.RoleUpdate(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return r.Fail(fmt.Errorf("Could not update consul ACL role %s: %s", role.Name, err))
+	}
+	r.Done(report.ActionUpdated)
+	return nil
+}
+
+func pushACLTokens(ctx context.Context, acl *api.ACL, tokens config.ConsulACLTokens, opts PushOptions) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	list, _, err := acl.TokenList((&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("Could not list consul ACL tokens: %s", err)
+	}
+
+	existingTokens := make(map[string]*api.ACLTokenListEntry, len(list))
+	for _, entry := range list {
+		existingTokens[entry.Description] = entry
+	}
+
+	exec := opts.executor()
+
+	for _, token := range tokens {
+		token := token
+		exec.Add("consul_acl_token", token.Name, func() error {
+			return pushACLToken(ctx, acl, token, existingTokens[token.Name], opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
+
+// pushACLToken creates token if existing is nil, or updates existing
+func pushACLToken(ctx context.Context, acl *api.ACL, token *config.ConsulACLToken, existing *api.ACLTokenListEntry, opts PushOptions) error {
+	desired := &api.ACLToken{
+		Description:       token.Name,
+		Local:             token.Local,
+		Policies:          make([]*api.ACLTokenPolicyLink, 0),
+		Roles:             make([]*api.ACLTokenRoleLink, 0),
+		ServiceIdentities: serviceIdentities(token.ServiceIdentities),
+	}
+
+	for _, name := range token.Policies {
+		desired.Policies = append(desired.Policies, &api.ACLTokenPolicyLink{Name: name})
+	}
+
+	for _, name := range token.Roles {
+		desired.Roles = append(desired.Roles, &api.ACLTokenRoleLink{Name: name})
+	}
+
+	r := report.Start("consul_acl_token", token.Name)
+
+	if existing == nil {
+		log.Infof("Creating consul ACL token %s", token.Name)
+		if err := opts.Snapshot.CaptureConsulACLToken(token.Name, nil); err != nil {
+			return r.Fail(err)
+		}
+
+		// a token gets a new accessor on every create, so a retry could leave an unmanaged duplicate
+		created, _, err := acl.TokenCreate(desired, (&api.WriteOptions{}).WithContext(support.WithoutRetries(ctx)))
+		if err != nil {
+			return r.Fail(fmt.Errorf("Could not create consul ACL token %s: %s", token.Name, err))
+		}
+
+		log.Infof("  Created token with accessor %s", created.AccessorID)
+		r.Done(report.ActionCreated)
+		return outputTokenSecret(token.Name, created.SecretID, opts.KeybaseRecipients)
+	}
+
+	if existing.Local != token.Local {
+		return r.Fail(fmt.Errorf("Can't change 'local' on existing consul ACL token %s, delete it first", token.Name))
+	}
+
+	existingPolicies := make([]string, 0, len(existing.Policies))
+	for _, link := range existing.Policies {
+		existingPolicies = append(existingPolicies, link.Name)
+	}
+
+	existingRoles := make([]string, 0, len(existing.Roles))
+	for _, link := range existing.Roles {
+		existingRoles = append(existingRoles, link.Name)
+	}
+
+	if equalStrings(existingPolicies, token.Policies) &&
+		equalStrings(existingRoles, token.Roles) &&
+		equalStrings(serviceIdentityNames(existing.ServiceIdentities), token.ServiceIdentities) {
+		log.Debugf("Consul ACL token %s is up to date", token.Name)
+		r.Done(report.ActionUnchanged)
+		return nil
+	}
+
+	log.Infof("Updating consul ACL token %s", token.Name)
+	if err := opts.Snapshot.CaptureConsulACLToken(token.Name, existing); err != nil {
+		return r.Fail(err)
+	}
+
+	desired.AccessorID = existing.AccessorID
+	if _, _, err := acl.TokenUpdate(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return r.Fail(fmt.Errorf("Could not update consul ACL token %s: %s", token.Name, err))
+	}
+	r.Done(report.ActionUpdated)
+	return nil
+}
+
+func pushACLBindingRules(ctx context.Context, acl *api.ACL, rules config.ConsulACLBindingRules, opts PushOptions) error {
+	// binding rules can only be listed per auth method
+	existingRules := make(map[string]map[string]*api.ACLBindingRule)
+
+	exec := opts.executor()
+
+	for _, rule := range rules {
+		if _, ok := existingRules[rule.AuthMethod]; !ok {
+			list, _, err := acl.BindingRuleList(rule.AuthMethod, (&api.QueryOptions{}).WithContext(ctx))
+			if err != nil {
+				return fmt.Errorf("Could not list consul ACL binding rules for %s: %s", rule.AuthMethod, err)
+			}
+
+			existingRules[rule.AuthMethod] = make(map[string]*api.ACLBindingRule, len(list))
+			for _, existing := range list {
+				existingRules[rule.AuthMethod][existing.Description] = existing
+			}
+		}
+
+		rule, existing := rule, existingRules[rule.AuthMethod][rule.Name]
+		exec.Add("consul_acl_binding_rule", rule.Name, func() error {
+			return pushACLBindingRule(ctx, acl, rule, existing, opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
+
+// pushACLBindingRule creates rule if existing is nil, or updates existing
+func pushACLBindingRule(ctx context.Context, acl *api.ACL, rule *config.ConsulACLBindingRule, existing *api.ACLBindingRule, opts PushOptions) error {
+	desired := &api.ACLBindingRule{
+		Description: rule.Name,
+		AuthMethod:  rule.AuthMethod,
+		Selector:    rule.Selector,
+		BindType:    api.BindingRuleBindType(rule.BindType),
+		BindName:    rule.BindName,
+	}
+
+	r := report.Start("consul_acl_binding_rule", rule.Name)
+
+	if existing == nil {
+		log.Infof("Creating consul ACL binding rule %s", rule.Name)
+		if err := opts.Snapshot.CaptureConsulACLBindingRule(rule.Name, rule.AuthMethod, nil); err != nil {
+			return r.Fail(err)
+		}
+
+		// like tokens, binding rules get a new ID on every create
+		if _, _, err := acl.BindingRuleCreate(desired, (&api.WriteOptions{}).WithContext(support.WithoutRetries(ctx))); err != nil {
+			return r.Fail(fmt.Errorf("Could not create consul ACL binding rule %s: %s", rule.Name, err))
+		}
+		r.Done(report.ActionCreated)
+		return nil
+	}
+
+	if existing.Selector == desired.Selector && existing.BindType == desired.BindType && existing.BindName == desired.BindName {
+		log.Debugf("Consul ACL binding rule %s is up to date", rule.Name)
+		r.Done(report.ActionUnchanged)
+		return nil
+	}
+
+	log.Infof("Updating consul ACL binding rule %s", rule.Name)
+	if err := opts.Snapshot.CaptureConsulACLBindingRule(rule.Name, rule.AuthMethod, existing); err != nil {
+		return r.Fail(err)
+	}
+
+	desired.ID = existing.ID
+	if _, _, err := acl.BindingRuleUpdate(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return r.Fail(fmt.Errorf("Could not update consul ACL binding rule %s: %s", rule.Name, err))
+	}
+	r.Done(report.ActionUpdated)
+	return nil
+}
+
+// outputTokenSecret prints the secret of a newly created token encrypted for the keybase recipients,
+// the secret is never written to the log in plaintext
+func outputTokenSecret(name, secret string, recipients []string) error {
+	if len(recipients) == 0 {
+		log.Warnf("  No --keybase recipients provided, the secret of token %s is not shown. Read it with 'consul acl token read' instead", name)
+		return nil
+	}
+
+	message, err := support.KeybaseEncrypt(recipients, secret)
+	if err != nil {
+		return err
+	}
+
+	// stdout carries the --output json records, so the message is written to the log output
+	log.Infof("  Send the following message to %s:", strings.Join(recipients, ","))
+	fmt.Fprintf(log.StandardLogger().Out, "\n%s\n", message)
+
+	return nil
+}
+
+func serviceIdentities(names []string) []*api.ACLServiceIdentity {
+	result := make([]*api.ACLServiceIdentity, 0, len(names))
+	for _, name := range names {
+		result = append(result, &api.ACLServiceIdentity{ServiceName: name})
+	}
+
+	return result
+}
+
+func serviceIdentityNames(identities []*api.ACLServiceIdentity) []string {
+	result := make([]string, 0, len(identities))
+	for _, identity := range identities {
+		result = append(result, identity.ServiceName)
+	}
+
+	return result
+}
+
+// equalStrings compares two lists of strings, ignoring order
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	x := append([]string{}, a...)
+	y := append([]string{}, b...)
+	sort.Strings(x)
+	sort.Strings(y)
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
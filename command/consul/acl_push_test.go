@@ -0,0 +1,442 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/command/snapshot"
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEqualStrings(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        []string
+		b        []string
+		expected bool
+	}{
+		{
+			name:     "both empty",
+			expected: true,
+		},
+		{
+			name:     "nil and empty",
+			a:        nil,
+			b:        []string{},
+			expected: true,
+		},
+		{
+			name:     "same order",
+			a:        []string{"a", "b"},
+			b:        []string{"a", "b"},
+			expected: true,
+		},
+		{
+			name:     "different order",
+			a:        []string{"b", "a"},
+			b:        []string{"a", "b"},
+			expected: true,
+		},
+		{
+			name: "different length",
+			a:    []string{"a"},
+			b:    []string{"a", "b"},
+		},
+		{
+			name: "different values",
+			a:    []string{"a", "c"},
+			b:    []string{"a", "b"},
+		},
+		{
+			name: "duplicates count",
+			a:    []string{"a", "a"},
+			b:    []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, equalStrings(tt.a, tt.b))
+			require.Equal(t, tt.expected, equalStrings(tt.b, tt.a))
+		})
+	}
+}
+
+func TestServiceIdentities(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+	}{
+		{
+			name:  "none",
+			names: []string{},
+		},
+		{
+			name:  "several",
+			names: []string{"api", "web"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identities := serviceIdentities(tt.names)
+			require.Len(t, identities, len(tt.names))
+			require.Equal(t, tt.names, serviceIdentityNames(identities))
+		})
+	}
+}
+
+// fakeConsulACL serves the GET paths in reads, answers 404 for other reads, and records every write
+func fakeConsulACL(t *testing.T, reads map[string]interface{}) (*api.ACL, *[]string) {
+	writes := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			body, ok := reads[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			require.NoError(t, json.NewEncoder(w).Encode(body))
+			return
+		}
+
+		writes = append(writes, r.Method+" "+r.URL.Path)
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+
+	return client.ACL(), &writes
+}
+
+// requireCaptured checks the recorder captured the resource once before it was written, and nothing if it was unchanged
+func requireCaptured(t *testing.T, rec *snapshot.Recorder, writes []string, existed bool) {
+	entries := rec.Entries()
+	if len(writes) == 0 {
+		require.Empty(t, entries)
+		return
+	}
+
+	require.Len(t, entries, 1)
+	require.Equal(t, existed, entries[0].Existed)
+}
+
+func TestPushACLPolicy(t *testing.T) {
+	policy := &config.ConsulACLPolicy{
+		Name:        "api",
+		Description: "api service",
+		Datacenters: []string{"dc1", "dc2"},
+		Rules:       `service "api" { policy = "write" }`,
+	}
+
+	tests := []struct {
+		name     string
+		existing *api.ACLPolicy
+		expected []string
+	}{
+		{
+			name:     "missing policy is created",
+			expected: []string{"PUT /v1/acl/policy"},
+		},
+		{
+			name: "equal policy is unchanged",
+			existing: &api.ACLPolicy{
+				ID:          "policy-id",
+				Name:        "api",
+				Description: "api service",
+				Datacenters: []string{"dc2", "dc1"},
+				Rules:       "service \"api\" { policy = \"write\" }\n",
+			},
+			expected: []string{},
+		},
+		{
+			name: "different rules are updated",
+			existing: &api.ACLPolicy{
+				ID:          "policy-id",
+				Name:        "api",
+				Description: "api service",
+				Datacenters: []string{"dc1", "dc2"},
+				Rules:       `service "api" { policy = "read" }`,
+			},
+			expected: []string{"PUT /v1/acl/policy/policy-id"},
+		},
+		{
+			name: "different datacenters are updated",
+			existing: &api.ACLPolicy{
+				ID:          "policy-id",
+				Name:        "api",
+				Description: "api service",
+				Datacenters: []string{"dc1"},
+				Rules:       `service "api" { policy = "write" }`,
+			},
+			expected: []string{"PUT /v1/acl/policy/policy-id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := map[string]interface{}{}
+			if tt.existing != nil {
+				reads["/v1/acl/policy/name/api"] = tt.existing
+			}
+
+			acl, writes := fakeConsulACL(t, reads)
+			rec := snapshot.NewRecorder()
+
+			require.NoError(t, pushACLPolicy(context.Background(), acl, policy, PushOptions{Snapshot: rec}))
+			require.Equal(t, tt.expected, *writes)
+			requireCaptured(t, rec, *writes, tt.existing != nil)
+		})
+	}
+}
+
+func TestPushACLRole(t *testing.T) {
+	role := &config.ConsulACLRole{
+		Name:              "api",
+		Description:       "api service",
+		Policies:          []string{"api", "base"},
+		ServiceIdentities: []string{"api"},
+	}
+
+	tests := []struct {
+		name     string
+		existing *api.ACLRole
+		expected []string
+	}{
+		{
+			name:     "missing role is created",
+			expected: []string{"PUT /v1/acl/role"},
+		},
+		{
+			name: "equal role is unchanged",
+			existing: &api.ACLRole{
+				ID:                "role-id",
+				Name:              "api",
+				Description:       "api service",
+				Policies:          []*api.ACLRolePolicyLink{{Name: "base"}, {Name: "api"}},
+				ServiceIdentities: []*api.ACLServiceIdentity{{ServiceName: "api"}},
+			},
+			expected: []string{},
+		},
+		{
+			name: "different policies are updated",
+			existing: &api.ACLRole{
+				ID:                "role-id",
+				Name:              "api",
+				Description:       "api service",
+				Policies:          []*api.ACLRolePolicyLink{{Name: "api"}},
+				ServiceIdentities: []*api.ACLServiceIdentity{{ServiceName: "api"}},
+			},
+			expected: []string{"PUT /v1/acl/role/role-id"},
+		},
+		{
+			name: "different service identities are updated",
+			existing: &api.ACLRole{
+				ID:          "role-id",
+				Name:        "api",
+				Description: "api service",
+				Policies:    []*api.ACLRolePolicyLink{{Name: "api"}, {Name: "base"}},
+			},
+			expected: []string{"PUT /v1/acl/role/role-id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := map[string]interface{}{}
+			if tt.existing != nil {
+				reads["/v1/acl/role/name/api"] = tt.existing
+			}
+
+			acl, writes := fakeConsulACL(t, reads)
+			rec := snapshot.NewRecorder()
+
+			require.NoError(t, pushACLRole(context.Background(), acl, role, PushOptions{Snapshot: rec}))
+			require.Equal(t, tt.expected, *writes)
+			requireCaptured(t, rec, *writes, tt.existing != nil)
+		})
+	}
+}
+
+func TestPushACLToken(t *testing.T) {
+	token := &config.ConsulACLToken{
+		Name:              "api",
+		Policies:          []string{"api"},
+		Roles:             []string{"base"},
+		ServiceIdentities: []string{"api"},
+	}
+
+	tests := []struct {
+		name      string
+		existing  *api.ACLTokenListEntry
+		atomic    bool
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "missing token is created",
+			expected: []string{"PUT /v1/acl/token"},
+		},
+		{
+			name:      "missing token isn't created when the atomic snapshot can't be captured",
+			atomic:    true,
+			expected:  []string{},
+			expectErr: true,
+		},
+		{
+			name: "equal token is unchanged",
+			existing: &api.ACLTokenListEntry{
+				AccessorID:        "accessor",
+				Description:       "api",
+				Policies:          []*api.ACLTokenPolicyLink{{Name: "api"}},
+				Roles:             []*api.ACLTokenRoleLink{{Name: "base"}},
+				ServiceIdentities: []*api.ACLServiceIdentity{{ServiceName: "api"}},
+			},
+			expected: []string{},
+		},
+		{
+			name: "different roles are updated",
+			existing: &api.ACLTokenListEntry{
+				AccessorID:        "accessor",
+				Description:       "api",
+				Policies:          []*api.ACLTokenPolicyLink{{Name: "api"}},
+				ServiceIdentities: []*api.ACLServiceIdentity{{ServiceName: "api"}},
+			},
+			expected: []string{"PUT /v1/acl/token/accessor"},
+		},
+		{
+			name: "local can't be changed",
+			existing: &api.ACLTokenListEntry{
+				AccessorID:  "accessor",
+				Description: "api",
+				Local:       true,
+			},
+			expected:  []string{},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl, writes := fakeConsulACL(t, nil)
+
+			// an atomic push with a snapshot dir but no recipient can't capture anything
+			rec := snapshot.NewRecorder()
+			if tt.atomic {
+				rec.Atomic = true
+				rec.Dir = t.TempDir()
+			}
+
+			err := pushACLToken(context.Background(), acl, token, tt.existing, PushOptions{Snapshot: rec})
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.expected, *writes)
+			requireCaptured(t, rec, *writes, tt.existing != nil)
+		})
+	}
+}
+
+func TestPushACLBindingRule(t *testing.T) {
+	rule := &config.ConsulACLBindingRule{
+		Name:       "api",
+		AuthMethod: "kubernetes",
+		Selector:   "serviceaccount.name==api",
+		BindType:   "service",
+		BindName:   "api",
+	}
+
+	tests := []struct {
+		name      string
+		existing  *api.ACLBindingRule
+		atomic    bool
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "missing binding rule is created",
+			expected: []string{"PUT /v1/acl/binding-rule"},
+		},
+		{
+			name:      "missing binding rule isn't created when the atomic snapshot can't be captured",
+			atomic:    true,
+			expected:  []string{},
+			expectErr: true,
+		},
+		{
+			name: "equal binding rule is unchanged",
+			existing: &api.ACLBindingRule{
+				ID:          "rule-id",
+				Description: "api",
+				AuthMethod:  "kubernetes",
+				Selector:    "serviceaccount.name==api",
+				BindType:    api.BindingRuleBindTypeService,
+				BindName:    "api",
+			},
+			expected: []string{},
+		},
+		{
+			name: "different selector is updated",
+			existing: &api.ACLBindingRule{
+				ID:          "rule-id",
+				Description: "api",
+				AuthMethod:  "kubernetes",
+				Selector:    "serviceaccount.name==web",
+				BindType:    api.BindingRuleBindTypeService,
+				BindName:    "api",
+			},
+			expected: []string{"PUT /v1/acl/binding-rule/rule-id"},
+		},
+		{
+			name: "different bind type is updated",
+			existing: &api.ACLBindingRule{
+				ID:          "rule-id",
+				Description: "api",
+				AuthMethod:  "kubernetes",
+				Selector:    "serviceaccount.name==api",
+				BindType:    api.BindingRuleBindTypeRole,
+				BindName:    "api",
+			},
+			expected: []string{"PUT /v1/acl/binding-rule/rule-id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl, writes := fakeConsulACL(t, nil)
+
+			rec := snapshot.NewRecorder()
+			if tt.atomic {
+				rec.Atomic = true
+				rec.Dir = t.TempDir()
+			}
+
+			err := pushACLBindingRule(context.Background(), acl, rule, tt.existing, PushOptions{Snapshot: rec})
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, tt.expected, *writes)
+			requireCaptured(t, rec, *writes, tt.existing != nil)
+
+			// rolling back a created rule lists the rules of its auth method to find it by description
+			if len(*writes) > 0 && tt.existing == nil {
+				require.Equal(t, "kubernetes", rec.Entries()[0].ConsulACLBindingRule.AuthMethod)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/command/executor"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// ConfigEntriesPush ...
+func ConfigEntriesPush(c *cli.Context) error {
+	config, err := config.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	return ConfigEntriesPushWithConfig(c, config)
+}
+
+// ConfigEntriesPushWithConfig ...
+func ConfigEntriesPushWithConfig(c *cli.Context, config *config.Config) error {
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	return PushConfigEntries(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// PushConfigEntries will write all config entries and intentions to Consul
+func PushConfigEntries(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
+	if len(config.ConsulConfigEntries) == 0 && len(config.ConsulIntentions) == 0 {
+		return nil
+	}
+
+	exec := opts.executor()
+
+	// entries wait for all entries of the kinds sorted before them, e.g. routers for the defaults
+	var previousKinds, currentKind []*executor.Task
+	kind := ""
+
+	for _, entry := range config.ConsulConfigEntries.Sorted() {
+		if entry.Kind != kind {
+			previousKinds = append(previousKinds, currentKind...)
+			currentKind = nil
+			kind = entry.Kind
+		}
+
+		entry := entry
+		currentKind = append(currentKind, exec.Add("consul_config_entry", entry.Kind+"/"+entry.Name, func() error {
+			return pushConfigEntry(ctx, client, entry, opts)
+		}, previousKinds...))
+	}
+
+	failures := opts.failures()
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	connect := client.Connect()
+	exec = opts.executor()
+
+	for _, intention := range config.ConsulIntentions {
+		intention := intention
+		exec.Add("consul_intention", intention.Source+" => "+intention.Destination, func() error {
+			return pushIntention(ctx, connect, intention, opts)
+		})
+	}
+
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	return failures.Err()
+}
+
+func pushConfigEntry(ctx context.Context, client *api.Client, entry *config.ConsulConfigEntry, opts PushOptions) error {
+	log.Infof("Saving consul config entry %s/%s", entry.Kind, entry.Name)
+	r := report.Start("consul_config_entry", entry.Kind+"/"+entry.Name)
+
+	if err := opts.Snapshot.CaptureConsulConfigEntry(client, entry.Kind, entry.Name); err != nil {
+		return r.Fail(err)
+	}
+
+	ok, meta, err := client.ConfigEntries().Set(entry.Entry, (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return r.Fail(fmt.Errorf("Could not write consul config entry %s/%s: %s", entry.Kind, entry.Name, err))
+	}
+
+	if !ok {
+		return r.Fail(fmt.Errorf("Consul did not accept config entry %s/%s", entry.Kind, entry.Name))
+	}
+
+	log.Infof("  Saved config entry in %s", meta.RequestTime.String())
+	r.Done(report.ActionUpdated)
+	return nil
+}
+
+func pushIntention(ctx context.Context, connect *api.Connect, intention *config.ConsulIntention, opts PushOptions) error {
+	r := report.Start("consul_intention", intention.Source+" => "+intention.Destination)
+
+	existing, _, err := connect.IntentionGetExact(intention.Source, intention.Destination, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return r.Fail(fmt.Errorf("Could not read consul intention %s => %s: %s", intention.Source, intention.Destination, err))
+	}
+
+	if existing != nil && string(existing.Action) == intention.Action && existing.Description == intention.Description && equalMeta(existing.Meta, intention.Meta) {
+		log.Debugf("Consul intention %s => %s is up to date", intention.Source, intention.Destination)
+		r.Done(report.ActionUnchanged)
+		return nil
+	}
+
+	log.Infof("Saving consul intention %s => %s (%s)", intention.Source, intention.Destination, intention.Action)
+	if err := opts.Snapshot.CaptureConsulIntention(intention.Source, intention.Destination, existing); err != nil {
+		return r.Fail(err)
+	}
+
+	meta, err := connect.IntentionUpsert(intention.ToConsulIntention(), (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return r.Fail(fmt.Errorf("Could not write consul intention %s => %s: %s", intention.Source, intention.Destination, err))
+	}
+
+	log.Infof("  Saved intention in %s", meta.RequestTime.String())
+
+	if existing == nil {
+		r.Done(report.ActionCreated)
+	} else {
+		r.Done(report.ActionUpdated)
+	}
+	return nil
+}
+
+// equalMeta compares two meta maps, treating nil and empty as equal
+func equalMeta(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
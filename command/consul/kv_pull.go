@@ -0,0 +1,151 @@
+package consul
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// rootApplication is the file name used for keys that don't belong to any application
+const rootApplication = "_root"
+
+// KVPull will write all Consul KV below --prefix to the first --config-dir
+func KVPull(c *cli.Context) error {
+	log.Info("Pulling Consul KV")
+
+	env, dir, err := pullDirectory(c)
+	if err != nil {
+		return err
+	}
+
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return err
+	}
+
+	application := c.GlobalString("application")
+	prefix := kvPullPrefix(application, c.String("prefix"))
+
+	pairs, _, err := client.KV().List(prefix, &api.QueryOptions{})
+	if err != nil {
+		return err
+	}
+
+	grouped := groupKVPairs(pairs, application, c.Bool("applications"))
+	if len(grouped) == 0 {
+		log.Warnf("No Consul KV found below prefix '%s'", prefix)
+		return nil
+	}
+
+	apps := make([]string, 0, len(grouped))
+	for app := range grouped {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+
+	for _, app := range apps {
+		w := support.NewHCLWriter()
+		w.Comment("Generated by hashi-helper consul-pull-kv")
+		w.Block("environment", env)
+		if app != rootApplication {
+			w.Block("application", app)
+		}
+
+		for _, pair := range grouped[app] {
+			w.Block("kv", pair.Key)
+			w.Attribute("value", string(pair.Value))
+			w.End()
+		}
+
+		if app != rootApplication {
+			w.End()
+		}
+		w.End()
+
+		file := filepath.Join(dir, "consul_kv", app+".hcl")
+		if err := w.WriteFile(file, c.Bool("overwrite")); err != nil {
+			return err
+		}
+
+		log.Infof("  Wrote file: %s", file)
+	}
+
+	return nil
+}
+
+// kvPullPrefix returns the Consul KV prefix to list, mirroring ConsulKV.toPath
+// where keys for an application are stored below '<application>/'
+func kvPullPrefix(application, prefix string) string {
+	prefix = strings.TrimPrefix(prefix, "/")
+
+	if application != "" {
+		return application + "/" + prefix
+	}
+
+	return prefix
+}
+
+// groupKVPairs groups pairs by the application they will be written for, with keys
+// relative to the application. Folders and binary values are skipped.
+//
+// With an application filter all keys belong to that application, with splitApplications
+// the first path segment is used as application name, otherwise keys are written as-is
+// outside of any application.
+func groupKVPairs(pairs api.KVPairs, application string, splitApplications bool) map[string][]*api.KVPair {
+	result := make(map[string][]*api.KVPair)
+
+	for _, pair := range pairs {
+		if strings.HasSuffix(pair.Key, "/") && len(pair.Value) == 0 {
+			continue
+		}
+
+		if !utf8.Valid(pair.Value) {
+			log.Warnf("  Skipping key %s, binary values are not supported", pair.Key)
+			continue
+		}
+
+		app := rootApplication
+		key := pair.Key
+
+		switch {
+		case application != "":
+			app = application
+			key = strings.TrimPrefix(key, application+"/")
+		case splitApplications && strings.Contains(key, "/"):
+			parts := strings.SplitN(key, "/", 2)
+			app, key = parts[0], parts[1]
+		}
+
+		result[app] = append(result[app], &api.KVPair{Key: key, Value: pair.Value})
+	}
+
+	for _, list := range result {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Key < list[j].Key
+		})
+	}
+
+	return result
+}
+
+// pullDirectory returns the environment and the directory pulled files should be written to
+func pullDirectory(c *cli.Context) (string, string, error) {
+	env := c.GlobalString("environment")
+	if env == "" {
+		return "", "", fmt.Errorf("Pulling require a 'environment' value (--environment or ENV[ENVIRONMENT])")
+	}
+
+	dirs := c.GlobalStringSlice("config-dir")
+	if len(dirs) == 0 {
+		return "", "", fmt.Errorf("Pulling require a '--config-dir' to write files into")
+	}
+
+	return env, filepath.Join(dirs[0], env), nil
+}
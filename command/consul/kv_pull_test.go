@@ -0,0 +1,66 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupKVPairs(t *testing.T) {
+	pairs := api.KVPairs{
+		{Key: "api/", Value: nil},
+		{Key: "api/url", Value: []byte("http://localhost")},
+		{Key: "api/db/host", Value: []byte("db")},
+		{Key: "web/port", Value: []byte("80")},
+		{Key: "global", Value: []byte("yes")},
+		{Key: "api/binary", Value: []byte{0xff, 0xfe}},
+	}
+
+	keys := func(result map[string][]*api.KVPair) map[string][]string {
+		out := make(map[string][]string)
+		for app, list := range result {
+			for _, pair := range list {
+				out[app] = append(out[app], pair.Key)
+			}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name        string
+		application string
+		split       bool
+		expected    map[string][]string
+	}{
+		{
+			name:     "as-is",
+			expected: map[string][]string{rootApplication: {"api/db/host", "api/url", "global", "web/port"}},
+		},
+		{
+			name:  "split applications",
+			split: true,
+			expected: map[string][]string{
+				rootApplication: {"global"},
+				"api":           {"db/host", "url"},
+				"web":           {"port"},
+			},
+		},
+		{
+			name:        "single application",
+			application: "api",
+			expected:    map[string][]string{"api": {"db/host", "url"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := pairs
+			if tt.application != "" {
+				input = api.KVPairs{pairs[1], pairs[2]}
+			}
+
+			require.Equal(t, tt.expected, keys(groupKVPairs(input, tt.application, tt.split)))
+		})
+	}
+}
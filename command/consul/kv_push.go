@@ -1,7 +1,11 @@
 package consul
 
 import (
+	"bytes"
+	"context"
+
 	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/command/report"
 	"github.com/seatgeek/hashi-helper/config"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
@@ -19,25 +23,65 @@ func KVPush(c *cli.Context) error {
 
 // KVPushWithConfig ...
 func KVPushWithConfig(c *cli.Context, config *config.Config) error {
-	client, err := api.NewClient(api.DefaultConfig())
+	client, err := newClient(c)
 	if err != nil {
 		return err
 	}
 
+	return PushKV(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// PushKV will write all kv{} to Consul
+func PushKV(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
 	kvService := client.KV()
+	exec := opts.executor()
 
 	for _, kv := range config.ConsulKVs {
-		log.Infof("Saving consul KV %s", kv.Key)
+		kv := kv
+		exec.Add("consul_kv", kv.Key, func() error {
+			return pushKV(ctx, client, kvService, kv, opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
+
+// pushKV writes kv, unless Consul already has the same value and flags. With Force it's always written
+func pushKV(ctx context.Context, client *api.Client, kvService *api.KV, kv *config.ConsulKV, opts PushOptions) error {
+	r := report.Start("consul_kv", kv.Key)
+	if kv.Application != nil {
+		r.App(kv.Application.Name)
+	}
 
-		consulKV := kv.ToConsulKV()
+	consulKV := kv.ToConsulKV()
 
-		meta, err := kvService.Put(consulKV, &api.WriteOptions{})
+	action := report.ActionUpdated
+	if !opts.Force {
+		existing, _, err := kvService.Get(consulKV.Key, (&api.QueryOptions{}).WithContext(ctx))
 		if err != nil {
-			return err
+			return r.Fail(err)
+		}
+
+		if existing == nil {
+			action = report.ActionCreated
+		} else if bytes.Equal(existing.Value, consulKV.Value) && existing.Flags == consulKV.Flags {
+			log.Debugf("Consul KV %s is up to date", kv.Key)
+			r.Done(report.ActionUnchanged)
+			return nil
 		}
+	}
 
-		log.Infof("  Saved KV in %s", meta.RequestTime.String())
+	log.Infof("Saving consul KV %s", kv.Key)
+	if err := opts.Snapshot.CaptureConsulKV(client, consulKV.Key); err != nil {
+		return r.Fail(err)
+	}
+
+	meta, err := kvService.Put(consulKV, (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return r.Fail(err)
 	}
 
+	log.Infof("  Saved KV in %s", meta.RequestTime.String())
+	r.Done(action)
 	return nil
 }
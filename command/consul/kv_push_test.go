@@ -0,0 +1,80 @@
+package consul
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/command/snapshot"
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushKV_snapshotWithoutRecipient(t *testing.T) {
+	tests := []struct {
+		name      string
+		atomic    bool
+		expectErr bool
+		written   bool
+	}{
+		{
+			name:    "push is written without a snapshot file",
+			written: true,
+		},
+		{
+			name:      "atomic push fails before writing",
+			atomic:    true,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written := map[string]string{}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet:
+					w.WriteHeader(http.StatusNotFound)
+				case http.MethodPut:
+					body, err := ioutil.ReadAll(r.Body)
+					require.NoError(t, err)
+					written[r.URL.Path] = string(body)
+					w.Write([]byte("true"))
+				}
+			}))
+			defer server.Close()
+
+			client, err := api.NewClient(&api.Config{Address: server.URL})
+			require.NoError(t, err)
+
+			// the default flags: a snapshot dir, but no recipient to encrypt the snapshot for
+			rec := snapshot.NewRecorder()
+			rec.Atomic = tt.atomic
+			rec.Dir = filepath.Join(t.TempDir(), "snapshots")
+
+			cfg := &config.Config{ConsulKVs: config.ConsulKVs{{Key: "api/url", Value: []byte("http://localhost")}}}
+
+			err = PushKV(context.Background(), cfg, client, PushOptions{Snapshot: rec})
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if tt.written {
+				require.Equal(t, map[string]string{"/v1/kv/api/url": "http://localhost"}, written)
+				require.Len(t, rec.Entries(), 1)
+			} else {
+				require.Empty(t, written)
+			}
+
+			require.NoError(t, rec.Finish(false))
+			require.NoDirExists(t, rec.Dir)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package consul
+
+import (
+	"os"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Plan ...
+func Plan(c *cli.Context) error {
+	config, err := config.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	changes, err := PlanWithConfig(c, config)
+	if err != nil {
+		return err
+	}
+
+	changes.Print(os.Stdout)
+
+	return plan.ExitCode(c, changes)
+}
+
+// PlanWithConfig will compare the remote Consul state with the local config
+// and return the changes a push would make
+func PlanWithConfig(c *cli.Context, config *config.Config) (plan.Changes, error) {
+	log.Info("Planning Consul changes")
+
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	changes := plan.Changes{}
+
+	services, err := planServices(client, config)
+	if err != nil {
+		return nil, err
+	}
+	changes.Append(services)
+
+	kvs, err := planKV(client, config)
+	if err != nil {
+		return nil, err
+	}
+	changes.Append(kvs)
+
+	return changes, nil
+}
+
+func planServices(client *api.Client, config *config.Config) (plan.Changes, error) {
+	log.Info("  Planning Consul services")
+
+	changes := plan.Changes{}
+	nodes := make(map[string]*api.CatalogNode)
+
+	for _, service := range config.ConsulServices {
+		name := service.Node + "/" + service.Service.ID
+
+		node, ok := nodes[service.Node]
+		if !ok {
+			var err error
+			node, _, err = client.Catalog().Node(service.Node, nil)
+			if err != nil {
+				return nil, err
+			}
+			nodes[service.Node] = node
+		}
+
+		desired := serviceData(service.Service)
+
+		var remote *api.AgentService
+		if node != nil {
+			remote = node.Services[service.Service.ID]
+		}
+
+		if remote == nil {
+			changes.Add(&plan.Change{Resource: "consul_service", Name: name, Action: plan.ActionCreate, Fields: plan.DiffData(nil, desired, false)})
+			continue
+		}
+
+		changes.Add(plan.NewChange("consul_service", name, plan.DiffDataStrict(serviceData(remote), desired, false)))
+	}
+
+	return changes, nil
+}
+
+func planKV(client *api.Client, config *config.Config) (plan.Changes, error) {
+	log.Info("  Planning Consul KV")
+
+	changes := plan.Changes{}
+
+	for _, kv := range config.ConsulKVs {
+		pair := kv.ToConsulKV()
+
+		remote, _, err := client.KV().Get(pair.Key, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		desired := map[string]interface{}{"value": string(pair.Value)}
+
+		if remote == nil {
+			changes.Add(&plan.Change{Resource: "consul_kv", Name: pair.Key, Action: plan.ActionCreate, Fields: plan.DiffData(nil, desired, false)})
+			continue
+		}
+
+		changes.Add(plan.NewChange("consul_kv", pair.Key, plan.DiffData(map[string]interface{}{"value": string(remote.Value)}, desired, false)))
+	}
+
+	return changes, nil
+}
+
+// serviceData returns the fields of a catalog service hashi-helper manages
+func serviceData(service *api.AgentService) map[string]interface{} {
+	tags := make([]interface{}, 0, len(service.Tags))
+	for _, tag := range service.Tags {
+		tags = append(tags, tag)
+	}
+
+	meta := make(map[string]interface{}, len(service.Meta))
+	for k, v := range service.Meta {
+		meta[k] = v
+	}
+
+	return map[string]interface{}{
+		"address": service.Address,
+		"port":    service.Port,
+		"tags":    tags,
+		"meta":    meta,
+	}
+}
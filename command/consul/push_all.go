@@ -1,12 +1,50 @@
 package consul
 
 import (
+	"context"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/command/executor"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/command/snapshot"
 	cfg "github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
 	cli "gopkg.in/urfave/cli.v1"
 )
 
-// PushAll ...
-func PushAll(cli *cli.Context) error {
+// PushOptions configures the Consul push functions, it's the library equivalent of the push command flags
+type PushOptions struct {
+	// KeybaseRecipients are the keybase users the secret of newly created ACL tokens is encrypted for
+	KeybaseRecipients []string
+
+	// KeepGoing pushes the remaining resources after one failed, and returns all failures at the end
+	KeepGoing bool
+
+	// Concurrency is how many resources are written at the same time, resources are written one by one if it's 0
+	Concurrency int
+
+	// Force writes every KV pair, instead of only those that differ from what Consul returns
+	Force bool
+
+	// Snapshot captures the state of every resource before it's written, nothing is captured if it's nil
+	Snapshot *snapshot.Recorder
+}
+
+// failures collects the errors of a push according to KeepGoing
+func (o PushOptions) failures() *report.Failures {
+	return &report.Failures{KeepGoing: o.KeepGoing}
+}
+
+// executor returns the executor to run the writes of a push with these options
+func (o PushOptions) executor() *executor.Executor {
+	return executor.New(o.Concurrency, o.KeepGoing)
+}
+
+// pushFunc pushes one kind of Consul resource
+type pushFunc func(ctx context.Context, config *cfg.Config, client *api.Client, opts PushOptions) error
+
+// PushAllFromCLI ...
+func PushAllFromCLI(cli *cli.Context) error {
 	config, err := cfg.NewConfigFromCLI(cli)
 	if err != nil {
 		return err
@@ -17,9 +55,57 @@ func PushAll(cli *cli.Context) error {
 
 // PushAllWithConfig ...
 func PushAllWithConfig(cli *cli.Context, config *cfg.Config) error {
-	if err := ServicesPushWithConfig(cli, config); err != nil {
+	client, err := newClient(cli)
+	if err != nil {
 		return err
 	}
 
-	return KVPushWithConfig(cli, config)
+	return PushAll(context.Background(), config, client, pushOptionsFromCLI(cli))
+}
+
+// PushAll will push all Consul configuration
+func PushAll(ctx context.Context, config *cfg.Config, client *api.Client, opts PushOptions) error {
+	pushers := []pushFunc{
+		PushACL,
+		PushConfigEntries,
+		PushServices,
+		PushKV,
+	}
+
+	failures := opts.failures()
+
+	for _, fn := range pushers {
+		if err := failures.Add(fn(ctx, config, client, opts)); err != nil {
+			return err
+		}
+	}
+
+	return failures.Err()
+}
+
+// pushOptionsFromCLI returns the push options of the --keybase, --keep-going, --concurrency and --force flags
+func pushOptionsFromCLI(c *cli.Context) PushOptions {
+	return PushOptions{
+		KeybaseRecipients: c.StringSlice("keybase"),
+		KeepGoing:         c.GlobalBool("keep-going"),
+		Concurrency:       c.GlobalInt("concurrency"),
+		Force:             c.GlobalBool("force"),
+		Snapshot:          snapshot.FromCLI(c),
+	}
+}
+
+// newClient returns a Consul client configured from the environment, retrying transient
+// failures as often as the --retries flag allows
+func newClient(c *cli.Context) (*api.Client, error) {
+	config := api.DefaultConfig()
+
+	httpClient, err := api.NewHttpClient(config.Transport, config.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient.Transport = &support.RetryTransport{Base: httpClient.Transport, Retries: c.GlobalInt("retries")}
+	config.HttpClient = httpClient
+
+	return api.NewClient(config)
 }
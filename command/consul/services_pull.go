@@ -0,0 +1,103 @@
+package consul
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// ServicesPull will write all services registered on external nodes (nodes that are
+// not a member of the Consul cluster) to the first --config-dir
+func ServicesPull(c *cli.Context) error {
+	log.Info("Pulling Consul services")
+
+	env, dir, err := pullDirectory(c)
+	if err != nil {
+		return err
+	}
+
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return err
+	}
+
+	members, err := client.Agent().Members(false)
+	if err != nil {
+		return err
+	}
+
+	agents := make(map[string]bool, len(members))
+	for _, member := range members {
+		agents[member.Name] = true
+	}
+
+	nodes, _, err := client.Catalog().Nodes(&api.QueryOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		// services on agent nodes are registered by the agent itself
+		if agents[node.Node] {
+			continue
+		}
+
+		catalogNode, _, err := client.Catalog().Node(node.Node, &api.QueryOptions{})
+		if err != nil {
+			return err
+		}
+
+		if catalogNode == nil || len(catalogNode.Services) == 0 {
+			continue
+		}
+
+		ids := make([]string, 0, len(catalogNode.Services))
+		for id := range catalogNode.Services {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		w := support.NewHCLWriter()
+		w.Comment("Generated by hashi-helper consul-pull-services")
+		w.Block("environment", env)
+
+		for _, id := range ids {
+			service := catalogNode.Services[id]
+
+			address := service.Address
+			if address == "" {
+				address = node.Address
+			}
+
+			w.Block("service", service.Service)
+			if service.ID != service.Service {
+				w.Attribute("id", service.ID)
+			}
+			w.Attribute("node", node.Node)
+			w.Attribute("address", address)
+			w.Attribute("port", service.Port)
+			if len(service.Tags) > 0 {
+				w.Attribute("tags", service.Tags)
+			}
+			if len(service.Meta) > 0 {
+				w.Attribute("meta", service.Meta)
+			}
+			w.End()
+		}
+
+		w.End()
+
+		file := filepath.Join(dir, "consul_services", node.Node+".hcl")
+		if err := w.WriteFile(file, c.Bool("overwrite")); err != nil {
+			return err
+		}
+
+		log.Infof("  Wrote file: %s", file)
+	}
+
+	return nil
+}
@@ -1,7 +1,10 @@
 package consul
 
 import (
+	"context"
+
 	"github.com/hashicorp/consul/api"
+	"github.com/seatgeek/hashi-helper/command/report"
 	"github.com/seatgeek/hashi-helper/config"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
@@ -19,25 +22,52 @@ func ServicesPush(c *cli.Context) error {
 
 // ServicesPushWithConfig ...
 func ServicesPushWithConfig(c *cli.Context, config *config.Config) error {
-	client, err := api.NewClient(api.DefaultConfig())
+	client, err := newClient(c)
 	if err != nil {
 		return err
 	}
 
+	return PushServices(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// PushServices will register all service{} in the Consul catalog
+func PushServices(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
 	catalog := client.Catalog()
+	exec := opts.executor()
 
 	for _, service := range config.ConsulServices {
-		log.Infof("Saving consul service %s/%s", service.Node, service.Service.Service)
+		service := service
+		exec.Add("consul_service", service.Node+"/"+service.Service.Service, func() error {
+			return pushService(ctx, client, catalog, service, opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
 
-		consulService := service.ToConsulService()
+func pushService(ctx context.Context, client *api.Client, catalog *api.Catalog, service *config.ConsulService, opts PushOptions) error {
+	log.Infof("Saving consul service %s/%s", service.Node, service.Service.Service)
 
-		meta, err := catalog.Register(consulService, &api.WriteOptions{})
-		if err != nil {
-			return err
-		}
+	r := report.Start("consul_service", service.Node+"/"+service.Service.Service)
 
-		log.Infof("  Saved service in %s", meta.RequestTime.String())
+	consulService := service.ToConsulService()
+
+	// the catalog uses the service name as ID if none is set
+	serviceID := consulService.Service.ID
+	if serviceID == "" {
+		serviceID = consulService.Service.Service
+	}
+
+	if err := opts.Snapshot.CaptureConsulService(client, consulService.Node, serviceID); err != nil {
+		return r.Fail(err)
+	}
+
+	meta, err := catalog.Register(consulService, (&api.WriteOptions{}).WithContext(ctx))
+	if err != nil {
+		return r.Fail(err)
 	}
 
+	log.Infof("  Saved service in %s", meta.RequestTime.String())
+	r.Done(report.ActionUpdated)
 	return nil
 }
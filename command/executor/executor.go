@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/seatgeek/hashi-helper/command/report"
+	log "github.com/sirupsen/logrus"
+)
+
+// Task is a single unit of work of a push, usually writing one remote resource
+type Task struct {
+	Type string
+	Name string
+
+	run  func() error
+	deps []*Task
+
+	// done is set once the task ran or was skipped, ok if it ran without error
+	done bool
+	ok   bool
+}
+
+func (t *Task) String() string {
+	return t.Type + " " + t.Name
+}
+
+// Executor runs tasks on at most Concurrency workers. Tasks start in the order they were added
+// once all tasks they depend on succeeded, and are skipped if any of them failed or was skipped.
+type Executor struct {
+	// Concurrency is how many tasks run at the same time, at least 1
+	Concurrency int
+
+	// KeepGoing keeps starting tasks after one failed, and returns all errors at the end.
+	// Without it no task is started after the first failure
+	KeepGoing bool
+
+	// ProgressInterval is how often the number of finished tasks is logged (default: 5s)
+	ProgressInterval time.Duration
+
+	tasks []*Task
+}
+
+// New returns an executor running concurrency tasks at the same time
+func New(concurrency int, keepGoing bool) *Executor {
+	return &Executor{Concurrency: concurrency, KeepGoing: keepGoing}
+}
+
+// Add queues fn as task resourceType name, to run after deps. Dependencies must have been added
+// before, so there can't be cycles, nil dependencies are ignored
+func (e *Executor) Add(resourceType, name string, fn func() error, deps ...*Task) *Task {
+	task := &Task{Type: resourceType, Name: name, run: fn}
+
+	for _, dep := range deps {
+		if dep != nil {
+			task.deps = append(task.deps, dep)
+		}
+	}
+
+	e.tasks = append(e.tasks, task)
+	return task
+}
+
+// Len returns the number of queued tasks
+func (e *Executor) Len() int {
+	return len(e.tasks)
+}
+
+// result is a task that finished running
+type result struct {
+	task *Task
+	err  error
+}
+
+// Run runs all queued tasks and waits for them to complete. It returns the first error, or all
+// errors with KeepGoing. An executor can only be run once
+func (e *Executor) Run(ctx context.Context) error {
+	total := len(e.tasks)
+	if total == 0 {
+		return nil
+	}
+
+	concurrency := e.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var finished int64
+	stopProgress := e.logProgress(&finished, total)
+	defer stopProgress()
+
+	failures := &report.Failures{KeepGoing: e.KeepGoing}
+	results := make(chan result, concurrency)
+	pending := append([]*Task(nil), e.tasks...)
+	running := 0
+
+	var stopErr error
+	stopped := false
+	cancelled := ctx.Done()
+
+	for {
+		// start the first tasks that are ready, while workers are free
+		for i := 0; !stopped && ctx.Err() == nil && i < len(pending) && running < concurrency; {
+			task := pending[i]
+
+			ready, failedDep := task.state()
+			if !ready {
+				i++
+				continue
+			}
+
+			pending = append(pending[:i], pending[i+1:]...)
+
+			if failedDep != nil {
+				log.Warnf("Skipping %s, it depends on %s which was not pushed", task, failedDep)
+				task.done = true
+				atomic.AddInt64(&finished, 1)
+				continue
+			}
+
+			running++
+			go func(task *Task) {
+				start := time.Now()
+				err := task.run()
+				log.Debugf("Finished %s in %s", task, time.Since(start).Round(time.Millisecond))
+				results <- result{task: task, err: err}
+			}(task)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		select {
+		case res := <-results:
+			running--
+			atomic.AddInt64(&finished, 1)
+
+			res.task.done = true
+			res.task.ok = res.err == nil
+
+			// tasks already running may fail too, the push stops with the first error
+			if err := failures.Add(res.err); err != nil && stopErr == nil {
+				stopErr = err
+				stopped = true
+			}
+
+		case <-cancelled:
+			// running tasks are still waited for, so they don't outlive the push
+			stopped = true
+			cancelled = nil
+		}
+	}
+
+	if stopErr != nil {
+		return stopErr
+	}
+
+	if err := failures.Err(); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// state returns if all dependencies of the task are done, and the first one that failed or was skipped
+func (t *Task) state() (bool, *Task) {
+	for _, dep := range t.deps {
+		if !dep.done {
+			return false, nil
+		}
+	}
+
+	for _, dep := range t.deps {
+		if !dep.ok {
+			return true, dep
+		}
+	}
+
+	return true, nil
+}
+
+// logProgress logs how many of total tasks finished every ProgressInterval, until the returned func is called
+func (e *Executor) logProgress(finished *int64, total int) func() {
+	interval := e.ProgressInterval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	stop := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				log.Infof("Progress: %d of %d done", atomic.LoadInt64(finished), total)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(stop)
+	}
+}
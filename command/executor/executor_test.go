@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecutor_Run(t *testing.T) {
+	boom := errors.New("boom")
+
+	tests := []struct {
+		name        string
+		keepGoing   bool
+		failing     map[string]bool
+		expectErr   string
+		expectedRun []string
+	}{
+		{
+			name:        "runs all tasks",
+			expectedRun: []string{"mount", "config", "role", "policy"},
+		},
+		{
+			name:        "skips dependents of failed tasks",
+			keepGoing:   true,
+			failing:     map[string]bool{"mount": true},
+			expectErr:   "1 error occurred",
+			expectedRun: []string{"mount", "policy"},
+		},
+		{
+			name:        "collects all errors with keep going",
+			keepGoing:   true,
+			failing:     map[string]bool{"config": true, "policy": true},
+			expectErr:   "2 errors occurred",
+			expectedRun: []string{"mount", "config", "policy"},
+		},
+		{
+			name:        "stops at the first error",
+			failing:     map[string]bool{"mount": true},
+			expectErr:   "boom",
+			expectedRun: []string{"mount"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var ran []string
+
+			task := func(name string) func() error {
+				return func() error {
+					mu.Lock()
+					ran = append(ran, name)
+					mu.Unlock()
+
+					if tt.failing[name] {
+						return boom
+					}
+					return nil
+				}
+			}
+
+			e := New(1, tt.keepGoing)
+			mount := e.Add("vault_mount", "db", task("mount"))
+			config := e.Add("vault_mount_config", "db/config/main", task("config"), mount)
+			e.Add("vault_mount_role", "db/roles/read", task("role"), mount, config)
+			e.Add("vault_policy", "read", task("policy"), nil)
+
+			err := e.Run(context.Background())
+			if tt.expectErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.expectErr)
+			}
+
+			require.Equal(t, tt.expectedRun, ran)
+		})
+	}
+}
+
+func TestExecutor_Run_concurrency(t *testing.T) {
+	var running, max int64
+
+	e := New(3, false)
+	for i := 0; i < 20; i++ {
+		e.Add("vault_secret", "secret", func() error {
+			n := atomic.AddInt64(&running, 1)
+			defer atomic.AddInt64(&running, -1)
+
+			for {
+				m := atomic.LoadInt64(&max)
+				if n <= m || atomic.CompareAndSwapInt64(&max, m, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		})
+	}
+
+	require.NoError(t, e.Run(context.Background()))
+	require.Equal(t, int64(3), max)
+}
+
+func TestExecutor_Run_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runs := 0
+	e := New(1, true)
+	e.Add("vault_secret", "first", func() error {
+		runs++
+		cancel()
+		return nil
+	})
+	e.Add("vault_secret", "second", func() error {
+		runs++
+		return nil
+	})
+
+	require.Equal(t, context.Canceled, e.Run(ctx))
+	require.Equal(t, 1, runs)
+}
@@ -0,0 +1,85 @@
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// ACLPoliciesPush ...
+func ACLPoliciesPush(c *cli.Context) error {
+	config, err := config.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	return ACLPoliciesPushWithConfig(c, config)
+}
+
+// ACLPoliciesPushWithConfig ...
+func ACLPoliciesPushWithConfig(c *cli.Context, config *config.Config) error {
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	return PushACLPolicies(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// PushACLPolicies will create or update all nomad_acl_policy{} in Nomad
+func PushACLPolicies(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
+	if len(config.NomadACLPolicies) == 0 {
+		return nil
+	}
+
+	policies := client.ACLPolicies()
+	exec := opts.executor()
+
+	for _, policy := range config.NomadACLPolicies {
+		policy := policy
+		exec.Add("nomad_acl_policy", policy.Name, func() error {
+			return pushACLPolicy(ctx, policies, policy, opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
+
+func pushACLPolicy(ctx context.Context, policies *api.ACLPolicies, policy *config.NomadACLPolicy, opts PushOptions) error {
+	desired := policy.ToNomadACLPolicy()
+
+	r := report.Start("nomad_acl_policy", policy.Name)
+
+	existing, _, err := policies.Info(policy.Name, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil && !isNotFound(err) {
+		return r.Fail(fmt.Errorf("Could not read nomad ACL policy %s: %s", policy.Name, err))
+	}
+
+	if existing != nil && existing.Description == desired.Description && strings.TrimSpace(existing.Rules) == strings.TrimSpace(desired.Rules) {
+		log.Debugf("Nomad ACL policy %s is up to date", policy.Name)
+		r.Done(report.ActionUnchanged)
+		return nil
+	}
+
+	log.Infof("Saving nomad ACL policy %s", policy.Name)
+	if err := opts.Snapshot.CaptureNomadACLPolicy(policy.Name, existing); err != nil {
+		return r.Fail(err)
+	}
+
+	if _, err := policies.Upsert(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return r.Fail(fmt.Errorf("Could not write nomad ACL policy %s: %s", policy.Name, err))
+	}
+
+	if existing == nil {
+		r.Done(report.ActionCreated)
+	} else {
+		r.Done(report.ActionUpdated)
+	}
+	return nil
+}
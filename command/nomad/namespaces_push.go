@@ -0,0 +1,105 @@
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// NamespacesPush ...
+func NamespacesPush(c *cli.Context) error {
+	config, err := config.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	return NamespacesPushWithConfig(c, config)
+}
+
+// NamespacesPushWithConfig ...
+func NamespacesPushWithConfig(c *cli.Context, config *config.Config) error {
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	return PushNamespaces(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// PushNamespaces will create or update all nomad_namespace{} in Nomad
+func PushNamespaces(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
+	if len(config.NomadNamespaces) == 0 {
+		return nil
+	}
+
+	namespaces := client.Namespaces()
+	exec := opts.executor()
+
+	for _, namespace := range config.NomadNamespaces {
+		namespace := namespace
+		exec.Add("nomad_namespace", namespace.Name, func() error {
+			return pushNamespace(ctx, namespaces, namespace, opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
+
+func pushNamespace(ctx context.Context, namespaces *api.Namespaces, namespace *config.NomadNamespace, opts PushOptions) error {
+	desired := namespace.ToNomadNamespace()
+
+	r := report.Start("nomad_namespace", namespace.Name)
+
+	existing, _, err := namespaces.Info(namespace.Name, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil && !isNotFound(err) {
+		return r.Fail(fmt.Errorf("Could not read nomad namespace %s: %s", namespace.Name, err))
+	}
+
+	if existing != nil && existing.Description == desired.Description && existing.Quota == desired.Quota && equalMeta(existing.Meta, desired.Meta) {
+		log.Debugf("Nomad namespace %s is up to date", namespace.Name)
+		r.Done(report.ActionUnchanged)
+		return nil
+	}
+
+	log.Infof("Saving nomad namespace %s", namespace.Name)
+	if err := opts.Snapshot.CaptureNomadNamespace(namespace.Name, existing); err != nil {
+		return r.Fail(err)
+	}
+
+	if _, err := namespaces.Register(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return r.Fail(fmt.Errorf("Could not write nomad namespace %s: %s", namespace.Name, err))
+	}
+
+	if existing == nil {
+		r.Done(report.ActionCreated)
+	} else {
+		r.Done(report.ActionUpdated)
+	}
+	return nil
+}
+
+// isNotFound returns true if Nomad responded with a 404 to a read
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "404")
+}
+
+// equalMeta compares two meta maps, treating nil and empty as equal
+func equalMeta(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
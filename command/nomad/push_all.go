@@ -0,0 +1,117 @@
+package nomad
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"time"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/nomad/api"
+	"github.com/seatgeek/hashi-helper/command/executor"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/command/snapshot"
+	cfg "github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// PushOptions configures the Nomad push functions, it's the library equivalent of the push command flags
+type PushOptions struct {
+	// KeepGoing pushes the remaining resources after one failed, and returns all failures at the end
+	KeepGoing bool
+
+	// Concurrency is how many resources are written at the same time, resources are written one by one if it's 0
+	Concurrency int
+
+	// Snapshot captures the state of every resource before it's written, nothing is captured if it's nil
+	Snapshot *snapshot.Recorder
+}
+
+// failures collects the errors of a push according to KeepGoing
+func (o PushOptions) failures() *report.Failures {
+	return &report.Failures{KeepGoing: o.KeepGoing}
+}
+
+// executor returns the executor to run the writes of a push with these options
+func (o PushOptions) executor() *executor.Executor {
+	return executor.New(o.Concurrency, o.KeepGoing)
+}
+
+// pushFunc pushes one kind of Nomad resource
+type pushFunc func(ctx context.Context, config *cfg.Config, client *api.Client, opts PushOptions) error
+
+// PushAllFromCLI ...
+func PushAllFromCLI(cli *cli.Context) error {
+	config, err := cfg.NewConfigFromCLI(cli)
+	if err != nil {
+		return err
+	}
+
+	return PushAllWithConfig(cli, config)
+}
+
+// PushAllWithConfig ...
+func PushAllWithConfig(cli *cli.Context, config *cfg.Config) error {
+	client, err := newClient(cli)
+	if err != nil {
+		return err
+	}
+
+	return PushAll(context.Background(), config, client, pushOptionsFromCLI(cli))
+}
+
+// PushAll will push all Nomad configuration
+func PushAll(ctx context.Context, config *cfg.Config, client *api.Client, opts PushOptions) error {
+	// namespaces reference quotas, and policies reference namespaces
+	pushers := []pushFunc{
+		PushQuotas,
+		PushNamespaces,
+		PushACLPolicies,
+	}
+
+	failures := opts.failures()
+
+	for _, fn := range pushers {
+		if err := failures.Add(fn(ctx, config, client, opts)); err != nil {
+			return err
+		}
+	}
+
+	return failures.Err()
+}
+
+// pushOptionsFromCLI returns the push options of the --keep-going and --concurrency flags
+func pushOptionsFromCLI(c *cli.Context) PushOptions {
+	return PushOptions{
+		KeepGoing:   c.GlobalBool("keep-going"),
+		Concurrency: c.GlobalInt("concurrency"),
+		Snapshot:    snapshot.FromCLI(c),
+	}
+}
+
+// newClient returns a Nomad client configured from the environment, retrying transient
+// failures as often as the --retries flag allows
+func newClient(c *cli.Context) (*api.Client, error) {
+	config := api.DefaultConfig()
+
+	// unix sockets are local, the client sets up its own transport for them
+	if strings.HasPrefix(config.Address, "unix://") {
+		return api.NewClient(config)
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	httpClient := &http.Client{Transport: transport}
+	if err := api.ConfigureTLS(httpClient, config.TLSConfig); err != nil {
+		return nil, err
+	}
+
+	httpClient.Transport = &support.RetryTransport{Base: transport, Retries: c.GlobalInt("retries")}
+	config.HttpClient = httpClient
+
+	return api.NewClient(config)
+}
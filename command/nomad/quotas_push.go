@@ -0,0 +1,104 @@
+package nomad
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// QuotasPush ...
+func QuotasPush(c *cli.Context) error {
+	config, err := config.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	return QuotasPushWithConfig(c, config)
+}
+
+// QuotasPushWithConfig ...
+func QuotasPushWithConfig(c *cli.Context, config *config.Config) error {
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	return PushQuotas(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// PushQuotas will create or update all nomad_quota{} in Nomad (Enterprise only)
+func PushQuotas(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
+	if len(config.NomadQuotas) == 0 {
+		return nil
+	}
+
+	quotas := client.Quotas()
+	exec := opts.executor()
+
+	for _, quota := range config.NomadQuotas {
+		quota := quota
+		exec.Add("nomad_quota", quota.Name, func() error {
+			return pushQuota(ctx, quotas, quota, opts)
+		})
+	}
+
+	return exec.Run(ctx)
+}
+
+func pushQuota(ctx context.Context, quotas *api.Quotas, quota *config.NomadQuota, opts PushOptions) error {
+	desired := quota.ToNomadQuota()
+
+	r := report.Start("nomad_quota", quota.Name)
+
+	existing, _, err := quotas.Info(quota.Name, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil && !isNotFound(err) {
+		return r.Fail(fmt.Errorf("Could not read nomad quota %s: %s", quota.Name, err))
+	}
+
+	if existing != nil && existing.Description == desired.Description && equalQuotaLimits(existing.Limits, desired.Limits) {
+		log.Debugf("Nomad quota %s is up to date", quota.Name)
+		r.Done(report.ActionUnchanged)
+		return nil
+	}
+
+	log.Infof("Saving nomad quota %s", quota.Name)
+	if err := opts.Snapshot.CaptureNomadQuota(quota.Name, existing); err != nil {
+		return r.Fail(err)
+	}
+
+	if _, err := quotas.Register(desired, (&api.WriteOptions{}).WithContext(ctx)); err != nil {
+		return r.Fail(fmt.Errorf("Could not write nomad quota %s: %s", quota.Name, err))
+	}
+
+	if existing == nil {
+		r.Done(report.ActionCreated)
+	} else {
+		r.Done(report.ActionUpdated)
+	}
+	return nil
+}
+
+func equalQuotaLimits(a, b []*api.QuotaLimit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Region != b[i].Region ||
+			!reflect.DeepEqual(a[i].RegionLimit.CPU, b[i].RegionLimit.CPU) ||
+			!reflect.DeepEqual(a[i].RegionLimit.Cores, b[i].RegionLimit.Cores) ||
+			!reflect.DeepEqual(a[i].RegionLimit.MemoryMB, b[i].RegionLimit.MemoryMB) ||
+			!reflect.DeepEqual(a[i].RegionLimit.MemoryMaxMB, b[i].RegionLimit.MemoryMaxMB) ||
+			!reflect.DeepEqual(a[i].VariablesLimit, b[i].VariablesLimit) {
+			return false
+		}
+	}
+
+	return true
+}
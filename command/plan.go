@@ -0,0 +1,39 @@
+package command
+
+import (
+	"os"
+
+	consul "github.com/seatgeek/hashi-helper/command/consul"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	vault "github.com/seatgeek/hashi-helper/command/vault"
+	"github.com/seatgeek/hashi-helper/config"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Plan ...
+func Plan(cli *cli.Context) error {
+	config, err := config.NewConfigFromCLI(cli)
+	if err != nil {
+		return err
+	}
+
+	changes := plan.Changes{}
+
+	// Consul
+	consulChanges, err := consul.PlanWithConfig(cli, config)
+	if err != nil {
+		return err
+	}
+	changes.Append(consulChanges)
+
+	// Vault
+	vaultChanges, err := vault.PlanWithConfig(cli, config)
+	if err != nil {
+		return err
+	}
+	changes.Append(vaultChanges)
+
+	changes.Print(os.Stdout)
+
+	return plan.ExitCode(cli, changes)
+}
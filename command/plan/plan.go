@@ -0,0 +1,359 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Action describes what a push would do to a single remote resource
+type Action string
+
+const (
+	// ActionCreate means the resource does not exist remotely
+	ActionCreate Action = "create"
+
+	// ActionUpdate means the resource exists remotely but differs from config
+	ActionUpdate Action = "update"
+
+	// ActionDelete means the resource exists remotely but not in config
+	ActionDelete Action = "delete"
+
+	// ActionNoop means the remote resource matches the config
+	ActionNoop Action = "no-op"
+)
+
+var actionSymbols = map[Action]string{
+	ActionCreate: "+",
+	ActionUpdate: "~",
+	ActionDelete: "-",
+	ActionNoop:   "=",
+}
+
+// sensitiveMask is shown instead of the real value of sensitive fields
+const sensitiveMask = "(sensitive value)"
+
+// FieldChange is a single key that differs between remote and local state
+type FieldChange struct {
+	Key       string
+	Old       interface{}
+	New       interface{}
+	Sensitive bool
+}
+
+// Change is the planned action for a single remote resource
+type Change struct {
+	Resource string
+	Name     string
+	Action   Action
+	Fields   []FieldChange
+}
+
+// NewChange returns an update change if any fields changed, otherwise a no-op
+func NewChange(resource, name string, fields []FieldChange) *Change {
+	action := ActionNoop
+	if len(fields) > 0 {
+		action = ActionUpdate
+	}
+
+	return &Change{Resource: resource, Name: name, Action: action, Fields: fields}
+}
+
+// Changes ...
+type Changes []*Change
+
+// Add ...
+func (c *Changes) Add(change *Change) {
+	*c = append(*c, change)
+}
+
+// Append ...
+func (c *Changes) Append(changes Changes) {
+	*c = append(*c, changes...)
+}
+
+// Count returns the number of changes with the provided action
+func (c Changes) Count(action Action) int {
+	count := 0
+	for _, change := range c {
+		if change.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
+
+// HasChanges returns true if any change is different from a no-op
+func (c Changes) HasChanges() bool {
+	return len(c) > c.Count(ActionNoop)
+}
+
+// Print writes a human readable diff of all changes to w
+func (c Changes) Print(w io.Writer) {
+	for _, change := range c {
+		fmt.Fprintf(w, "%s %-6s %s %q\n", actionSymbols[change.Action], change.Action, change.Resource, change.Name)
+
+		for _, field := range change.Fields {
+			field.print(w)
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete, %d unchanged.\n",
+		c.Count(ActionCreate), c.Count(ActionUpdate), c.Count(ActionDelete), c.Count(ActionNoop))
+}
+
+// ExitCode returns an error with exit code 2 if --detailed-exitcode is set and
+// the plan contains changes, so CI pipelines can tell a clean plan from a dirty one
+func ExitCode(c *cli.Context, changes Changes) error {
+	if c.Bool("detailed-exitcode") && changes.HasChanges() {
+		return cli.NewExitError("", 2)
+	}
+
+	return nil
+}
+
+func (f FieldChange) print(w io.Writer) {
+	if f.Sensitive {
+		switch {
+		case f.Old == nil:
+			fmt.Fprintf(w, "    + %s: %s\n", f.Key, sensitiveMask)
+		case f.New == nil:
+			fmt.Fprintf(w, "    - %s: %s\n", f.Key, sensitiveMask)
+		default:
+			fmt.Fprintf(w, "    ~ %s: %s\n", f.Key, sensitiveMask)
+		}
+		return
+	}
+
+	oldValue, newValue := formatValue(f.Old), formatValue(f.New)
+
+	// multi-line values (policies, SQL statements) are easier to review as a line diff
+	if strings.Contains(oldValue, "\n") || strings.Contains(newValue, "\n") {
+		fmt.Fprintf(w, "    ~ %s:\n", f.Key)
+		for _, line := range LineDiff(oldValue, newValue) {
+			fmt.Fprintf(w, "        %s\n", line)
+		}
+		return
+	}
+
+	switch {
+	case f.Old == nil:
+		fmt.Fprintf(w, "    + %s: %s\n", f.Key, newValue)
+	case f.New == nil:
+		fmt.Fprintf(w, "    - %s: %s\n", f.Key, oldValue)
+	default:
+		fmt.Fprintf(w, "    ~ %s: %s => %s\n", f.Key, oldValue, newValue)
+	}
+}
+
+// DiffData compares the desired data with the data read from the remote server
+//
+// Keys the remote server did not return are only considered changed when the resource
+// does not exist at all (remote == nil), since most Vault endpoints never echo back
+// write-only fields such as passwords.
+func DiffData(remote, desired map[string]interface{}, sensitive bool) []FieldChange {
+	changes := make([]FieldChange, 0)
+
+	for _, key := range sortedKeys(desired) {
+		newValue := desired[key]
+
+		if remote == nil {
+			changes = append(changes, FieldChange{Key: key, New: newValue, Sensitive: sensitive})
+			continue
+		}
+
+		oldValue, ok := remote[key]
+		if !ok {
+			continue
+		}
+
+		if !EqualSetting(oldValue, newValue) {
+			changes = append(changes, FieldChange{Key: key, Old: oldValue, New: newValue, Sensitive: sensitive})
+		}
+	}
+
+	return changes
+}
+
+// DiffDataForWrite works like DiffData, but keys the remote server did not return are
+// considered changed. A push uses it to decide if a resource must be written, since a
+// missing key may be a write-only field (like a password) whose value can't be compared.
+func DiffDataForWrite(remote, desired map[string]interface{}, sensitive bool) []FieldChange {
+	if remote == nil {
+		return DiffData(nil, desired, sensitive)
+	}
+
+	changes := DiffData(remote, desired, sensitive)
+	for _, key := range sortedKeys(desired) {
+		if _, ok := remote[key]; !ok {
+			changes = append(changes, FieldChange{Key: key, New: desired[key], Sensitive: sensitive})
+		}
+	}
+
+	return changes
+}
+
+// DiffDataStrict works like DiffData, but also reports keys that only exist remotely,
+// and keys missing from the remote data. It's used for resources where the remote
+// server returns exactly what was written, like secrets.
+func DiffDataStrict(remote, desired map[string]interface{}, sensitive bool) []FieldChange {
+	changes := make([]FieldChange, 0)
+
+	for _, key := range sortedKeys(desired) {
+		oldValue, ok := remote[key]
+		if !ok {
+			changes = append(changes, FieldChange{Key: key, New: desired[key], Sensitive: sensitive})
+			continue
+		}
+
+		if !Equal(oldValue, desired[key]) {
+			changes = append(changes, FieldChange{Key: key, Old: oldValue, New: desired[key], Sensitive: sensitive})
+		}
+	}
+
+	for _, key := range sortedKeys(remote) {
+		if _, ok := desired[key]; !ok {
+			changes = append(changes, FieldChange{Key: key, Old: remote[key], Sensitive: sensitive})
+		}
+	}
+
+	return changes
+}
+
+// Equal compares two values loosely, the way Vault and Consul would interpret them
+//
+// Numbers are compared by their string value, and lists are compared to their
+// comma separated string form, since Vault accepts both for most list fields.
+func Equal(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+
+	return formatValue(a) == formatValue(b)
+}
+
+// EqualSetting works like Equal, but also treats durations with different units as equal, since
+// Vault returns TTLs in seconds no matter how they were written (e.g. "1h" and 3600)
+func EqualSetting(a, b interface{}) bool {
+	if Equal(a, b) {
+		return true
+	}
+
+	durationA, okA := parseDuration(a)
+	durationB, okB := parseDuration(b)
+
+	return okA && okB && durationA == durationB
+}
+
+// parseDuration parses a duration the way Vault does, a number is in seconds and "d" is days
+func parseDuration(v interface{}) (time.Duration, bool) {
+	s := strings.TrimSpace(formatValue(v))
+	if s == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.ParseInt(strings.TrimSuffix(s, "d"), 10, 64); err == nil {
+			return time.Duration(days) * 24 * time.Hour, true
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	return d, err == nil
+}
+
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []string:
+		return strings.Join(t, ",")
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, p := range t {
+			parts = append(parts, formatValue(p))
+		}
+		return strings.Join(parts, ",")
+	case map[string]interface{}:
+		parts := make([]string, 0, len(t))
+		for _, k := range sortedKeys(t) {
+			parts = append(parts, fmt.Sprintf("%s=%s", k, formatValue(t[k])))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// LineDiff returns a minimal line based diff between old and new, each line prefixed
+// with "+", "-" or " "
+func LineDiff(old, new string) []string {
+	a := strings.Split(strings.TrimSpace(old), "\n")
+	b := strings.Split(strings.TrimSpace(new), "\n")
+
+	// longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	out := make([]string, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+a[i])
+			i++
+		default:
+			out = append(out, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		out = append(out, "+ "+b[j])
+	}
+
+	return out
+}
@@ -0,0 +1,122 @@
+package plan
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffData(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  map[string]interface{}
+		desired map[string]interface{}
+		want    []FieldChange
+	}{
+		{
+			name:    "missing remote reports every key",
+			desired: map[string]interface{}{"a": "1", "b": "2"},
+			want:    []FieldChange{{Key: "a", New: "1"}, {Key: "b", New: "2"}},
+		},
+		{
+			name:    "write-only keys are ignored",
+			remote:  map[string]interface{}{"a": "1"},
+			desired: map[string]interface{}{"a": "1", "password": "secret"},
+			want:    []FieldChange{},
+		},
+		{
+			name:    "lists and comma separated strings are equal",
+			remote:  map[string]interface{}{"policies": []interface{}{"a", "b"}},
+			desired: map[string]interface{}{"policies": "a,b"},
+			want:    []FieldChange{},
+		},
+		{
+			name:    "numbers are compared by value",
+			remote:  map[string]interface{}{"ttl": float64(3600)},
+			desired: map[string]interface{}{"ttl": 3600},
+			want:    []FieldChange{},
+		},
+		{
+			name:    "durations are compared in seconds",
+			remote:  map[string]interface{}{"ttl": float64(3600), "max_ttl": float64(172800), "period": "0"},
+			desired: map[string]interface{}{"ttl": "1h", "max_ttl": "2d", "period": "0s"},
+			want:    []FieldChange{},
+		},
+		{
+			name:    "changed duration",
+			remote:  map[string]interface{}{"ttl": float64(3600)},
+			desired: map[string]interface{}{"ttl": "2h"},
+			want:    []FieldChange{{Key: "ttl", Old: float64(3600), New: "2h"}},
+		},
+		{
+			name:    "changed value",
+			remote:  map[string]interface{}{"a": "1"},
+			desired: map[string]interface{}{"a": "2"},
+			want:    []FieldChange{{Key: "a", Old: "1", New: "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, DiffData(tt.remote, tt.desired, false))
+		})
+	}
+}
+
+func TestDiffDataForWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  map[string]interface{}
+		desired map[string]interface{}
+		want    []FieldChange
+	}{
+		{
+			name:    "defaults echoed by the server are ignored",
+			remote:  map[string]interface{}{"ttl": float64(3600), "token_type": "default", "bound_cidrs": []interface{}{}},
+			desired: map[string]interface{}{"ttl": "1h"},
+			want:    []FieldChange{},
+		},
+		{
+			name:    "write-only keys are changed",
+			remote:  map[string]interface{}{"username": "vault"},
+			desired: map[string]interface{}{"username": "vault", "password": "secret"},
+			want:    []FieldChange{{Key: "password", New: "secret", Sensitive: true}},
+		},
+		{
+			name:    "missing remote reports every key",
+			desired: map[string]interface{}{"a": "1"},
+			want:    []FieldChange{{Key: "a", New: "1", Sensitive: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, DiffDataForWrite(tt.remote, tt.desired, true))
+		})
+	}
+}
+
+func TestChanges_PrintMasksSensitiveValues(t *testing.T) {
+	changes := Changes{
+		{
+			Resource: "vault_secret",
+			Name:     "secret/app/key",
+			Action:   ActionUpdate,
+			Fields:   DiffDataStrict(map[string]interface{}{"value": "old"}, map[string]interface{}{"value": "new"}, true),
+		},
+	}
+
+	var buf bytes.Buffer
+	changes.Print(&buf)
+
+	require.NotContains(t, buf.String(), "old")
+	require.NotContains(t, buf.String(), "new")
+	require.Contains(t, buf.String(), "~ value: (sensitive value)")
+	require.Contains(t, buf.String(), "Plan: 0 to create, 1 to update, 0 to delete, 0 unchanged.")
+}
+
+func TestLineDiff(t *testing.T) {
+	got := LineDiff("a\nb\nc", "a\nx\nc")
+	require.Equal(t, []string{"  a", "- b", "+ x", "  c"}, got)
+}
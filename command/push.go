@@ -2,6 +2,8 @@ package command
 
 import (
 	consul "github.com/seatgeek/hashi-helper/command/consul"
+	nomad "github.com/seatgeek/hashi-helper/command/nomad"
+	"github.com/seatgeek/hashi-helper/command/report"
 	vault "github.com/seatgeek/hashi-helper/command/vault"
 	"github.com/seatgeek/hashi-helper/config"
 	cli "gopkg.in/urfave/cli.v1"
@@ -14,11 +16,22 @@ func PushAll(cli *cli.Context) error {
 		return err
 	}
 
+	failures := &report.Failures{KeepGoing: cli.GlobalBool("keep-going")}
+
 	// Consul
-	if err := consul.PushAllWithConfig(cli, config); err != nil {
+	if err := failures.Add(consul.PushAllWithConfig(cli, config)); err != nil {
 		return err
 	}
 
 	// Vault
-	return vault.PushAllWithConfig(cli, config)
+	if err := failures.Add(vault.PushAllWithConfig(cli, config)); err != nil {
+		return err
+	}
+
+	// Nomad
+	if err := failures.Add(nomad.PushAllWithConfig(cli, config)); err != nil {
+		return err
+	}
+
+	return failures.Err()
 }
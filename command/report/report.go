@@ -0,0 +1,354 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Action is what a push did to a single remote resource
+type Action string
+
+const (
+	// ActionCreated means the resource did not exist remotely
+	ActionCreated Action = "created"
+
+	// ActionUpdated means the resource was written, and existed remotely (or it's unknown if it did)
+	ActionUpdated Action = "updated"
+
+	// ActionUnchanged means the resource already matched the config, and was not written
+	ActionUnchanged Action = "unchanged"
+
+	// ActionDeleted means the resource was pruned
+	ActionDeleted Action = "deleted"
+
+	// ActionFailed means writing the resource failed
+	ActionFailed Action = "failed"
+)
+
+// FromPlan returns the action a push of a planned change results in
+func FromPlan(action plan.Action) Action {
+	switch action {
+	case plan.ActionCreate:
+		return ActionCreated
+	case plan.ActionDelete:
+		return ActionDeleted
+	case plan.ActionNoop:
+		return ActionUnchanged
+	default:
+		return ActionUpdated
+	}
+}
+
+// Record is the outcome of pushing a single remote resource
+type Record struct {
+	Type        string   `json:"type"`
+	Environment string   `json:"environment,omitempty"`
+	Application string   `json:"application,omitempty"`
+	Namespace   string   `json:"namespace,omitempty"`
+	Path        string   `json:"path"`
+	Action      Action   `json:"action"`
+	DurationMS  int64    `json:"duration_ms"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Error       string   `json:"error,omitempty"`
+
+	reporter *Reporter
+	start    time.Time
+}
+
+// Summary counts the records by action
+type Summary struct {
+	Created    int   `json:"created"`
+	Updated    int   `json:"updated"`
+	Unchanged  int   `json:"unchanged"`
+	Deleted    int   `json:"deleted"`
+	Failed     int   `json:"failed"`
+	DurationMS int64 `json:"duration_ms"`
+	ExitCode   int   `json:"exit_code"`
+
+	Timings []Timing `json:"timings,omitempty"`
+}
+
+// Timing sums up how long the records of one resource type took
+type Timing struct {
+	Type    string `json:"type"`
+	Count   int    `json:"count"`
+	TotalMS int64  `json:"total_ms"`
+	MaxMS   int64  `json:"max_ms"`
+	Slowest string `json:"slowest"`
+}
+
+// Reporter collects the records of a single run
+type Reporter struct {
+	sync.Mutex
+
+	environment string
+	file        string
+	records     []*Record
+	start       time.Time
+	stream      io.Writer
+}
+
+// current is the reporter used by Start, it only collects records until Configure is called
+var current = &Reporter{start: time.Now()}
+
+// Configure sets up the reporter from the global --output and --report-file flags
+func Configure(c *cli.Context) error {
+	current = &Reporter{
+		environment: c.GlobalString("environment"),
+		file:        c.GlobalString("report-file"),
+		start:       time.Now(),
+	}
+
+	switch output := c.GlobalString("output"); output {
+	case "", "text":
+	case "json":
+		current.stream = os.Stdout
+	default:
+		return fmt.Errorf("Invalid --output %s, must be text or json", output)
+	}
+
+	return nil
+}
+
+// Start begins a record for the resource type at path, it must be completed with Done or Fail
+func Start(resourceType, path string) *Record {
+	return &Record{
+		Type:        resourceType,
+		Path:        path,
+		Environment: current.environment,
+		reporter:    current,
+		start:       time.Now(),
+	}
+}
+
+// App sets the application the resource belongs to
+func (r *Record) App(name string) *Record {
+	r.Application = name
+	return r
+}
+
+// In sets the namespace the resource belongs to
+func (r *Record) In(namespace string) *Record {
+	r.Namespace = namespace
+	return r
+}
+
+// Warn adds remote warnings to the record
+func (r *Record) Warn(warnings ...string) *Record {
+	r.Warnings = append(r.Warnings, warnings...)
+	return r
+}
+
+// Done completes the record with action
+func (r *Record) Done(action Action) {
+	r.Action = action
+	r.reporter.add(r)
+}
+
+// Fail completes the record as failed, and returns err with the resource as context, so it can be used as `return r.Fail(err)`
+func (r *Record) Fail(err error) error {
+	r.Action = ActionFailed
+	r.Error = err.Error()
+	r.reporter.add(r)
+	return &ResourceError{Type: r.Type, Namespace: r.Namespace, Path: r.Path, Err: err}
+}
+
+// ResourceError is the error pushing a single remote resource failed with
+type ResourceError struct {
+	Type      string
+	Namespace string
+	Path      string
+	Err       error
+}
+
+func (e *ResourceError) Error() string {
+	if e.Namespace != "" {
+		return fmt.Sprintf("[%s %s, namespace %s] %s", e.Type, e.Path, e.Namespace, e.Err)
+	}
+
+	return fmt.Sprintf("[%s %s] %s", e.Type, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// Failures collects the errors of a push. With KeepGoing a failed resource doesn't stop the push,
+// and all errors are returned together at the end
+type Failures struct {
+	KeepGoing bool
+
+	errors *multierror.Error
+}
+
+// Add collects err, and returns the error the push has to stop with, or nil if it should keep going
+func (f *Failures) Add(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	f.errors = multierror.Append(f.errors, err)
+	if f.KeepGoing {
+		return nil
+	}
+
+	return err
+}
+
+// Err returns all collected errors, or nil if nothing failed
+func (f *Failures) Err() error {
+	return f.errors.ErrorOrNil()
+}
+
+func (rep *Reporter) add(r *Record) {
+	r.DurationMS = time.Since(r.start).Milliseconds()
+
+	rep.Lock()
+	defer rep.Unlock()
+
+	rep.records = append(rep.records, r)
+
+	if rep.stream != nil {
+		writeJSON(rep.stream, map[string]interface{}{"record": r})
+	}
+}
+
+// Summarize counts the records collected so far
+func Summarize() Summary {
+	current.Lock()
+	defer current.Unlock()
+
+	summary := Summary{DurationMS: time.Since(current.start).Milliseconds()}
+	timings := map[string]*Timing{}
+
+	for _, r := range current.records {
+		timing, ok := timings[r.Type]
+		if !ok {
+			timing = &Timing{Type: r.Type}
+			timings[r.Type] = timing
+		}
+
+		timing.Count++
+		timing.TotalMS += r.DurationMS
+		if r.DurationMS > timing.MaxMS || timing.Slowest == "" {
+			timing.MaxMS = r.DurationMS
+			timing.Slowest = r.Path
+		}
+
+		switch r.Action {
+		case ActionCreated:
+			summary.Created++
+		case ActionUpdated:
+			summary.Updated++
+		case ActionUnchanged:
+			summary.Unchanged++
+		case ActionDeleted:
+			summary.Deleted++
+		case ActionFailed:
+			summary.Failed++
+		}
+	}
+
+	for _, timing := range timings {
+		summary.Timings = append(summary.Timings, *timing)
+	}
+
+	// slowest resource types first
+	sort.Slice(summary.Timings, func(i, j int) bool {
+		if summary.Timings[i].TotalMS != summary.Timings[j].TotalMS {
+			return summary.Timings[i].TotalMS > summary.Timings[j].TotalMS
+		}
+		return summary.Timings[i].Type < summary.Timings[j].Type
+	})
+
+	summary.ExitCode = summary.exitCode(0)
+	return summary
+}
+
+// ExitCode returns the exit code for the run, 2 if some resources were pushed before others failed,
+// 1 if all failed and otherwise fallback
+func ExitCode(fallback int) int {
+	return Summarize().exitCode(fallback)
+}
+
+func (s Summary) exitCode(fallback int) int {
+	if s.Failed == 0 {
+		return fallback
+	}
+
+	if s.Created+s.Updated+s.Deleted > 0 {
+		return 2
+	}
+
+	return 1
+}
+
+// Finish writes the summary to the JSON output, and all records and the summary to the report file
+func Finish(err error) error {
+	summary := Summarize()
+	if err != nil && summary.ExitCode == 0 {
+		summary.ExitCode = 1
+	}
+
+	current.Lock()
+	defer current.Unlock()
+
+	if current.stream != nil {
+		writeJSON(current.stream, map[string]interface{}{"summary": summary})
+	} else {
+		logTimings(summary)
+	}
+
+	if current.file == "" {
+		return nil
+	}
+
+	records := current.records
+	if records == nil {
+		records = []*Record{}
+	}
+
+	b, jsonErr := json.MarshalIndent(map[string]interface{}{"records": records, "summary": summary}, "", "  ")
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	return ioutil.WriteFile(current.file, append(b, '\n'), 0644)
+}
+
+// logTimings logs how long each resource type took to push
+func logTimings(summary Summary) {
+	if len(summary.Timings) == 0 {
+		return
+	}
+
+	log.Infof("Pushed %d resource(s) in %s", summary.Created+summary.Updated+summary.Unchanged+summary.Deleted+summary.Failed, ms(summary.DurationMS))
+	for _, timing := range summary.Timings {
+		log.Infof("  %-28s %6d  total %-10s max %s (%s)", timing.Type, timing.Count, ms(timing.TotalMS), ms(timing.MaxMS), timing.Slowest)
+	}
+}
+
+func ms(milliseconds int64) time.Duration {
+	return time.Duration(milliseconds) * time.Millisecond
+}
+
+func writeJSON(w io.Writer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintln(w, string(b))
+}
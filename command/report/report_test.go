@@ -0,0 +1,119 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		actions  []Action
+		fallback int
+		expected int
+	}{
+		{
+			name:     "no records",
+			expected: 0,
+		},
+		{
+			name:     "no failures",
+			actions:  []Action{ActionCreated, ActionUnchanged},
+			fallback: 0,
+			expected: 0,
+		},
+		{
+			name:     "only failures",
+			actions:  []Action{ActionUnchanged, ActionFailed},
+			fallback: 0,
+			expected: 1,
+		},
+		{
+			name:     "partial failure",
+			actions:  []Action{ActionUpdated, ActionFailed},
+			fallback: 1,
+			expected: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current = &Reporter{start: time.Now()}
+
+			for _, action := range tt.actions {
+				r := Start("vault_mount", "secret")
+				if action == ActionFailed {
+					r.Fail(errors.New("boom"))
+					continue
+				}
+				r.Done(action)
+			}
+
+			require.Equal(t, tt.expected, ExitCode(tt.fallback))
+		})
+	}
+}
+
+func TestFinish(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.json")
+	current = &Reporter{start: time.Now(), environment: "production", file: file}
+
+	Start("vault_policy", "admin").App("api").Warn("deprecated").Done(ActionCreated)
+	Start("vault_policy", "read").Fail(errors.New("permission denied"))
+
+	require.NoError(t, Finish(nil))
+
+	b, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+
+	var result struct {
+		Records []*Record
+		Summary Summary
+	}
+	require.NoError(t, json.Unmarshal(b, &result))
+
+	require.Len(t, result.Records, 2)
+	require.Equal(t, "production", result.Records[0].Environment)
+	require.Equal(t, "api", result.Records[0].Application)
+	require.Equal(t, []string{"deprecated"}, result.Records[0].Warnings)
+	require.Equal(t, "permission denied", result.Records[1].Error)
+	require.Equal(t, 1, result.Summary.Created)
+	require.Equal(t, 1, result.Summary.Failed)
+	require.Equal(t, 2, result.Summary.ExitCode)
+
+	require.Len(t, result.Summary.Timings, 1)
+	require.Equal(t, "vault_policy", result.Summary.Timings[0].Type)
+	require.Equal(t, 2, result.Summary.Timings[0].Count)
+	require.Contains(t, []string{"admin", "read"}, result.Summary.Timings[0].Slowest)
+}
+
+func TestFailures(t *testing.T) {
+	current = &Reporter{start: time.Now()}
+
+	boom := errors.New("boom")
+
+	failures := &Failures{}
+	require.NoError(t, failures.Add(nil))
+	err := failures.Add(Start("vault_mount", "secret").Fail(boom))
+	require.EqualError(t, err, "[vault_mount secret] boom")
+	require.True(t, errors.Is(err, boom))
+
+	failures = &Failures{KeepGoing: true}
+	require.NoError(t, failures.Add(Start("vault_mount", "secret").Fail(boom)))
+	require.NoError(t, failures.Add(Start("vault_policy", "admin").In("team-a").Fail(boom)))
+	require.NoError(t, failures.Add(nil))
+
+	err = failures.Err()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "2 errors occurred")
+	require.Contains(t, err.Error(), "[vault_mount secret] boom")
+	require.Contains(t, err.Error(), "[vault_policy admin, namespace team-a] boom")
+
+	require.NoError(t, (&Failures{KeepGoing: true}).Err())
+}
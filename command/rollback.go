@@ -0,0 +1,36 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/seatgeek/hashi-helper/command/snapshot"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Rollback restores the remote resources captured in a snapshot file to their state before the push
+func Rollback(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("Rolling back requires a snapshot file as argument")
+	}
+
+	file := c.Args().First()
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	snap, err := snapshot.Decode(string(content), config.NewDecrypterFromCLI(c))
+	if err != nil {
+		return fmt.Errorf("Could not read snapshot %s: %s", file, err)
+	}
+
+	if env := c.GlobalString("environment"); env != "" && snap.Environment != "" && env != snap.Environment {
+		return fmt.Errorf("Snapshot %s was taken in environment %s, not %s", file, snap.Environment, env)
+	}
+
+	log.Infof("Rolling back %d resource(s) to their state at %s", len(snap.Entries), snap.CreatedAt)
+	return snapshot.Restore(snap.Entries)
+}
@@ -0,0 +1,57 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Decrypt will print the files provided as arguments with all encrypted values decrypted,
+// or write them back in place with --in-place
+func Decrypt(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("Decrypting requires at least one file as argument")
+	}
+
+	decrypter := config.NewDecrypterFromCLI(c)
+
+	for _, file := range c.Args() {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		result, err := decryptContent(string(content), decrypter)
+		if err != nil {
+			return fmt.Errorf("Could not decrypt %s: %s", file, err)
+		}
+
+		if !c.Bool("in-place") {
+			fmt.Fprint(os.Stdout, result)
+			continue
+		}
+
+		if err := writeFile(file, result); err != nil {
+			return err
+		}
+
+		log.Infof("Decrypted %s", file)
+	}
+
+	return nil
+}
+
+// decryptContent decrypts a whole-file encrypted content, and then all encrypted values
+func decryptContent(content string, decrypter *support.Decrypter) (string, error) {
+	content, err := decrypter.DecryptFile(content)
+	if err != nil {
+		return "", err
+	}
+
+	return decrypter.DecryptValues(content)
+}
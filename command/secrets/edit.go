@@ -0,0 +1,136 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Edit will decrypt a file into a temporary file, open it in $EDITOR and encrypt it again
+// once the editor exits. Values that were not changed keep their existing ciphertext.
+func Edit(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("Editing requires exactly one file as argument")
+	}
+
+	file := c.Args().First()
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	decrypter := config.NewDecrypterFromCLI(c)
+	wholeFile := support.IsEncryptedFile(string(content))
+
+	plaintext, err := decrypter.DecryptFile(string(content))
+	if err != nil {
+		return fmt.Errorf("Could not decrypt %s: %s", file, err)
+	}
+
+	// remember the ciphertext of every value, so unchanged values can be reused
+	previous := make(map[string]string)
+	values, err := config.FindSecretValues(plaintext)
+	if err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		if !value.IsEncrypted() {
+			continue
+		}
+
+		decrypted, err := decrypter.Decrypt(value.Value)
+		if err != nil {
+			return fmt.Errorf("Could not decrypt %s line %d: %s", file, value.Pos.Line, err)
+		}
+
+		previous[decrypted] = value.Value
+	}
+
+	plaintext, err = decrypter.DecryptValues(plaintext)
+	if err != nil {
+		return err
+	}
+
+	edited, err := editInEditor(file, plaintext)
+	if err != nil {
+		return err
+	}
+
+	if edited == plaintext {
+		log.Infof("No changes made to %s", file)
+		return nil
+	}
+
+	encrypter := newEncrypterFromCLI(c)
+
+	var result string
+	if wholeFile {
+		result, err = encrypter.EncryptFile(edited)
+	} else {
+		result, _, err = encryptValues(edited, encrypter, previous)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Could not encrypt %s, your changes were not saved: %s", file, err)
+	}
+
+	if err := writeFile(file, result); err != nil {
+		return err
+	}
+
+	log.Infof("Saved %s", file)
+	return nil
+}
+
+// editInEditor writes content to a private temporary file, opens it in $EDITOR (default pico)
+// and returns the edited content. The temporary file is always removed.
+func editInEditor(file, content string) (string, error) {
+	dir, err := ioutil.TempDir("", "hashi-helper")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, filepath.Base(file))
+	if err := ioutil.WriteFile(tmp, []byte(content), 0600); err != nil {
+		return "", err
+	}
+
+	// find the editor to use from env, default to pico/nano
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "pico"
+	}
+
+	// custom flags to weird editors
+	flags := make([]string, 0)
+	switch editor {
+	case "code":
+		flags = append(flags, "-w", "-n")
+	}
+
+	cmd := exec.Command(editor, append(flags, tmp)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Editor %s failed: %s", editor, err)
+	}
+
+	edited, err := ioutil.ReadFile(tmp)
+	if err != nil {
+		return "", err
+	}
+
+	return string(edited), nil
+}
@@ -0,0 +1,104 @@
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Encrypt will encrypt all plaintext secret values in the files provided as arguments, in place
+func Encrypt(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("Encrypting requires at least one file as argument")
+	}
+
+	encrypter := newEncrypterFromCLI(c)
+
+	for _, file := range c.Args() {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		if support.IsEncryptedFile(string(content)) {
+			log.Infof("%s is already encrypted", file)
+			continue
+		}
+
+		var result string
+		if c.Bool("whole-file") {
+			result, err = encrypter.EncryptFile(string(content))
+		} else {
+			var count int
+			result, count, err = encryptValues(string(content), encrypter, nil)
+			log.Infof("Encrypted %d values in %s", count, file)
+		}
+
+		if err != nil {
+			return fmt.Errorf("Could not encrypt %s: %s", file, err)
+		}
+
+		if err := writeFile(file, result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newEncrypterFromCLI(c *cli.Context) *support.Encrypter {
+	return &support.Encrypter{
+		AgeRecipients:    c.StringSlice("age-recipient"),
+		PGPPublicKeyring: c.String("pgp-public-keyring"),
+	}
+}
+
+// encryptValues replaces all plaintext secret values in content with an ENC[...] value.
+// Plaintext found in previous, a map of plaintext to ENC[...] value, reuses the existing
+// ciphertext, so unchanged values don't show up in diffs.
+func encryptValues(content string, encrypter *support.Encrypter, previous map[string]string) (string, int, error) {
+	values, err := config.FindSecretValues(content)
+	if err != nil {
+		return "", 0, err
+	}
+
+	count := 0
+
+	// replace from the end, so the offsets of earlier values stay valid
+	for i := len(values) - 1; i >= 0; i-- {
+		value := values[i]
+		if value.IsEncrypted() {
+			continue
+		}
+
+		encrypted, ok := previous[value.Value]
+		if !ok {
+			encrypted, err = encrypter.Encrypt(value.Value)
+			if err != nil {
+				return "", 0, err
+			}
+		}
+
+		start := value.Pos.Offset
+		end := start + len(value.Text)
+		content = content[:start] + `"` + encrypted + `"` + content[end:]
+		count++
+	}
+
+	return content, count, nil
+}
+
+// writeFile replaces the content of an existing file, keeping its permissions
+func writeFile(file, content string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, []byte(content), info.Mode())
+}
@@ -0,0 +1,450 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	consul "github.com/hashicorp/consul/api"
+	multierror "github.com/hashicorp/go-multierror"
+	nomad "github.com/hashicorp/nomad/api"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+)
+
+// restorer restores entries with clients configured from the environment, e.g. VAULT_ADDR, CONSUL_HTTP_ADDR and NOMAD_ADDR
+type restorer struct {
+	consul *consul.Client
+	nomad  *nomad.Client
+	vault  map[string]*vault.Client
+}
+
+// Restore writes the prior state of all entries back, in reverse order so resources are restored
+// before the mounts and backends they live in are removed. It continues after errors, and returns all of them
+func Restore(entries []*Entry) error {
+	r := &restorer{vault: make(map[string]*vault.Client)}
+
+	var result error
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+
+		if entry.Existed {
+			log.Infof("Restoring %s", entry)
+			if len(entry.WriteOnly) > 0 {
+				log.Warnf("  Vault did not return %s before the push, the values the push wrote are kept", strings.Join(entry.WriteOnly, ", "))
+			}
+		} else {
+			log.Infof("Removing %s, it did not exist before", entry)
+		}
+
+		if err := r.restore(entry); err != nil {
+			result = multierror.Append(result, fmt.Errorf("Could not restore %s: %s", entry, err))
+		}
+	}
+
+	return result
+}
+
+func (r *restorer) restore(entry *Entry) error {
+	switch entry.Kind {
+	case KindConsulKV, KindConsulACLPolicy, KindConsulACLRole, KindConsulACLToken, KindConsulACLBindingRule,
+		KindConsulConfigEntry, KindConsulIntention, KindConsulService:
+		client, err := r.consulClient()
+		if err != nil {
+			return err
+		}
+
+		return restoreConsul(client, entry)
+	case KindNomadQuota, KindNomadNamespace, KindNomadACLPolicy:
+		client, err := r.nomadClient()
+		if err != nil {
+			return err
+		}
+
+		return restoreNomad(client, entry)
+	}
+
+	client, err := r.vaultClient(entry.Namespace)
+	if err != nil {
+		return err
+	}
+
+	switch entry.Kind {
+	case KindVaultPolicy:
+		return restoreVaultPolicy(client, entry)
+	case KindVaultMount, KindVaultAuth:
+		return restoreVaultMount(client, entry)
+	case KindVaultRemount:
+		log.Infof("Moving %s back to %s", entry.Path, entry.From)
+		return support.Remount(context.Background(), client, entry.Path, entry.From)
+	case KindVaultAudit:
+		return restoreVaultAudit(client, entry)
+	case KindVaultPath:
+		return restoreVaultPath(client, entry)
+	case KindVaultKV:
+		return restoreVaultKV(client, entry)
+	default:
+		return fmt.Errorf("Unknown snapshot entry kind %s", entry.Kind)
+	}
+}
+
+func restoreVaultPolicy(client *vault.Client, entry *Entry) error {
+	if !entry.Existed {
+		return client.Sys().DeletePolicy(entry.Path)
+	}
+
+	return client.Sys().PutPolicy(entry.Path, entry.Policy)
+}
+
+func restoreVaultMount(client *vault.Client, entry *Entry) error {
+	var list func() (map[string]*vault.MountOutput, error)
+	var disable func(path string) error
+	var tunePath string
+
+	if entry.Kind == KindVaultAuth {
+		list, disable, tunePath = client.Sys().ListAuth, client.Sys().DisableAuth, "auth/"+entry.Path
+	} else {
+		list, disable, tunePath = client.Sys().ListMounts, client.Sys().Unmount, entry.Path
+	}
+
+	existing, err := list()
+	if err != nil {
+		return err
+	}
+	_, exists := existing[strings.Trim(entry.Path, "/")+"/"]
+
+	if !entry.Existed {
+		if !exists {
+			return nil
+		}
+
+		return disable(entry.Path)
+	}
+
+	mount := entry.Mount
+	config := mountConfigInput(mount)
+
+	// tuning restores the config of a mount that still exists, a pruned mount is mounted again,
+	// but the data stored in it is gone
+	if exists {
+		return client.Sys().TuneMount(tunePath, config)
+	}
+
+	input := &vault.MountInput{
+		Type:        mount.Type,
+		Description: mount.Description,
+		Config:      config,
+		Local:       mount.Local,
+		SealWrap:    mount.SealWrap,
+		Options:     mount.Options,
+	}
+
+	if entry.Kind == KindVaultAuth {
+		return client.Sys().EnableAuthWithOptions(entry.Path, input)
+	}
+
+	return client.Sys().Mount(entry.Path, input)
+}
+
+func mountConfigInput(mount *vault.MountOutput) vault.MountConfigInput {
+	description := mount.Description
+
+	return vault.MountConfigInput{
+		Description:               &description,
+		DefaultLeaseTTL:           fmt.Sprintf("%ds", mount.Config.DefaultLeaseTTL),
+		MaxLeaseTTL:               fmt.Sprintf("%ds", mount.Config.MaxLeaseTTL),
+		ForceNoCache:              mount.Config.ForceNoCache,
+		AuditNonHMACRequestKeys:   mount.Config.AuditNonHMACRequestKeys,
+		AuditNonHMACResponseKeys:  mount.Config.AuditNonHMACResponseKeys,
+		ListingVisibility:         mount.Config.ListingVisibility,
+		PassthroughRequestHeaders: mount.Config.PassthroughRequestHeaders,
+		AllowedResponseHeaders:    mount.Config.AllowedResponseHeaders,
+		TokenType:                 mount.Config.TokenType,
+	}
+}
+
+func restoreVaultAudit(client *vault.Client, entry *Entry) error {
+	audits, err := client.Sys().ListAudit()
+	if err != nil {
+		return err
+	}
+
+	// audit devices can't be updated, only disabled and enabled again
+	if _, ok := audits[strings.Trim(entry.Path, "/")+"/"]; ok {
+		if err := client.Sys().DisableAudit(entry.Path); err != nil {
+			return err
+		}
+	}
+
+	if !entry.Existed {
+		return nil
+	}
+
+	return client.Sys().EnableAuditWithOptions(entry.Path, &vault.EnableAuditOptions{
+		Type:        entry.Audit.Type,
+		Description: entry.Audit.Description,
+		Options:     entry.Audit.Options,
+		Local:       entry.Audit.Local,
+	})
+}
+
+func restoreVaultPath(client *vault.Client, entry *Entry) error {
+	if !entry.Existed {
+		_, err := client.Logical().Delete(entry.Path)
+		return err
+	}
+
+	_, err := client.Logical().Write(entry.Path, entry.Data)
+	return err
+}
+
+func restoreVaultKV(client *vault.Client, entry *Entry) error {
+	// deleting the metadata removes all versions written by the push
+	if !entry.Existed {
+		_, err := client.Logical().Delete(entry.MetadataPath)
+		return err
+	}
+
+	if len(entry.Metadata) > 0 {
+		if _, err := client.Logical().Write(entry.MetadataPath, entry.Metadata); err != nil {
+			return err
+		}
+	}
+
+	// the latest version was deleted before the push, delete the version it wrote too
+	if entry.Data == nil {
+		_, err := client.Logical().Delete(entry.Path)
+		return err
+	}
+
+	// the restored data is written as a new version, the versions written by the push are kept
+	_, err := client.Logical().Write(entry.Path, map[string]interface{}{"data": entry.Data})
+	return err
+}
+
+func restoreConsulKV(client *consul.Client, entry *Entry) error {
+	if !entry.Existed {
+		_, err := client.KV().Delete(entry.Path, nil)
+		return err
+	}
+
+	_, err := client.KV().Put(&consul.KVPair{Key: entry.Path, Value: entry.Value, Flags: entry.Flags}, nil)
+	return err
+}
+
+func restoreConsul(client *consul.Client, entry *Entry) error {
+	switch entry.Kind {
+	case KindConsulKV:
+		return restoreConsulKV(client, entry)
+	case KindConsulACLPolicy:
+		return restoreConsulACLPolicy(client.ACL(), entry)
+	case KindConsulACLRole:
+		return restoreConsulACLRole(client.ACL(), entry)
+	case KindConsulACLToken:
+		return restoreConsulACLToken(client.ACL(), entry)
+	case KindConsulACLBindingRule:
+		return restoreConsulACLBindingRule(client.ACL(), entry)
+	case KindConsulConfigEntry:
+		return restoreConsulConfigEntry(client.ConfigEntries(), entry)
+	case KindConsulIntention:
+		return restoreConsulIntention(client.Connect(), entry)
+	case KindConsulService:
+		return restoreConsulService(client.Catalog(), entry)
+	default:
+		return fmt.Errorf("Unknown snapshot entry kind %s", entry.Kind)
+	}
+}
+
+// restoreConsulACLPolicy updates the policy by ID, a policy created by the push is looked up by name to delete it
+func restoreConsulACLPolicy(acl *consul.ACL, entry *Entry) error {
+	if entry.Existed {
+		_, _, err := acl.PolicyUpdate(entry.ConsulACLPolicy, nil)
+		return err
+	}
+
+	existing, _, err := acl.PolicyReadByName(entry.Path, nil)
+	if err != nil || existing == nil {
+		return err
+	}
+
+	_, err = acl.PolicyDelete(existing.ID, nil)
+	return err
+}
+
+func restoreConsulACLRole(acl *consul.ACL, entry *Entry) error {
+	if entry.Existed {
+		_, _, err := acl.RoleUpdate(entry.ConsulACLRole, nil)
+		return err
+	}
+
+	existing, _, err := acl.RoleReadByName(entry.Path, nil)
+	if err != nil || existing == nil {
+		return err
+	}
+
+	_, err = acl.RoleDelete(existing.ID, nil)
+	return err
+}
+
+// restoreConsulACLToken updates the token by accessor, tokens created by the push are looked up by description to delete them
+func restoreConsulACLToken(acl *consul.ACL, entry *Entry) error {
+	if !entry.Existed {
+		tokens, _, err := acl.TokenList(nil)
+		if err != nil {
+			return err
+		}
+
+		for _, token := range tokens {
+			if token.Description != entry.Path {
+				continue
+			}
+
+			if _, err := acl.TokenDelete(token.AccessorID, nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	_, _, err := acl.TokenUpdate(entry.ConsulACLToken, nil)
+	return err
+}
+
+func restoreConsulACLBindingRule(acl *consul.ACL, entry *Entry) error {
+	if !entry.Existed {
+		rules, _, err := acl.BindingRuleList(entry.ConsulACLBindingRule.AuthMethod, nil)
+		if err != nil {
+			return err
+		}
+
+		for _, rule := range rules {
+			if rule.Description != entry.Path {
+				continue
+			}
+
+			if _, err := acl.BindingRuleDelete(rule.ID, nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	_, _, err := acl.BindingRuleUpdate(entry.ConsulACLBindingRule, nil)
+	return err
+}
+
+func restoreConsulConfigEntry(entries *consul.ConfigEntries, entry *Entry) error {
+	if !entry.Existed {
+		parts := strings.SplitN(entry.Path, "/", 2)
+		_, err := entries.Delete(parts[0], parts[1], nil)
+		return err
+	}
+
+	configEntry, err := consul.DecodeConfigEntryFromJSON(entry.ConsulConfigEntry)
+	if err != nil {
+		return err
+	}
+
+	ok, _, err := entries.Set(configEntry, nil)
+	if err == nil && !ok {
+		err = fmt.Errorf("Consul did not accept the config entry")
+	}
+	return err
+}
+
+func restoreConsulIntention(connect *consul.Connect, entry *Entry) error {
+	if !entry.Existed {
+		_, err := connect.IntentionDeleteExact(entry.ConsulIntention.SourceName, entry.ConsulIntention.DestinationName, nil)
+		return err
+	}
+
+	_, err := connect.IntentionUpsert(entry.ConsulIntention, nil)
+	return err
+}
+
+func restoreConsulService(catalog *consul.Catalog, entry *Entry) error {
+	if !entry.Existed {
+		_, err := catalog.Deregister(&consul.CatalogDeregistration{Node: entry.ConsulService.Node, ServiceID: entry.ConsulService.Service.ID}, nil)
+		return err
+	}
+
+	_, err := catalog.Register(entry.ConsulService, nil)
+	return err
+}
+
+func restoreNomad(client *nomad.Client, entry *Entry) error {
+	switch entry.Kind {
+	case KindNomadQuota:
+		if !entry.Existed {
+			_, err := client.Quotas().Delete(entry.Path, nil)
+			return err
+		}
+
+		_, err := client.Quotas().Register(entry.NomadQuota, nil)
+		return err
+	case KindNomadNamespace:
+		if !entry.Existed {
+			_, err := client.Namespaces().Delete(entry.Path, nil)
+			return err
+		}
+
+		_, err := client.Namespaces().Register(entry.NomadNamespace, nil)
+		return err
+	case KindNomadACLPolicy:
+		if !entry.Existed {
+			_, err := client.ACLPolicies().Delete(entry.Path, nil)
+			return err
+		}
+
+		_, err := client.ACLPolicies().Upsert(entry.NomadACLPolicy, nil)
+		return err
+	default:
+		return fmt.Errorf("Unknown snapshot entry kind %s", entry.Kind)
+	}
+}
+
+func (r *restorer) consulClient() (*consul.Client, error) {
+	if r.consul == nil {
+		client, err := consul.NewClient(consul.DefaultConfig())
+		if err != nil {
+			return nil, err
+		}
+		r.consul = client
+	}
+
+	return r.consul, nil
+}
+
+func (r *restorer) nomadClient() (*nomad.Client, error) {
+	if r.nomad == nil {
+		client, err := nomad.NewClient(nomad.DefaultConfig())
+		if err != nil {
+			return nil, err
+		}
+		r.nomad = client
+	}
+
+	return r.nomad, nil
+}
+
+// vaultClient returns a client scoped to the Vault namespace the entry was captured in
+func (r *restorer) vaultClient(namespace string) (*vault.Client, error) {
+	if client, ok := r.vault[namespace]; ok {
+		return client, nil
+	}
+
+	client, err := vault.NewClient(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace != "" {
+		client.SetNamespace(namespace)
+	}
+
+	r.vault[namespace] = client
+	return client, nil
+}
@@ -0,0 +1,635 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+	nomad "github.com/hashicorp/nomad/api"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+const (
+	// KindVaultPolicy is a Vault ACL policy
+	KindVaultPolicy = "vault_policy"
+
+	// KindVaultMount is a Vault secret engine mount, and its tune config
+	KindVaultMount = "vault_mount"
+
+	// KindVaultAuth is a Vault auth backend, and its tune config
+	KindVaultAuth = "vault_auth"
+
+	// KindVaultRemount is a Vault mount or auth backend moved from another path
+	KindVaultRemount = "vault_remount"
+
+	// KindVaultAudit is a Vault audit device
+	KindVaultAudit = "vault_audit"
+
+	// KindVaultPath is any logical Vault path, like mount and auth config, roles and KV version 1 secrets
+	KindVaultPath = "vault_path"
+
+	// KindVaultKV is a KV version 2 secret, including its metadata
+	KindVaultKV = "vault_kv"
+
+	// KindConsulKV is a Consul KV value
+	KindConsulKV = "consul_kv"
+
+	// KindConsulACLPolicy is a Consul ACL policy
+	KindConsulACLPolicy = "consul_acl_policy"
+
+	// KindConsulACLRole is a Consul ACL role
+	KindConsulACLRole = "consul_acl_role"
+
+	// KindConsulACLToken is a Consul ACL token, the path is its description
+	KindConsulACLToken = "consul_acl_token"
+
+	// KindConsulACLBindingRule is a Consul ACL binding rule, the path is its description
+	KindConsulACLBindingRule = "consul_acl_binding_rule"
+
+	// KindConsulConfigEntry is a Consul config entry, the path is "kind/name"
+	KindConsulConfigEntry = "consul_config_entry"
+
+	// KindConsulIntention is a Consul intention, the path is "source => destination"
+	KindConsulIntention = "consul_intention"
+
+	// KindConsulService is a service registered in the Consul catalog, the path is "node/service-id"
+	KindConsulService = "consul_service"
+
+	// KindNomadQuota is a Nomad quota specification
+	KindNomadQuota = "nomad_quota"
+
+	// KindNomadNamespace is a Nomad namespace
+	KindNomadNamespace = "nomad_namespace"
+
+	// KindNomadACLPolicy is a Nomad ACL policy
+	KindNomadACLPolicy = "nomad_acl_policy"
+)
+
+// recorderKey is the key of the recorder in the app metadata
+const recorderKey = "snapshot"
+
+// kvMetadataKeys are the writable fields of KV version 2 metadata
+var kvMetadataKeys = []string{"max_versions", "cas_required", "delete_version_after", "custom_metadata"}
+
+// Entry is the remote state of a single resource before a push modified it
+type Entry struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Path      string `json:"path"`
+
+	// Existed is false if the resource was created by the push, rolling back deletes it
+	Existed bool `json:"existed"`
+
+	// Policy is the rules of a vault_policy
+	Policy string `json:"policy,omitempty"`
+
+	// Mount is the vault_mount or vault_auth backend
+	Mount *vault.MountOutput `json:"mount,omitempty"`
+
+	// From is the path a vault_remount was moved from, rolling back moves it there again
+	From string `json:"from,omitempty"`
+
+	// Audit is the vault_audit device
+	Audit *vault.Audit `json:"audit,omitempty"`
+
+	// Data is the data of a vault_path or vault_kv secret
+	Data map[string]interface{} `json:"data,omitempty"`
+
+	// WriteOnly are the keys the push wrote to a vault_path that Vault doesn't return, like database
+	// passwords. Their prior value is unknown, so rolling back can't restore them
+	WriteOnly []string `json:"write_only,omitempty"`
+
+	// MetadataPath and Metadata are the metadata of a vault_kv secret
+	MetadataPath string                 `json:"metadata_path,omitempty"`
+	Metadata     map[string]interface{} `json:"metadata,omitempty"`
+
+	// Value and Flags are the consul_kv value
+	Value []byte `json:"value,omitempty"`
+	Flags uint64 `json:"flags,omitempty"`
+
+	// ConsulACLPolicy, ConsulACLRole, ConsulACLToken and ConsulACLBindingRule are the Consul ACL objects.
+	// Objects created by the push are looked up by name or description to delete them, binding rules
+	// created by the push only have their auth method
+	ConsulACLPolicy      *consul.ACLPolicy      `json:"consul_acl_policy,omitempty"`
+	ConsulACLRole        *consul.ACLRole        `json:"consul_acl_role,omitempty"`
+	ConsulACLToken       *consul.ACLToken       `json:"consul_acl_token,omitempty"`
+	ConsulACLBindingRule *consul.ACLBindingRule `json:"consul_acl_binding_rule,omitempty"`
+
+	// ConsulConfigEntry is the consul_config_entry as returned by Consul
+	ConsulConfigEntry json.RawMessage `json:"consul_config_entry,omitempty"`
+
+	// ConsulIntention is the consul_intention, only its source and destination are set if it did not exist
+	ConsulIntention *consul.Intention `json:"consul_intention,omitempty"`
+
+	// ConsulService is the consul_service registration, only its node and service ID are set if it did not exist
+	ConsulService *consul.CatalogRegistration `json:"consul_service,omitempty"`
+
+	// NomadQuota, NomadNamespace and NomadACLPolicy are the Nomad objects
+	NomadQuota     *nomad.QuotaSpec `json:"nomad_quota,omitempty"`
+	NomadNamespace *nomad.Namespace `json:"nomad_namespace,omitempty"`
+	NomadACLPolicy *nomad.ACLPolicy `json:"nomad_acl_policy,omitempty"`
+}
+
+// String returns a readable name for the entry, like "vault_policy admin"
+func (e *Entry) String() string {
+	if e.Namespace != "" {
+		return fmt.Sprintf("%s %s (namespace %s)", e.Kind, e.Path, e.Namespace)
+	}
+
+	return fmt.Sprintf("%s %s", e.Kind, e.Path)
+}
+
+// Snapshot is the content of a snapshot file
+type Snapshot struct {
+	Environment string    `json:"environment,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Entries     []*Entry  `json:"entries"`
+}
+
+// Recorder collects the prior state of every resource modified by the pushes it's passed to.
+// All capture methods do nothing on a nil recorder
+type Recorder struct {
+	sync.Mutex
+
+	// Atomic fails a push if the state of a resource can't be captured, and makes Finish roll back a failed run
+	Atomic bool
+
+	// Dir is the directory Finish writes the snapshot file to, nothing is written if it's empty
+	Dir string
+
+	// Encrypter encrypts the snapshot file, it must have a recipient if Dir is set
+	Encrypter *support.Encrypter
+
+	// Environment is added to the snapshot file name and content
+	Environment string
+
+	entries []*Entry
+	seen    map[string]bool
+	start   time.Time
+}
+
+// NewRecorder returns a recorder that only collects entries in memory, until Dir is set
+func NewRecorder() *Recorder {
+	return &Recorder{Encrypter: &support.Encrypter{}, seen: make(map[string]bool), start: time.Now()}
+}
+
+// Configure returns a new recorder for this run from the global --atomic, --snapshot-dir and snapshot
+// encryption flags, FromCLI returns it to the push commands
+func Configure(c *cli.Context) *Recorder {
+	rec := NewRecorder()
+	rec.Atomic = c.GlobalBool("atomic")
+	rec.Dir = c.GlobalString("snapshot-dir")
+	rec.Environment = c.GlobalString("environment")
+	rec.Encrypter = &support.Encrypter{
+		AgeRecipients:    c.GlobalStringSlice("snapshot-age-recipient"),
+		PGPPublicKeyring: c.GlobalString("snapshot-pgp-public-keyring"),
+	}
+
+	if c.App.Metadata == nil {
+		c.App.Metadata = make(map[string]interface{})
+	}
+	c.App.Metadata[recorderKey] = rec
+
+	return rec
+}
+
+// FromCLI returns the recorder set up by Configure, nil if there is none
+func FromCLI(c *cli.Context) *Recorder {
+	rec, _ := c.App.Metadata[recorderKey].(*Recorder)
+	return rec
+}
+
+// Entries returns the entries captured so far, in capture order
+func (rec *Recorder) Entries() []*Entry {
+	if rec == nil {
+		return nil
+	}
+
+	rec.Lock()
+	defer rec.Unlock()
+
+	return append([]*Entry{}, rec.entries...)
+}
+
+// capture adds the entry returned by read, unless the resource was already captured by this recorder,
+// as only the state before the first write can be restored. Failing to read the remote state
+// only fails the push with Atomic, otherwise the resource is pushed without a snapshot
+func (rec *Recorder) capture(kind, namespace, path string, read func() (*Entry, error)) error {
+	if rec == nil {
+		return nil
+	}
+
+	// without a recipient the snapshot file isn't written, an atomic push fails before its first
+	// write instead of finding out at the end that its snapshot is lost
+	if rec.Atomic && rec.Dir != "" && !rec.canEncrypt() {
+		return fmt.Errorf("Can't snapshot %s %s, set --snapshot-age-recipient or --snapshot-pgp-public-keyring to encrypt the snapshot for, or disable snapshot files with --snapshot-dir ''", kind, path)
+	}
+
+	key := kind + "|" + namespace + "|" + path
+
+	rec.Lock()
+	seen := rec.seen[key]
+	rec.seen[key] = true
+	rec.Unlock()
+
+	if seen {
+		return nil
+	}
+
+	entry, err := read()
+	if err != nil {
+		err = fmt.Errorf("Could not snapshot %s %s: %s", kind, path, err)
+		if rec.Atomic {
+			return err
+		}
+
+		log.Warnf("%s, it can't be rolled back", err)
+		return nil
+	}
+
+	entry.Kind = kind
+	entry.Namespace = namespace
+	entry.Path = path
+
+	rec.Lock()
+	rec.entries = append(rec.entries, entry)
+	rec.Unlock()
+
+	return nil
+}
+
+// CaptureVaultPolicy captures the Vault policy name before it's written or deleted
+func (rec *Recorder) CaptureVaultPolicy(client *vault.Client, name string) error {
+	return rec.capture(KindVaultPolicy, namespaceOf(client), name, func() (*Entry, error) {
+		rules, err := client.Sys().GetPolicy(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Entry{Existed: rules != "", Policy: rules}, nil
+	})
+}
+
+// CaptureVaultMount captures the Vault mount at path before it's mounted, tuned or unmounted
+func (rec *Recorder) CaptureVaultMount(client *vault.Client, path string) error {
+	return rec.capture(KindVaultMount, namespaceOf(client), path, func() (*Entry, error) {
+		mounts, err := client.Sys().ListMounts()
+		if err != nil {
+			return nil, err
+		}
+
+		mount, ok := mounts[strings.Trim(path, "/")+"/"]
+		return &Entry{Existed: ok, Mount: mount}, nil
+	})
+}
+
+// CaptureVaultAuth captures the Vault auth backend at path before it's enabled, tuned or disabled
+func (rec *Recorder) CaptureVaultAuth(client *vault.Client, path string) error {
+	return rec.capture(KindVaultAuth, namespaceOf(client), path, func() (*Entry, error) {
+		auths, err := client.Sys().ListAuth()
+		if err != nil {
+			return nil, err
+		}
+
+		auth, ok := auths[strings.Trim(path, "/")+"/"]
+		return &Entry{Existed: ok, Mount: auth}, nil
+	})
+}
+
+// CaptureVaultRemount records that the Vault mount at from is about to be moved to to. Auth backends are prefixed with "auth/"
+func (rec *Recorder) CaptureVaultRemount(client *vault.Client, from, to string) error {
+	return rec.capture(KindVaultRemount, namespaceOf(client), to, func() (*Entry, error) {
+		return &Entry{Existed: true, From: from}, nil
+	})
+}
+
+// CaptureVaultAudit captures the Vault audit device at path before it's enabled or disabled
+func (rec *Recorder) CaptureVaultAudit(client *vault.Client, path string) error {
+	return rec.capture(KindVaultAudit, namespaceOf(client), path, func() (*Entry, error) {
+		audits, err := client.Sys().ListAudit()
+		if err != nil {
+			return nil, err
+		}
+
+		audit, ok := audits[strings.Trim(path, "/")+"/"]
+		return &Entry{Existed: ok, Audit: audit}, nil
+	})
+}
+
+// CaptureVaultPath captures the logical Vault path before data is written to it
+func (rec *Recorder) CaptureVaultPath(client *vault.Client, path string, data map[string]interface{}) error {
+	return rec.capture(KindVaultPath, namespaceOf(client), path, func() (*Entry, error) {
+		secret, err := client.Logical().Read(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if secret == nil {
+			return &Entry{}, nil
+		}
+
+		entry := &Entry{Existed: true, Data: secret.Data, WriteOnly: writeOnlyKeys(data, secret.Data)}
+		if len(entry.WriteOnly) > 0 {
+			log.Debugf("Vault doesn't return %s of %s, rolling it back can't restore them", strings.Join(entry.WriteOnly, ", "), path)
+		}
+
+		return entry, nil
+	})
+}
+
+// writeOnlyKeys returns the sorted keys of written that are missing from read
+func writeOnlyKeys(written, read map[string]interface{}) []string {
+	var keys []string
+	for key := range written {
+		if _, ok := read[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// CaptureVaultPathCreated records that the logical Vault path did not exist before the push created it,
+// like a new namespace, or a path that is only known after it was written, like the ID of a new identity group alias
+func (rec *Recorder) CaptureVaultPathCreated(client *vault.Client, path string) error {
+	return rec.capture(KindVaultPath, namespaceOf(client), path, func() (*Entry, error) {
+		return &Entry{}, nil
+	})
+}
+
+// CaptureVaultKV captures the KV version 2 secret at dataPath, and its metadata at metadataPath, before it's written
+func (rec *Recorder) CaptureVaultKV(client *vault.Client, dataPath, metadataPath string) error {
+	return rec.capture(KindVaultKV, namespaceOf(client), dataPath, func() (*Entry, error) {
+		metadata, err := client.Logical().Read(metadataPath)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &Entry{MetadataPath: metadataPath}
+		if metadata == nil {
+			return entry, nil
+		}
+
+		entry.Existed = true
+		entry.Metadata = make(map[string]interface{})
+		for _, key := range kvMetadataKeys {
+			if value, ok := metadata.Data[key]; ok {
+				entry.Metadata[key] = value
+			}
+		}
+
+		secret, err := client.Logical().Read(dataPath)
+		if err != nil {
+			return nil, err
+		}
+
+		// the latest version is deleted or destroyed, rolling back deletes it again
+		if secret != nil {
+			entry.Data, _ = secret.Data["data"].(map[string]interface{})
+		}
+
+		return entry, nil
+	})
+}
+
+// CaptureConsulKV captures the Consul KV key before it's written
+func (rec *Recorder) CaptureConsulKV(client *consul.Client, key string) error {
+	return rec.capture(KindConsulKV, "", key, func() (*Entry, error) {
+		pair, _, err := client.KV().Get(key, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if pair == nil {
+			return &Entry{}, nil
+		}
+
+		return &Entry{Existed: true, Value: pair.Value, Flags: pair.Flags}, nil
+	})
+}
+
+// CaptureConsulACLPolicy records the Consul ACL policy name before it's written, existing is nil if it doesn't exist yet
+func (rec *Recorder) CaptureConsulACLPolicy(name string, existing *consul.ACLPolicy) error {
+	return rec.capture(KindConsulACLPolicy, "", name, func() (*Entry, error) {
+		return &Entry{Existed: existing != nil, ConsulACLPolicy: existing}, nil
+	})
+}
+
+// CaptureConsulACLRole records the Consul ACL role name before it's written, existing is nil if it doesn't exist yet
+func (rec *Recorder) CaptureConsulACLRole(name string, existing *consul.ACLRole) error {
+	return rec.capture(KindConsulACLRole, "", name, func() (*Entry, error) {
+		return &Entry{Existed: existing != nil, ConsulACLRole: existing}, nil
+	})
+}
+
+// CaptureConsulACLToken records the Consul ACL token with the description name before it's written, existing is nil
+// if it doesn't exist yet. The secret is never captured
+func (rec *Recorder) CaptureConsulACLToken(name string, existing *consul.ACLTokenListEntry) error {
+	return rec.capture(KindConsulACLToken, "", name, func() (*Entry, error) {
+		if existing == nil {
+			return &Entry{}, nil
+		}
+
+		return &Entry{Existed: true, ConsulACLToken: &consul.ACLToken{
+			AccessorID:        existing.AccessorID,
+			Description:       existing.Description,
+			Policies:          existing.Policies,
+			Roles:             existing.Roles,
+			ServiceIdentities: existing.ServiceIdentities,
+			NodeIdentities:    existing.NodeIdentities,
+			Local:             existing.Local,
+		}}, nil
+	})
+}
+
+// CaptureConsulACLBindingRule records the Consul ACL binding rule with the description name of authMethod before
+// it's written, existing is nil if it doesn't exist yet
+func (rec *Recorder) CaptureConsulACLBindingRule(name, authMethod string, existing *consul.ACLBindingRule) error {
+	return rec.capture(KindConsulACLBindingRule, "", name, func() (*Entry, error) {
+		if existing == nil {
+			return &Entry{ConsulACLBindingRule: &consul.ACLBindingRule{Description: name, AuthMethod: authMethod}}, nil
+		}
+
+		return &Entry{Existed: true, ConsulACLBindingRule: existing}, nil
+	})
+}
+
+// CaptureConsulConfigEntry captures the Consul config entry before it's written
+func (rec *Recorder) CaptureConsulConfigEntry(client *consul.Client, kind, name string) error {
+	return rec.capture(KindConsulConfigEntry, "", kind+"/"+name, func() (*Entry, error) {
+		existing, _, err := client.ConfigEntries().Get(kind, name, nil)
+		if err != nil {
+			var statusErr consul.StatusError
+			if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+				return &Entry{}, nil
+			}
+
+			return nil, err
+		}
+
+		b, err := json.Marshal(existing)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Entry{Existed: true, ConsulConfigEntry: b}, nil
+	})
+}
+
+// CaptureConsulIntention records the Consul intention before it's written, existing is nil if it doesn't exist yet
+func (rec *Recorder) CaptureConsulIntention(source, destination string, existing *consul.Intention) error {
+	return rec.capture(KindConsulIntention, "", source+" => "+destination, func() (*Entry, error) {
+		if existing == nil {
+			return &Entry{ConsulIntention: &consul.Intention{SourceName: source, DestinationName: destination}}, nil
+		}
+
+		return &Entry{Existed: true, ConsulIntention: existing}, nil
+	})
+}
+
+// CaptureConsulService captures the service serviceID registered on node in the Consul catalog before it's registered again
+func (rec *Recorder) CaptureConsulService(client *consul.Client, node, serviceID string) error {
+	return rec.capture(KindConsulService, "", node+"/"+serviceID, func() (*Entry, error) {
+		registration := &consul.CatalogRegistration{Node: node, Service: &consul.AgentService{ID: serviceID}}
+
+		existing, _, err := client.Catalog().Node(node, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if existing == nil || existing.Services[serviceID] == nil {
+			return &Entry{ConsulService: registration}, nil
+		}
+
+		registration.Address = existing.Node.Address
+		registration.Service = existing.Services[serviceID]
+		return &Entry{Existed: true, ConsulService: registration}, nil
+	})
+}
+
+// CaptureNomadQuota records the Nomad quota name before it's written, existing is nil if it doesn't exist yet
+func (rec *Recorder) CaptureNomadQuota(name string, existing *nomad.QuotaSpec) error {
+	return rec.capture(KindNomadQuota, "", name, func() (*Entry, error) {
+		return &Entry{Existed: existing != nil, NomadQuota: existing}, nil
+	})
+}
+
+// CaptureNomadNamespace records the Nomad namespace name before it's written, existing is nil if it doesn't exist yet
+func (rec *Recorder) CaptureNomadNamespace(name string, existing *nomad.Namespace) error {
+	return rec.capture(KindNomadNamespace, "", name, func() (*Entry, error) {
+		return &Entry{Existed: existing != nil, NomadNamespace: existing}, nil
+	})
+}
+
+// CaptureNomadACLPolicy records the Nomad ACL policy name before it's written, existing is nil if it doesn't exist yet
+func (rec *Recorder) CaptureNomadACLPolicy(name string, existing *nomad.ACLPolicy) error {
+	return rec.capture(KindNomadACLPolicy, "", name, func() (*Entry, error) {
+		return &Entry{Existed: existing != nil, NomadACLPolicy: existing}, nil
+	})
+}
+
+// Finish rolls back all captured entries if the run failed with Atomic, and writes the snapshot
+// file if anything was captured. The snapshot is written even after a rollback, as rolling back
+// can fail too
+func (rec *Recorder) Finish(failed bool) error {
+	entries := rec.Entries()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var result error
+	if failed && rec.Atomic {
+		log.Warnf("Push failed, rolling back %d resource(s)", len(entries))
+		result = Restore(entries)
+	}
+
+	file, err := rec.write(entries)
+	if err != nil {
+		log.Error(err)
+	} else if file != "" {
+		log.Infof("Wrote snapshot of %d resource(s) to %s, undo the push with: hashi-helper rollback %s", len(entries), file, file)
+	}
+
+	return result
+}
+
+// write encrypts the entries into a new file in the snapshot directory, and returns the file name.
+// Snapshots contain secrets, so nothing is written without a recipient to encrypt it for
+func (rec *Recorder) write(entries []*Entry) (string, error) {
+	if rec.Dir == "" {
+		return "", nil
+	}
+
+	if !rec.canEncrypt() {
+		log.Warn("Not writing a snapshot, no --snapshot-age-recipient or --snapshot-pgp-public-keyring configured")
+		return "", nil
+	}
+
+	content, err := Encode(&Snapshot{Environment: rec.Environment, CreatedAt: rec.start.UTC(), Entries: entries}, rec.Encrypter)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(rec.Dir, 0700); err != nil {
+		return "", err
+	}
+
+	name := rec.start.UTC().Format("20060102T150405Z")
+	if rec.Environment != "" {
+		name += "-" + rec.Environment
+	}
+
+	file := filepath.Join(rec.Dir, name+".snapshot")
+	return file, ioutil.WriteFile(file, []byte(content), 0600)
+}
+
+// canEncrypt returns true if the recorder has a recipient to encrypt the snapshot file for
+func (rec *Recorder) canEncrypt() bool {
+	return len(rec.Encrypter.AgeRecipients) > 0 || rec.Encrypter.PGPPublicKeyring != ""
+}
+
+// Encode returns the snapshot as an armored message encrypted with encrypter
+func Encode(snapshot *Snapshot, encrypter *support.Encrypter) (string, error) {
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return encrypter.EncryptFile(string(b))
+}
+
+// Decode decrypts and parses the content of a snapshot file
+func Decode(content string, decrypter *support.Decrypter) (*Snapshot, error) {
+	if !support.IsEncryptedFile(content) {
+		return nil, fmt.Errorf("Not a snapshot, expected an age or OpenPGP encrypted file")
+	}
+
+	plaintext, err := decrypter.DecryptFile(content)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal([]byte(plaintext), snapshot); err != nil {
+		return nil, fmt.Errorf("Could not parse snapshot: %s", err)
+	}
+
+	return snapshot, nil
+}
+
+func namespaceOf(client *vault.Client) string {
+	return client.Headers().Get("X-Vault-Namespace")
+}
@@ -0,0 +1,193 @@
+package snapshot
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	consul "github.com/hashicorp/consul/api"
+	nomad "github.com/hashicorp/nomad/api"
+	vault "github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/support"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder_capture(t *testing.T) {
+	tests := []struct {
+		name      string
+		atomic    bool
+		dir       string
+		readErr   error
+		expectErr bool
+		entries   int
+	}{
+		{
+			name:    "first state wins",
+			entries: 1,
+		},
+		{
+			name:    "read error is a warning",
+			readErr: errors.New("permission denied"),
+			entries: 0,
+		},
+		{
+			name:      "read error fails atomic push",
+			atomic:    true,
+			readErr:   errors.New("permission denied"),
+			expectErr: true,
+			entries:   0,
+		},
+		{
+			name:    "snapshot dir without recipient",
+			dir:     "snapshots",
+			entries: 1,
+		},
+		{
+			name:      "snapshot dir without recipient fails atomic push",
+			atomic:    true,
+			dir:       "snapshots",
+			expectErr: true,
+			entries:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := NewRecorder()
+			rec.Atomic = tt.atomic
+			rec.Dir = tt.dir
+
+			for _, value := range []string{"before", "after"} {
+				value := value
+				err := rec.capture(KindVaultPolicy, "team-a", "admin", func() (*Entry, error) {
+					if tt.readErr != nil {
+						return nil, tt.readErr
+					}
+					return &Entry{Existed: true, Policy: value}, nil
+				})
+
+				if tt.expectErr && (value == "before" || tt.dir != "") {
+					require.Error(t, err)
+				} else {
+					require.NoError(t, err)
+				}
+			}
+
+			require.Len(t, rec.entries, tt.entries)
+			if tt.entries > 0 {
+				require.Equal(t, &Entry{Kind: KindVaultPolicy, Namespace: "team-a", Path: "admin", Existed: true, Policy: "before"}, rec.entries[0])
+			}
+		})
+	}
+}
+
+func TestRecorder_perPush(t *testing.T) {
+	capture := func(rec *Recorder) error {
+		return rec.capture(KindConsulKV, "", "api/config", func() (*Entry, error) {
+			return &Entry{}, nil
+		})
+	}
+
+	// every push gets its own recorder, so pushing the same resource again is captured again
+	for i := 0; i < 2; i++ {
+		rec := NewRecorder()
+		require.NoError(t, capture(rec))
+		require.Len(t, rec.Entries(), 1)
+	}
+
+	var rec *Recorder
+	require.NoError(t, capture(rec))
+	require.Empty(t, rec.Entries())
+	require.NoError(t, rec.Finish(true))
+}
+
+func TestWriteOnlyKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		written map[string]interface{}
+		read    map[string]interface{}
+		expect  []string
+	}{
+		{
+			name:    "all returned",
+			written: map[string]interface{}{"ttl": "1h"},
+			read:    map[string]interface{}{"ttl": 3600, "max_ttl": 0},
+		},
+		{
+			name:    "database password",
+			written: map[string]interface{}{"username": "vault", "password": "hunter2", "connection_url": "postgres://"},
+			read:    map[string]interface{}{"username": "vault", "connection_url": "postgres://"},
+			expect:  []string{"password"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expect, writeOnlyKeys(tt.written, tt.read))
+		})
+	}
+}
+
+func TestRecorder_write(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	identityFile := filepath.Join(dir, "keys.txt")
+	require.NoError(t, ioutil.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600))
+
+	rec := NewRecorder()
+	rec.Dir = filepath.Join(dir, "snapshots")
+	rec.Environment = "production"
+	rec.start = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec.Encrypter = &support.Encrypter{AgeRecipients: []string{identity.Recipient().String()}}
+
+	entries := []*Entry{
+		{Kind: KindVaultMount, Path: "db", Existed: true, Mount: &vault.MountOutput{Type: "database", Config: vault.MountConfigOutput{DefaultLeaseTTL: 3600}}},
+		{Kind: KindVaultKV, Path: "secret/data/api/TOKEN", MetadataPath: "secret/metadata/api/TOKEN", Existed: true, Data: map[string]interface{}{"value": "hunter2"}},
+		{Kind: KindConsulKV, Path: "api/config", Value: []byte{0, 1, 2}, Flags: 42},
+		{Kind: KindConsulConfigEntry, Path: "service-defaults/api", Existed: true, ConsulConfigEntry: []byte(`{"Kind":"service-defaults","Name":"api","Protocol":"http"}`)},
+		{Kind: KindNomadNamespace, Path: "api", Existed: true, NomadNamespace: &nomad.Namespace{Name: "api", Quota: "small"}},
+	}
+
+	file, err := rec.write(entries)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(dir, "snapshots", "20200102T030405Z-production.snapshot"), file)
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	content, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	require.NotContains(t, string(content), "hunter2")
+
+	snapshot, err := Decode(string(content), &support.Decrypter{AgeIdentityFile: identityFile})
+	require.NoError(t, err)
+	require.Equal(t, "production", snapshot.Environment)
+	require.Equal(t, rec.start, snapshot.CreatedAt)
+
+	// config entries are kept as returned by Consul, only their indentation changes
+	configEntry, err := consul.DecodeConfigEntryFromJSON(snapshot.Entries[3].ConsulConfigEntry)
+	require.NoError(t, err)
+	require.Equal(t, &consul.ServiceConfigEntry{Kind: "service-defaults", Name: "api", Protocol: "http"}, configEntry)
+	require.JSONEq(t, string(entries[3].ConsulConfigEntry), string(snapshot.Entries[3].ConsulConfigEntry))
+
+	snapshot.Entries[3].ConsulConfigEntry = entries[3].ConsulConfigEntry
+	require.Equal(t, entries, snapshot.Entries)
+
+	// snapshots are never written or read in plaintext
+	rec.Dir = filepath.Join(dir, "plaintext")
+	rec.Encrypter = &support.Encrypter{}
+	file, err = rec.write(entries)
+	require.NoError(t, err)
+	require.Empty(t, file)
+	require.NoDirExists(t, rec.Dir)
+
+	_, err = Decode(`{"entries": []}`, &support.Decrypter{})
+	require.Error(t, err)
+}
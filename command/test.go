@@ -0,0 +1,158 @@
+package command
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/seatgeek/hashi-helper/config"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Test runs all policy_test{} stanza against the local policies
+func Test(c *cli.Context) error {
+	cfg, err := config.Load(config.OptionsFromCLI(c))
+	if err != nil {
+		return err
+	}
+
+	results := cfg.RunPolicyTests()
+	printTestResults(os.Stdout, results)
+
+	if file := c.String("junit-file"); file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := writeJUnit(f, results); err != nil {
+			return err
+		}
+	}
+
+	for _, result := range results {
+		if result.Error != nil || result.Failures() > 0 {
+			return cli.NewExitError("", 1)
+		}
+	}
+
+	return nil
+}
+
+func printTestResults(w io.Writer, results []*config.PolicyTestResult) {
+	cases, failures := 0, 0
+
+	for _, result := range results {
+		test := result.Test
+		if result.Error != nil {
+			failures++
+			fmt.Fprintf(w, "ERROR %s (%s): %s\n", test.Name, test.Source, result.Error)
+			continue
+		}
+
+		for _, tc := range result.Cases {
+			cases++
+			if tc.Passed() {
+				continue
+			}
+
+			failures++
+			fmt.Fprintf(w, "FAIL %s: %s (%s)\n", test.Name, tc.Name(), tc.Source)
+			fmt.Fprintln(w, tc.Result.Explain())
+		}
+	}
+
+	fmt.Fprintf(w, "%d test(s), %d check(s), %d failure(s)\n", len(results), cases, failures)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Errors   int               `xml:"errors,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	File     string           `xml:"file,attr,omitempty"`
+	Cases    []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// writeJUnit writes the results as JUnit XML, one test suite per policy_test{}
+func writeJUnit(w io.Writer, results []*config.PolicyTestResult) error {
+	report := &junitTestSuites{}
+
+	for _, result := range results {
+		test := result.Test
+		suite := &junitTestSuite{Name: test.Name, File: test.Source.File}
+		if test.Environment != nil {
+			suite.Name = test.Environment.Name + "/" + test.Name
+		}
+
+		if result.Error != nil {
+			suite.Errors++
+			suite.Cases = append(suite.Cases, &junitTestCase{
+				Name:      test.Name,
+				ClassName: suite.Name,
+				File:      test.Source.File,
+				Line:      test.Source.Line,
+				Error:     &junitMessage{Message: result.Error.Error()},
+			})
+		}
+
+		for _, tc := range result.Cases {
+			junitCase := &junitTestCase{
+				Name:      tc.Name(),
+				ClassName: suite.Name,
+				File:      tc.Source.File,
+				Line:      tc.Source.Line,
+			}
+
+			if !tc.Passed() {
+				suite.Failures++
+				junitCase.Failure = &junitMessage{Message: tc.Name() + " failed", Body: tc.Result.Explain()}
+			}
+
+			suite.Cases = append(suite.Cases, junitCase)
+		}
+
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,158 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/seatgeek/hashi-helper/config"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// Validate ...
+func Validate(c *cli.Context) error {
+	rules, err := validationRules(c.StringSlice("enable-rule"), c.StringSlice("disable-rule"))
+	if err != nil {
+		return err
+	}
+
+	// config.Load rather than NewConfigFromCLI, which exits in --lint mode
+	cfg, err := config.Load(config.OptionsFromCLI(c))
+	if err != nil {
+		return err
+	}
+
+	findings := cfg.Validate(rules)
+
+	switch format := c.String("format"); format {
+	case "", "text":
+		printFindings(os.Stdout, findings)
+	case "json":
+		err = writeIndentedJSON(os.Stdout, findings)
+	case "sarif":
+		err = writeIndentedJSON(os.Stdout, sarifReport(rules, findings))
+	default:
+		err = fmt.Errorf("Invalid --format %s, must be text, json or sarif", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, finding := range findings {
+		if finding.Severity == config.SeverityError {
+			return cli.NewExitError("", 1)
+		}
+	}
+
+	return nil
+}
+
+// validationRules returns the enabled rules, all rules are enabled unless some are explicitly enabled
+func validationRules(enabled, disabled []string) ([]*config.ValidationRule, error) {
+	for _, name := range append(append([]string{}, enabled...), disabled...) {
+		if config.FindValidationRule(name) == nil {
+			return nil, fmt.Errorf("Unknown validation rule %s", name)
+		}
+	}
+
+	rules := make([]*config.ValidationRule, 0)
+	for _, rule := range config.ValidationRules {
+		if len(enabled) > 0 && !contains(enabled, rule.Name) {
+			continue
+		}
+
+		if contains(disabled, rule.Name) {
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// ValidationRulesUsage describes all rules, for the CLI help
+func ValidationRulesUsage() string {
+	lines := make([]string, 0, len(config.ValidationRules))
+	for _, rule := range config.ValidationRules {
+		lines = append(lines, fmt.Sprintf("  %s (%s): %s", rule.Name, rule.Severity, rule.Description))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func printFindings(w io.Writer, findings []*config.Finding) {
+	for _, finding := range findings {
+		fmt.Fprintf(w, "%s: %s: %s [%s]\n", finding.Source, finding.Severity, finding.Message, finding.Rule)
+	}
+
+	fmt.Fprintf(w, "%d problem(s) found\n", len(findings))
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// sarifReport returns the findings as a SARIF 2.1.0 log, understood by most CI code scanning annotations
+func sarifReport(rules []*config.ValidationRule, findings []*config.Finding) map[string]interface{} {
+	sarifRules := make([]map[string]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		sarifRules = append(sarifRules, map[string]interface{}{
+			"id":                   rule.Name,
+			"shortDescription":     map[string]string{"text": rule.Description},
+			"defaultConfiguration": map[string]string{"level": rule.Severity},
+		})
+	}
+
+	results := make([]map[string]interface{}, 0, len(findings))
+	for _, finding := range findings {
+		results = append(results, map[string]interface{}{
+			"ruleId":  finding.Rule,
+			"level":   finding.Severity,
+			"message": map[string]string{"text": finding.Message},
+			"locations": []map[string]interface{}{
+				{
+					"physicalLocation": map[string]interface{}{
+						"artifactLocation": map[string]string{"uri": finding.Source.File},
+						"region":           map[string]int{"startLine": finding.Source.Line},
+					},
+				},
+			},
+		})
+	}
+
+	return map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]interface{}{
+			{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":           "hashi-helper",
+						"informationUri": "https://github.com/seatgeek/hashi-helper",
+						"rules":          sarifRules,
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
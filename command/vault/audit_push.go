@@ -1,10 +1,13 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	"github.com/seatgeek/hashi-helper/command/report"
 	"github.com/seatgeek/hashi-helper/config"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
@@ -22,20 +25,24 @@ func AuditPush(c *cli.Context) error {
 
 // AuditPushWithConfig ...
 func AuditPushWithConfig(c *cli.Context, config *config.Config) error {
+	return pushWithCLI(c, config, PushAudit)
+}
+
+// PushAudit will create, update and optionally prune the audit devices in all Vault namespaces
+func PushAudit(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
 	log.Info("Pushing Vault Audit")
 
-	env := c.GlobalString("environment")
-	if env == "" {
-		return fmt.Errorf("Pushing auth backends require a 'environment' value (--environment or ENV[ENVIRONMENT])")
+	if err := checkPushConfig(config, opts, "Pushing audit devices"); err != nil {
+		return err
 	}
 
-	if !config.Environments.Contains(env) {
-		return fmt.Errorf("Could not find any environment with name %s in configuration", env)
-	}
+	return forEachVaultNamespace(ctx, client, config, opts, pushNamespaceAudit)
+}
 
-	client, err := api.NewClient(nil)
-	if err != nil {
-		return err
+// pushNamespaceAudit writes the audit devices of a single Vault namespace
+func pushNamespaceAudit(ctx context.Context, client *api.Client, config *config.Config, opts PushOptions) error {
+	if skipNamespacedAudit(client, config) {
+		return nil
 	}
 
 	audits, err := client.Sys().ListAudit()
@@ -43,29 +50,151 @@ func AuditPushWithConfig(c *cli.Context, config *config.Config) error {
 		return err
 	}
 
+	exec := opts.executor()
+
 	for _, audit := range config.VaultAudits {
-		log.Printf("  Writing audit path: %s", audit.Path)
+		if audit.IsPlaceholder() {
+			log.Debugf("  Audit path %s is a prevent_destroy placeholder, skipping", audit.Path)
+			continue
+		}
 
-		path := fmt.Sprintf("/sys/audit/%s", audit.Path)
-		if _, ok := audits[audit.Path+"/"]; ok {
-			s, err := client.Logical().Delete(path)
-			if err != nil {
-				log.Fatal(err)
-			}
+		// updating an audit device requires disabling it first
+		remote := audits[audit.Path+"/"]
+		if remote != nil && audit.PreventDestroy {
+			log.Warnf("  Audit path %s already exist and has prevent_destroy, not recreating it", audit.Path)
+			startRecord(client, "vault_audit", audit.Path).Done(report.ActionUnchanged)
+			continue
+		}
 
-			// Give Vault a little bit of time to complete the DELETE operation above
-			time.Sleep(1 * time.Second)
+		audit := audit
+		exec.Add("vault_audit", audit.Path, func() error {
+			return writeAudit(client, audit, remote, opts)
+		})
+	}
 
-			printRemoteSecretWarnings(s)
+	failures := opts.failures()
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	if !opts.Prune {
+		return failures.Err()
+	}
+
+	names := auditsToPrune(audits, config)
+	if !confirmPrune(opts, "audit devices", names) {
+		return failures.Err()
+	}
+
+	exec = opts.executor()
+	for _, name := range names {
+		name := name
+		exec.Add("vault_audit", name, func() error {
+			log.Printf("  Disabling audit path: %s", name)
+			return disableAudit(client, name, opts)
+		})
+	}
+
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	return failures.Err()
+}
+
+// writeAudit enables audit, or recreates it if it differs from remote. With Force it's always recreated
+func writeAudit(client *api.Client, audit *config.Audit, remote *api.Audit, opts PushOptions) error {
+	r := startRecord(client, "vault_audit", audit.Path)
+
+	if remote != nil && !opts.Force {
+		fields := diffAudit(remote, audit)
+		if len(fields) == 0 {
+			log.Debugf("  Audit path %s is up to date", audit.Path)
+			r.Done(report.ActionUnchanged)
+			return nil
 		}
 
-		s, err := client.Logical().Write(path, audit.ToMap())
+		for _, field := range fields {
+			log.Debugf("  Audit path %s: %s changed", audit.Path, field.Key)
+		}
+	}
+
+	log.Printf("  Writing audit path: %s", audit.Path)
+
+	if err := opts.Snapshot.CaptureVaultAudit(client, audit.Path); err != nil {
+		return r.Fail(err)
+	}
+
+	path := fmt.Sprintf("/sys/audit/%s", audit.Path)
+	if remote != nil {
+		log.Warnf("  Audit path %s is disabled until it's recreated", audit.Path)
+
+		s, err := client.Logical().Delete(path)
 		if err != nil {
-			log.Fatal(err)
+			return r.Fail(err)
 		}
 
-		printRemoteSecretWarnings(s)
+		// Give Vault a little bit of time to complete the DELETE operation above
+		time.Sleep(1 * time.Second)
+
+		printRemoteSecretWarnings(s, r)
+	}
+
+	s, err := client.Logical().Write(path, audit.ToMap())
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	printRemoteSecretWarnings(s, r)
+
+	if remote != nil {
+		r.Done(report.ActionUpdated)
+	} else {
+		r.Done(report.ActionCreated)
+	}
+
+	return nil
+}
+
+// diffAudit compares a remote audit device with config, all options are compared as Vault
+// returns them exactly as they were written
+func diffAudit(remote *api.Audit, audit *config.Audit) []plan.FieldChange {
+	remoteData := map[string]interface{}{
+		"description": remote.Description,
+		"type":        remote.Type,
+		"local":       remote.Local,
+	}
+
+	desired := map[string]interface{}{
+		"description": audit.Description,
+		"type":        audit.Type,
+		"local":       audit.Local,
+	}
+
+	remoteOptions := make(map[string]interface{}, len(remote.Options))
+	for k, v := range remote.Options {
+		remoteOptions[k] = v
+	}
+
+	fields := plan.DiffData(remoteData, desired, false)
+	for _, field := range plan.DiffDataStrict(remoteOptions, audit.Options, false) {
+		field.Key = "options." + field.Key
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+func disableAudit(client *api.Client, name string, opts PushOptions) error {
+	r := startRecord(client, "vault_audit", name)
+	if err := opts.Snapshot.CaptureVaultAudit(client, name); err != nil {
+		return r.Fail(err)
+	}
+
+	if err := client.Sys().DisableAudit(name); err != nil {
+		return r.Fail(err)
 	}
 
+	r.Done(report.ActionDeleted)
 	return nil
 }
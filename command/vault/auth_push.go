@@ -1,11 +1,15 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/executor"
+	"github.com/seatgeek/hashi-helper/command/report"
 	"github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
 )
@@ -22,21 +26,22 @@ func AuthPush(c *cli.Context) error {
 
 // AuthPushWithConfig ...
 func AuthPushWithConfig(c *cli.Context, config *config.Config) error {
+	return pushWithCLI(c, config, PushAuth)
+}
+
+// PushAuth will create, update and optionally prune the auth backends in all Vault namespaces
+func PushAuth(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
 	log.Info("Pushing Vault Auth")
 
-	env := c.GlobalString("environment")
-	if env == "" {
-		return fmt.Errorf("Pushing auth backends require a 'environment' value (--environment or ENV[ENVIRONMENT])")
+	if err := checkPushConfig(config, opts, "Pushing auth backends"); err != nil {
+		return err
 	}
 
-	if !config.Environments.Contains(env) {
-		return fmt.Errorf("Could not find any environment with name %s in configuration", env)
-	}
+	return forEachVaultNamespace(ctx, client, config, opts, pushNamespaceAuth)
+}
 
-	client, err := api.NewClient(nil)
-	if err != nil {
-		return err
-	}
+// pushNamespaceAuth writes the auth backends of a single Vault namespace
+func pushNamespaceAuth(ctx context.Context, client *api.Client, config *config.Config, opts PushOptions) error {
 
 	auths, err := client.Sys().ListAuth()
 
@@ -44,68 +49,191 @@ func AuthPushWithConfig(c *cli.Context, config *config.Config) error {
 		return err
 	}
 
+	exec := opts.executor()
+
 	for _, auth := range config.VaultAuths {
+		auth := auth
 
 		// Auth
 
-		if _, ok := auths[auth.Name+"/"]; !ok {
-			log.Printf("Creating auth backend %s", auth.Name)
-			err := client.Sys().EnableAuth(auth.Name, auth.Type, auth.Description)
-			if err != nil {
-				log.Panic(err)
-			}
-		} else {
-			log.Printf("Auth backend %s already exist", auth.Name)
+		// config, roles and maps can't be written without the backend, so they wait for it to be enabled
+		var authTask *executor.Task
+
+		remote, exists := auths[auth.Name+"/"]
+		from, fromErr := previousPath(auths, "auth backend", auth.Name, auth.PreviousPaths)
+
+		switch {
+		case auth.IsPlaceholder():
+			log.Debugf("Auth backend %s is a prevent_destroy placeholder, not managing the backend itself", auth.Name)
+		case fromErr != nil:
+			authTask = exec.Add("vault_auth", auth.Name, func() error {
+				return startRecord(client, "vault_auth", auth.Name).Fail(fromErr)
+			})
+		case exists:
+			authTask = exec.Add("vault_auth", auth.Name, func() error {
+				return tuneAuth(client, auth, remote, opts)
+			})
+		case from != "":
+			previous := auths[from+"/"]
+			authTask = exec.Add("vault_auth", auth.Name, func() error {
+				log.Printf("Moving auth backend %s to %s", from, auth.Name)
+				return moveAuth(ctx, client, auth, from, previous, opts)
+			})
+		default:
+			authTask = exec.Add("vault_auth", auth.Name, func() error {
+				log.Printf("Creating auth backend %s", auth.Name)
+				return enableAuth(client, auth, opts)
+			})
 		}
 
 		// Auth config
 
-		for _, config := range auth.Config {
-			configPath := fmt.Sprintf("auth/%s/config/%s", auth.Name, config.Name)
-			configPath = strings.TrimRight(configPath, "/")
-			log.Printf("  Writing auth backend config: %s", configPath)
+		// roles and maps are written after the config, which they may depend on
+		configTasks := []*executor.Task{authTask}
 
-			s, err := client.Logical().Write(configPath, config.Data)
-			if err != nil {
-				log.Panic(err)
-			}
+		for _, config := range auth.Config {
+			configPath := authConfigPath(auth, config)
+			data := config.Data
 
-			printRemoteSecretWarnings(s)
+			configTasks = append(configTasks, exec.Add("vault_auth_config", configPath, func() error {
+				return writePath(client, "vault_auth_config", configPath, data, opts)
+			}, authTask))
 		}
 
 		// Auth roles
 
-		authRolePath := "role"
-		if auth.Type == "token" {
-			authRolePath = "roles"
-		}
-
 		for _, role := range auth.Roles {
-			rolePath := fmt.Sprintf("auth/%s/%s/%s", auth.Name, authRolePath, role.Name)
-			log.Printf("  Writing auth backend role: %s", rolePath)
+			rolePath := authRolePath(auth, role)
+			data := role.Data
 
-			s, err := client.Logical().Write(rolePath, role.Data)
-			if err != nil {
-				log.Panic(err)
-			}
-
-			printRemoteSecretWarnings(s)
+			exec.Add("vault_auth_role", rolePath, func() error {
+				return writePath(client, "vault_auth_role", rolePath, data, opts)
+			}, configTasks...)
 		}
 
 		// Auth maps
 
 		for _, amap := range auth.Maps {
-			mapPath := fmt.Sprintf("auth/%s/map/%s", auth.Name, amap.Name)
-			log.Printf("  Writing auth backend role: %s", mapPath)
-
-			s, err := client.Logical().Write(mapPath, amap.Data)
-			if err != nil {
-				log.Fatal(err)
-			}
+			mapPath := authMapPath(auth, amap)
+			data := amap.Data
 
-			printRemoteSecretWarnings(s)
+			exec.Add("vault_auth_map", mapPath, func() error {
+				return writePath(client, "vault_auth_map", mapPath, data, opts)
+			}, configTasks...)
 		}
 	}
 
+	failures := opts.failures()
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	if !opts.Prune {
+		return failures.Err()
+	}
+
+	names := authsToPrune(auths, config)
+	if !confirmPrune(opts, "auth backends", names) {
+		return failures.Err()
+	}
+
+	exec = opts.executor()
+	for _, name := range names {
+		name := name
+		exec.Add("vault_auth", name, func() error {
+			log.Printf("Disabling auth backend %s", name)
+			return disableAuth(client, name, opts)
+		})
+	}
+
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	return failures.Err()
+}
+
+func enableAuth(client *api.Client, auth *config.Auth, opts PushOptions) error {
+	r := startRecord(client, "vault_auth", auth.Name)
+	if err := opts.Snapshot.CaptureVaultAuth(client, auth.Name); err != nil {
+		return r.Fail(err)
+	}
+
+	data, err := enableData(auth.AuthInput(), auth.PluginVersion)
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	if _, err := client.Logical().Write("sys/auth/"+auth.Name, data); err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(report.ActionCreated)
+	return nil
+}
+
+func tuneAuth(client *api.Client, auth *config.Auth, remote *api.AuthMount, opts PushOptions) error {
+	r := startRecord(client, "vault_auth", auth.Name)
+
+	tuned, err := tune(client, r, "auth backend "+auth.Name, "sys/auth/"+auth.Name+"/tune", auth.MountSettings, remote, opts.Force, func() error {
+		return opts.Snapshot.CaptureVaultAuth(client, auth.Name)
+	})
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(tunedAction(tuned))
+	return nil
+}
+
+// moveAuth moves the auth backend from its previous path with sys/remount, keeping its roles and leases, and tunes it
+func moveAuth(ctx context.Context, client *api.Client, auth *config.Auth, from string, remote *api.AuthMount, opts PushOptions) error {
+	r := startRecord(client, "vault_auth", auth.Name)
+	if err := opts.Snapshot.CaptureVaultRemount(client, "auth/"+from, "auth/"+auth.Name); err != nil {
+		return r.Fail(err)
+	}
+
+	if err := support.Remount(ctx, client, "auth/"+from, "auth/"+auth.Name); err != nil {
+		return r.Fail(err)
+	}
+
+	_, err := tune(client, r, "auth backend "+auth.Name, "sys/auth/"+auth.Name+"/tune", auth.MountSettings, remote, opts.Force, func() error {
+		return opts.Snapshot.CaptureVaultAuth(client, auth.Name)
+	})
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(report.ActionUpdated)
+	return nil
+}
+
+func disableAuth(client *api.Client, name string, opts PushOptions) error {
+	r := startRecord(client, "vault_auth", name)
+	if err := opts.Snapshot.CaptureVaultAuth(client, name); err != nil {
+		return r.Fail(err)
+	}
+
+	if err := client.Sys().DisableAuth(name); err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(report.ActionDeleted)
 	return nil
 }
+
+func authConfigPath(auth *config.Auth, config *config.AuthConfig) string {
+	return strings.TrimRight(fmt.Sprintf("auth/%s/config/%s", auth.Name, config.Name), "/")
+}
+
+func authRolePath(auth *config.Auth, role *config.AuthRole) string {
+	if auth.Type == "token" {
+		return fmt.Sprintf("auth/%s/roles/%s", auth.Name, role.Name)
+	}
+
+	return fmt.Sprintf("auth/%s/role/%s", auth.Name, role.Name)
+}
+
+func authMapPath(auth *config.Auth, amap *config.AuthMap) string {
+	return fmt.Sprintf("auth/%s/map/%s", auth.Name, amap.Name)
+}
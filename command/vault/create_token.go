@@ -1,12 +1,11 @@
 package vault
 
 import (
-	"bytes"
 	"fmt"
-	"os/exec"
 	"strings"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/support"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
 )
@@ -56,27 +55,14 @@ func CreateToken(c *cli.Context) error {
 
 	log.Info("Encrypting token")
 
-	args := make([]string, 0)
-	args = append(args, "encrypt")
-	args = append(args, c.StringSlice("keybase")...)
-
-	cmd := exec.Command("keybase", args...)
-	cmd.Stdin = strings.NewReader(token)
-
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-
-	err = cmd.Run()
+	message, err := support.KeybaseEncrypt(c.StringSlice("keybase"), token)
 	if err != nil {
-		return fmt.Errorf("Failed to run keybase encrypt: %s - %s", err, stderr.String())
+		return err
 	}
 
+	// stdout carries the --output json records, so the message is written to the log output
 	log.Infof("Send the following message to %s:", strings.Join(c.StringSlice("keybase"), ","))
-	fmt.Println()
-	fmt.Println(stdout.String())
+	fmt.Fprintf(log.StandardLogger().Out, "\n%s\n", message)
 
 	return nil
 }
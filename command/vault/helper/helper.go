@@ -32,13 +32,16 @@ func IndexRemoteSecrets(environment string, concurrency int) config.VaultSecrets
 
 	var paths config.VaultSecrets
 
+	// KV version 2 mounts are listed through <mount>/metadata/
+	kvMounts := mustLoadKVMounts()
+
 	// Queue our first path to kick off the scanning
 	indexerWg.Add(1)
 	indexerCh <- "/"
 
 	// Start go routines for workers
 	for i := 0; i <= concurrency; i++ {
-		go remoteSecretIndexer(indexerCh, resultCh, completeCh, &indexerWg, &resultWg, i)
+		go remoteSecretIndexer(kvMounts, indexerCh, resultCh, completeCh, &indexerWg, &resultWg, i)
 	}
 
 	go remoteSecretIndexerResultProcessor(&paths, resultCh, completeCh, &resultWg)
@@ -61,10 +64,12 @@ func IndexRemoteSecrets(environment string, concurrency int) config.VaultSecrets
 }
 
 // readRemoteSecrets
-// Take an array of secret paths to read
-func ReadRemoteSecrets(secrets config.VaultSecrets, concurrency int) (config.VaultSecrets, error) {
+// Take an array of secret paths to read, version 0 reads the latest version of KV version 2 secrets
+func ReadRemoteSecrets(secrets config.VaultSecrets, concurrency, version int) (config.VaultSecrets, error) {
 	log.Infof("Going to read %d remote secrets", len(secrets))
 
+	kvMounts := mustLoadKVMounts()
+
 	// Create a WaitGroup for the remote reader, so we automatically unblock when all tasks are done
 	var readerWg sync.WaitGroup
 	readerWg.Add(len(secrets))
@@ -78,7 +83,7 @@ func ReadRemoteSecrets(secrets config.VaultSecrets, concurrency int) (config.Vau
 
 	// Start go routines for readers
 	for i := 0; i <= concurrency; i++ {
-		go remoteSecretReader(readChan, completeCh, &readerWg, i)
+		go remoteSecretReader(kvMounts, version, readChan, completeCh, &readerWg, i)
 	}
 
 	// queue secrets to be read
@@ -98,7 +103,7 @@ func ReadRemoteSecrets(secrets config.VaultSecrets, concurrency int) (config.Vau
 	return secrets, nil
 }
 
-func remoteSecretReader(readCh chan *config.Secret, completeCh chan interface{}, wg *sync.WaitGroup, workerID int) error {
+func remoteSecretReader(kvMounts KVMounts, version int, readCh chan *config.Secret, completeCh chan interface{}, wg *sync.WaitGroup, workerID int) error {
 	log.WithField("method", "remoteSecretFetcher").Debugf("Starting worker %d", workerID)
 
 	// Create a new Vault API client for this go-routine
@@ -114,7 +119,7 @@ func remoteSecretReader(readCh chan *config.Secret, completeCh chan interface{},
 			return nil
 		case secret := <-readCh:
 			log.Debugf("[%d] Reading secret %s", workerID, secret.Path)
-			remoteSecret, err := client.Logical().Read(secret.Path)
+			remoteSecret, err := kvMounts.ReadSecret(client, secret.Path, version)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -183,7 +188,7 @@ func remoteSecretIndexerResultProcessor(result *config.VaultSecrets, resultCh ch
 	}
 }
 
-func remoteSecretIndexer(indexerCh chan string, resultCh chan string, completeCh chan interface{}, indexerWg *sync.WaitGroup, resultWg *sync.WaitGroup, workerID int) {
+func remoteSecretIndexer(kvMounts KVMounts, indexerCh chan string, resultCh chan string, completeCh chan interface{}, indexerWg *sync.WaitGroup, resultWg *sync.WaitGroup, workerID int) {
 	log.Debugf("Starting worker %d", workerID)
 
 	// Create a new Vault API client for this go-routine
@@ -201,7 +206,7 @@ func remoteSecretIndexer(indexerCh chan string, resultCh chan string, completeCh
 			logicalPath := fmt.Sprintf("secret/%s", strings.Trim(path, "/"))
 			log.Debugf("[%d] Scanning path: %s", workerID, logicalPath)
 
-			response, err := client.Logical().List(logicalPath)
+			response, err := client.Logical().List(kvMounts.MetadataPath(logicalPath))
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -239,6 +244,21 @@ func remoteSecretIndexer(indexerCh chan string, resultCh chan string, completeCh
 	}
 }
 
+// mustLoadKVMounts loads the KV mount versions, like the scanners it stops on errors
+func mustLoadKVMounts() KVMounts {
+	client, err := api.NewClient(nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	kvMounts, err := LoadKVMounts(client)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return kvMounts
+}
+
 func secretsToString(in interface{}) (out []string) {
 	t := in.([]interface{})
 
@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// KVMounts maps the path of every KV mount (with trailing slash) to its KV version
+type KVMounts map[string]int
+
+// LoadKVMounts reads the KV mounts and their version from sys/mounts
+func LoadKVMounts(client *api.Client) (KVMounts, error) {
+	mounts, err := client.Sys().ListMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(KVMounts)
+	for path, mount := range mounts {
+		switch mount.Type {
+		case "kv":
+			version := 1
+			if v, err := strconv.Atoi(mount.Options["version"]); err == nil {
+				version = v
+			}
+			result[path] = version
+
+		// the legacy "generic" backend is KV version 1
+		case "generic":
+			result[path] = 1
+		}
+	}
+
+	return result, nil
+}
+
+// mount returns the longest KV mount path that is a prefix of path, and its version.
+// Paths outside KV mounts return version 0.
+func (m KVMounts) mount(path string) (string, int) {
+	path = strings.TrimLeft(path, "/")
+
+	match := ""
+	for mount := range m {
+		if strings.HasPrefix(path+"/", mount) && len(mount) > len(match) {
+			match = mount
+		}
+	}
+
+	if match == "" {
+		return "", 0
+	}
+
+	return match, m[match]
+}
+
+// IsV2 returns true if path is inside a KV version 2 mount
+func (m KVMounts) IsV2(path string) bool {
+	_, version := m.mount(path)
+	return version == 2
+}
+
+// DataPath returns the path to read and write the secret at the logical path,
+// which is <mount>/data/<path> for KV version 2 mounts
+func (m KVMounts) DataPath(path string) string {
+	return m.rewrite(path, "data/")
+}
+
+// MetadataPath returns the path to list, or to read and write the metadata of, the logical path,
+// which is <mount>/metadata/<path> for KV version 2 mounts
+func (m KVMounts) MetadataPath(path string) string {
+	return m.rewrite(path, "metadata/")
+}
+
+func (m KVMounts) rewrite(path, prefix string) string {
+	path = strings.TrimLeft(path, "/")
+
+	mount, version := m.mount(path)
+	if version != 2 {
+		return path
+	}
+
+	return mount + prefix + strings.TrimPrefix(path, mount)
+}
+
+// Unwrap returns the secret data, which KV version 2 nests inside a "data" key next to the metadata
+func (m KVMounts) Unwrap(path string, secret *api.Secret) *api.Secret {
+	if secret == nil || !m.IsV2(path) {
+		return secret
+	}
+
+	data, _ := secret.Data["data"].(map[string]interface{})
+
+	result := *secret
+	result.Data = data
+	return &result
+}
+
+// ReadSecret reads the logical path, transparently handling KV version 2 mounts.
+// version 0 reads the latest version, other versions are only supported by KV version 2,
+// and are an error for any other path.
+func (m KVMounts) ReadSecret(client *api.Client, path string, version int) (*api.Secret, error) {
+	var params map[string][]string
+	if version > 0 {
+		if !m.IsV2(path) {
+			return nil, fmt.Errorf("Can't read version %d of %s, versions are only supported by KV version 2 mounts", version, path)
+		}
+
+		params = map[string][]string{"version": {strconv.Itoa(version)}}
+	}
+
+	secret, err := client.Logical().ReadWithData(m.DataPath(path), params)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Unwrap(path, secret), nil
+}
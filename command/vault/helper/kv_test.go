@@ -0,0 +1,42 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKVMounts_Paths(t *testing.T) {
+	kvMounts := KVMounts{"secret/": 2, "legacy/": 1, "secret/nested/": 1}
+
+	tests := []struct {
+		path     string
+		data     string
+		metadata string
+	}{
+		{path: "secret/api/TOKEN", data: "secret/data/api/TOKEN", metadata: "secret/metadata/api/TOKEN"},
+		{path: "/secret/api/TOKEN", data: "secret/data/api/TOKEN", metadata: "secret/metadata/api/TOKEN"},
+		{path: "secret/", data: "secret/data/", metadata: "secret/metadata/"},
+		{path: "secret/nested/TOKEN", data: "secret/nested/TOKEN", metadata: "secret/nested/TOKEN"},
+		{path: "legacy/api/TOKEN", data: "legacy/api/TOKEN", metadata: "legacy/api/TOKEN"},
+		{path: "secretive/TOKEN", data: "secretive/TOKEN", metadata: "secretive/TOKEN"},
+		{path: "auth/github/map/teams/infra", data: "auth/github/map/teams/infra", metadata: "auth/github/map/teams/infra"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			require.Equal(t, tt.data, kvMounts.DataPath(tt.path))
+			require.Equal(t, tt.metadata, kvMounts.MetadataPath(tt.path))
+		})
+	}
+}
+
+func TestKVMounts_ReadSecret_version(t *testing.T) {
+	kvMounts := KVMounts{"secret/": 2, "legacy/": 1}
+
+	_, err := kvMounts.ReadSecret(nil, "legacy/api/TOKEN", 3)
+	require.EqualError(t, err, "Can't read version 3 of legacy/api/TOKEN, versions are only supported by KV version 2 mounts")
+
+	_, err = kvMounts.ReadSecret(nil, "auth/github/map/teams/infra", 1)
+	require.Error(t, err)
+}
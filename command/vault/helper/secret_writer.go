@@ -1,33 +1,41 @@
 package helper
 
 import (
-	"bytes"
 	"fmt"
-	"os/exec"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/command/snapshot"
 	"github.com/seatgeek/hashi-helper/config"
 	log "github.com/sirupsen/logrus"
 )
 
 // SecretWriter ...
 type SecretWriter struct {
-	client *api.Client
+	// secrets may be written concurrently, the client and KV mounts are only set up once
+	sync.Mutex
+
+	// Force writes secrets even if Vault already returns the same data
+	Force bool
+
+	// Snapshot captures every secret before it's written, nothing is captured if it's nil
+	Snapshot *snapshot.Recorder
+
+	client   *api.Client
+	kvMounts KVMounts
 }
 
-// WriteSecret ...
-func (w SecretWriter) WriteSecret(secret *config.Secret, config map[string]string) error {
-	var path string
+// NewSecretWriter returns a SecretWriter using client, which may be scoped to a namespace
+func NewSecretWriter(client *api.Client) *SecretWriter {
+	return &SecretWriter{client: client}
+}
 
-	// @TODO Make a dedicated type for writing non-secrets !
-	if strings.HasPrefix(secret.Path, "/") {
-		path = strings.TrimLeft(secret.Path, "/")
-	} else if secret.Application != nil {
-		path = fmt.Sprintf("secret/%s/%s", secret.Application.Name, secret.Path)
-	} else {
-		path = fmt.Sprintf("secret/%s", secret.Path)
-	}
+// WriteSecret ...
+func (w *SecretWriter) WriteSecret(secret *config.Secret, config map[string]string) error {
+	path := SecretPath(secret)
 
 	if prefix, ok := config["only-prefix"]; ok && !strings.HasPrefix(path, prefix) {
 		log.Infof("Skipping %s, does not match prefix %s", path, prefix)
@@ -36,35 +44,172 @@ func (w SecretWriter) WriteSecret(secret *config.Secret, config map[string]strin
 
 	log.Info(path)
 
-	_, err := w.getClient().Logical().Write(path, secret.VaultSecret.Data)
-	return err
+	client, err := w.getClient()
+	if err != nil {
+		return err
+	}
+
+	r := report.Start("vault_secret", path).In(client.Headers().Get("X-Vault-Namespace"))
+	if secret.Application != nil {
+		r.App(secret.Application.Name)
+	}
+
+	action, err := w.write(client, path, secret, r)
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(action)
+	return nil
 }
 
-func (w SecretWriter) getClient() *api.Client {
-	if w.client == nil {
-		client, err := api.NewClient(nil)
+func (w *SecretWriter) write(client *api.Client, path string, secret *config.Secret, r *report.Record) (report.Action, error) {
+	kvMounts, err := w.getKVMounts(client)
+	if err != nil {
+		return "", err
+	}
+
+	// unchanged secrets aren't written, so KV version 2 doesn't create a new version
+	action := report.ActionUpdated
+	if !w.Force {
+		remote, err := kvMounts.ReadSecret(client, path, 0)
+		switch {
+		case err != nil:
+			// a token may only be allowed to write secrets, not to read them
+			log.Debugf("  Could not read %s, writing it anyway: %s", path, err)
+		case remote == nil || remote.Data == nil:
+			action = report.ActionCreated
+		case unchanged(client, kvMounts, path, secret, remote):
+			log.Debugf("  %s is up to date", path)
+			return report.ActionUnchanged, nil
+		}
+	}
+
+	if !kvMounts.IsV2(path) {
+		if secret.KVOptions != nil {
+			log.Warnf("  %s is not in a KV version 2 mount, ignoring cas, max_versions and custom_metadata", path)
+		}
+
+		if err := w.Snapshot.CaptureVaultPath(client, path, secret.VaultSecret.Data); err != nil {
+			return "", err
+		}
+
+		s, err := client.Logical().Write(path, secret.VaultSecret.Data)
+		addWarnings(s, r)
+		return action, err
+	}
+
+	if err := w.Snapshot.CaptureVaultKV(client, kvMounts.DataPath(path), kvMounts.MetadataPath(path)); err != nil {
+		return "", err
+	}
+
+	// metadata is written first, so max_versions applies to the version written below
+	if secret.KVOptions.HasMetadata() {
+		s, err := client.Logical().Write(kvMounts.MetadataPath(path), KVMetadata(secret.KVOptions))
+		addWarnings(s, r)
 		if err != nil {
-			log.Panic(err)
+			return "", err
 		}
-		w.client = client
 	}
-	return w.client
+
+	data := map[string]interface{}{"data": secret.VaultSecret.Data}
+	if secret.KVOptions != nil && secret.KVOptions.CAS != nil {
+		data["options"] = map[string]interface{}{"cas": *secret.KVOptions.CAS}
+	}
+
+	s, err := client.Logical().Write(kvMounts.DataPath(path), data)
+	addWarnings(s, r)
+	return action, err
 }
 
-// EscapeValue ...
-func EscapeValue(value string) string {
-	value = strings.Replace(value, "\\", "\\\\", -1)
-	value = strings.Replace(value, "\"", "\\", -1)
+// unchanged returns true if remote has exactly the data of secret, and for KV version 2 secrets
+// the metadata is up to date as well
+func unchanged(client *api.Client, kvMounts KVMounts, path string, secret *config.Secret, remote *api.Secret) bool {
+	if len(plan.DiffDataStrict(remote.Data, secret.VaultSecret.Data, true)) > 0 {
+		return false
+	}
 
-	return value
+	if !kvMounts.IsV2(path) || !secret.KVOptions.HasMetadata() {
+		return true
+	}
+
+	metadata, err := client.Logical().Read(kvMounts.MetadataPath(path))
+	if err != nil || metadata == nil {
+		return false
+	}
+
+	return len(plan.DiffData(metadata.Data, KVMetadata(secret.KVOptions), false)) == 0
+}
+
+// KVMetadata returns the metadata of a KV version 2 secret configured in options
+func KVMetadata(options *config.KVOptions) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if options == nil {
+		return metadata
+	}
+
+	if options.MaxVersions > 0 {
+		metadata["max_versions"] = options.MaxVersions
+	}
+	if len(options.CustomMetadata) > 0 {
+		metadata["custom_metadata"] = options.CustomMetadata
+	}
+
+	return metadata
 }
 
-// FormatHclFile ...
-func FormatHclFile(file string) (bytes.Buffer, error) {
-	cmd := exec.Command("hclfmt", "-w", file)
-	var out bytes.Buffer
-	cmd.Stdout = &out
+// addWarnings adds the warnings Vault returned for a write to the record
+func addWarnings(s *api.Secret, r *report.Record) {
+	if s == nil {
+		return
+	}
+
+	for _, warning := range s.Warnings {
+		log.Warnf("  %s", warning)
+	}
+	r.Warn(s.Warnings...)
+}
+
+// SecretPath returns the remote Vault path a local secret will be written to
+func SecretPath(secret *config.Secret) string {
+	// @TODO Make a dedicated type for writing non-secrets !
+	if strings.HasPrefix(secret.Path, "/") {
+		return strings.TrimLeft(secret.Path, "/")
+	}
 
-	err := cmd.Run()
-	return out, err
+	if secret.Application != nil {
+		return fmt.Sprintf("secret/%s/%s", secret.Application.Name, secret.Path)
+	}
+
+	return fmt.Sprintf("secret/%s", secret.Path)
+}
+
+// getClient returns the client of the writer, or creates one configured from the environment
+func (w *SecretWriter) getClient() (*api.Client, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.client == nil {
+		client, err := api.NewClient(nil)
+		if err != nil {
+			return nil, err
+		}
+		w.client = client
+	}
+	return w.client, nil
+}
+
+// getKVMounts loads the KV mount versions once per writer
+func (w *SecretWriter) getKVMounts(client *api.Client) (KVMounts, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.kvMounts == nil {
+		kvMounts, err := LoadKVMounts(client)
+		if err != nil {
+			return nil, err
+		}
+		w.kvMounts = kvMounts
+	}
+	return w.kvMounts, nil
 }
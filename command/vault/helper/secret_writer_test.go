@@ -0,0 +1,65 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnchanged(t *testing.T) {
+	kvMounts := KVMounts{"secret/": 2, "legacy/": 1}
+
+	tests := []struct {
+		name     string
+		path     string
+		remote   map[string]interface{}
+		desired  map[string]interface{}
+		expected bool
+	}{
+		{
+			name:     "same data",
+			path:     "legacy/api/TOKEN",
+			remote:   map[string]interface{}{"value": "hunter2"},
+			desired:  map[string]interface{}{"value": "hunter2"},
+			expected: true,
+		},
+		{
+			name:     "changed value",
+			path:     "secret/api/TOKEN",
+			remote:   map[string]interface{}{"value": "hunter2"},
+			desired:  map[string]interface{}{"value": "hunter3"},
+			expected: false,
+		},
+		{
+			name:     "remote has an extra key",
+			path:     "secret/api/TOKEN",
+			remote:   map[string]interface{}{"value": "hunter2", "old": "1"},
+			desired:  map[string]interface{}{"value": "hunter2"},
+			expected: false,
+		},
+		{
+			name:     "durations in secrets are compared exactly",
+			path:     "secret/api/TIMEOUT",
+			remote:   map[string]interface{}{"value": "3600"},
+			desired:  map[string]interface{}{"value": "1h"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &config.Secret{VaultSecret: &api.Secret{Data: tt.desired}}
+			require.Equal(t, tt.expected, unchanged(nil, kvMounts, tt.path, secret, &api.Secret{Data: tt.remote}))
+		})
+	}
+}
+
+func TestKVMetadata(t *testing.T) {
+	require.Equal(t, map[string]interface{}{}, KVMetadata(nil))
+	require.Equal(t, map[string]interface{}{
+		"max_versions":    5,
+		"custom_metadata": map[string]string{"owner": "infra"},
+	}, KVMetadata(&config.KVOptions{MaxVersions: 5, CustomMetadata: map[string]string{"owner": "infra"}}))
+}
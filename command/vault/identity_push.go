@@ -0,0 +1,434 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/executor"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// IdentityPush ...
+func IdentityPush(c *cli.Context) error {
+	config, err := config.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	return IdentityPushWithConfig(c, config)
+}
+
+// IdentityPushWithConfig ...
+func IdentityPushWithConfig(c *cli.Context, config *config.Config) error {
+	return pushWithCLI(c, config, PushIdentity)
+}
+
+// PushIdentity will upsert identity entities, groups and group aliases by name in all Vault namespaces.
+// Resources that already match the configuration are not written.
+func PushIdentity(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
+	log.Info("Pushing Vault Identity")
+
+	if err := checkPushConfig(config, opts, "Pushing identity"); err != nil {
+		return err
+	}
+
+	return forEachVaultNamespace(ctx, client, config, opts, pushNamespaceIdentity)
+}
+
+// pushNamespaceIdentity writes the identity resources of a single Vault namespace. Groups are written
+// after the entities and groups that are their members, and group aliases after their group
+func pushNamespaceIdentity(ctx context.Context, client *api.Client, config *config.Config, opts PushOptions) error {
+	groups, err := sortedIdentityGroups(config)
+	if err != nil {
+		return err
+	}
+
+	auths, err := identityAuths(client, config)
+	if err != nil {
+		return err
+	}
+
+	exec := opts.executor()
+
+	entityTasks := make(map[string]*executor.Task)
+	for _, entity := range config.VaultIdentityEntities {
+		entity := entity
+		entityTasks[entity.Name] = exec.Add("vault_identity_entity", entity.Name, func() error {
+			return writeIdentityEntity(client, entity, opts)
+		})
+	}
+
+	groupTasks := make(map[string]*executor.Task)
+	for _, group := range groups {
+		group := group
+
+		// members that are not in config must already exist in Vault
+		deps := make([]*executor.Task, 0)
+		for _, name := range group.MemberEntityNames {
+			deps = append(deps, entityTasks[name])
+		}
+		for _, name := range group.MemberGroupNames {
+			deps = append(deps, groupTasks[name])
+		}
+
+		groupTasks[group.Name] = exec.Add("vault_identity_group", group.Name, func() error {
+			return writeIdentityGroup(client, group, opts)
+		}, deps...)
+	}
+
+	for _, alias := range config.VaultIdentityGroupAliases {
+		alias := alias
+		exec.Add("vault_identity_group_alias", identityAliasName(alias), func() error {
+			return writeIdentityGroupAlias(client, alias, auths, opts)
+		}, groupTasks[alias.Group])
+	}
+
+	failures := opts.failures()
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	return failures.Err()
+}
+
+// sortedIdentityGroups returns the groups in config, members first, after checking that every
+// group alias references an external group
+func sortedIdentityGroups(config *config.Config) (config.VaultIdentityGroups, error) {
+	for _, alias := range config.VaultIdentityGroupAliases {
+		group := config.VaultIdentityGroups.Find(alias.Group)
+		if group != nil && !group.IsExternal() {
+			return nil, fmt.Errorf("identity_group_alias %s references identity_group %s, which must have type = \"external\"", alias.Name, alias.Group)
+		}
+	}
+
+	return config.VaultIdentityGroups.Sorted()
+}
+
+// identityAuths returns the auth backends of the namespace of client, if there are any group aliases to resolve
+func identityAuths(client *api.Client, config *config.Config) (map[string]*api.AuthMount, error) {
+	if len(config.VaultIdentityGroupAliases) == 0 {
+		return nil, nil
+	}
+
+	return client.Sys().ListAuth()
+}
+
+func writeIdentityEntity(client *api.Client, entity *config.IdentityEntity, opts PushOptions) error {
+	r := startRecord(client, "vault_identity_entity", entity.Name)
+	path := "identity/entity/name/" + entity.Name
+
+	remote, err := readIdentity(client, path)
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	change := identityChange("vault_identity_entity", entity.Name, remote, entity.ToMap())
+	_, err = writeIdentity(client, r, change, path, entity.ToMap(), opts, true)
+	return err
+}
+
+func writeIdentityGroup(client *api.Client, group *config.IdentityGroup, opts PushOptions) error {
+	r := startRecord(client, "vault_identity_group", group.Name)
+	path := "identity/group/name/" + group.Name
+
+	remote, err := readIdentity(client, path)
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	if remote != nil && remote["type"] != group.Type {
+		return r.Fail(fmt.Errorf("identity_group %s is %s in Vault, the type of a group can't be changed", group.Name, remote["type"]))
+	}
+
+	desired, err := identityGroupData(client, group)
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	change := identityChange("vault_identity_group", group.Name, remote, desired)
+	_, err = writeIdentity(client, r, change, path, desired, opts, true)
+	return err
+}
+
+func writeIdentityGroupAlias(client *api.Client, alias *config.IdentityGroupAlias, auths map[string]*api.AuthMount, opts PushOptions) error {
+	name := identityAliasName(alias)
+	r := startRecord(client, "vault_identity_group_alias", name)
+
+	desired, remote, err := identityGroupAliasData(client, alias, auths)
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	change := identityChange("vault_identity_group_alias", name, remote, desired)
+
+	// a new alias only gets its ID when it's written, so it's captured afterwards
+	if remote != nil {
+		id, _ := remote["id"].(string)
+		_, err = writeIdentity(client, r, change, "identity/group-alias/id/"+id, desired, opts, true)
+		return err
+	}
+
+	s, err := writeIdentity(client, r, change, "identity/group-alias", desired, opts, false)
+	if err != nil || s == nil || s.Data == nil {
+		return err
+	}
+
+	if id, _ := s.Data["id"].(string); id != "" {
+		return opts.Snapshot.CaptureVaultPathCreated(client, "identity/group-alias/id/"+id)
+	}
+
+	return nil
+}
+
+// writeIdentity writes data to path for change, unless it's already up to date. With Force it's always
+// written. The previous value of path is captured first if capture is true
+func writeIdentity(client *api.Client, r *report.Record, change *plan.Change, path string, data map[string]interface{}, opts PushOptions, capture bool) (*api.Secret, error) {
+	if change.Action == plan.ActionNoop && !opts.Force {
+		log.Debugf("  %s %s is up to date", change.Resource, change.Name)
+		r.Done(report.ActionUnchanged)
+		return nil, nil
+	}
+
+	if capture {
+		if err := opts.Snapshot.CaptureVaultPath(client, path, data); err != nil {
+			return nil, r.Fail(err)
+		}
+	}
+
+	log.Printf("  Writing %s %s", change.Resource, change.Name)
+
+	s, err := client.Logical().Write(path, data)
+	if err != nil {
+		return nil, r.Fail(fmt.Errorf("Could not write %s %s: %s", change.Resource, change.Name, err))
+	}
+
+	printRemoteSecretWarnings(s, r)
+
+	// with --force resources that are up to date are written as well
+	if change.Action == plan.ActionNoop {
+		r.Done(report.ActionUpdated)
+	} else {
+		r.Done(report.FromPlan(change.Action))
+	}
+
+	return s, nil
+}
+
+// planIdentity compares entities, groups and group aliases with Vault, in the order they must be written
+func planIdentity(client *api.Client, config *config.Config, opts PushOptions) (plan.Changes, error) {
+	log.Info("  Planning Vault Identity")
+
+	groups, err := sortedIdentityGroups(config)
+	if err != nil {
+		return nil, err
+	}
+
+	auths, err := identityAuths(client, config)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := plan.Changes{}
+
+	for _, entity := range config.VaultIdentityEntities {
+		remote, err := readIdentity(client, "identity/entity/name/"+entity.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		changes.Add(identityChange("vault_identity_entity", entity.Name, remote, entity.ToMap()))
+	}
+
+	for _, group := range groups {
+		remote, err := readIdentity(client, "identity/group/name/"+group.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if remote != nil && remote["type"] != group.Type {
+			return nil, fmt.Errorf("identity_group %s is %s in Vault, the type of a group can't be changed", group.Name, remote["type"])
+		}
+
+		// members that don't exist yet can't be resolved, the group will be written anyway
+		desired, err := identityGroupData(client, group)
+		if err != nil {
+			desired = map[string]interface{}{"type": group.Type, "policies": group.Policies, "metadata": group.Metadata}
+			remote = nil
+		}
+
+		changes.Add(identityChange("vault_identity_group", group.Name, remote, desired))
+	}
+
+	for _, alias := range config.VaultIdentityGroupAliases {
+		desired, remote, err := identityGroupAliasData(client, alias, auths)
+		if err != nil {
+			// the group doesn't exist yet, so neither does its alias
+			changes.Add(&plan.Change{
+				Resource: "vault_identity_group_alias",
+				Name:     identityAliasName(alias),
+				Action:   plan.ActionCreate,
+				Fields:   []plan.FieldChange{{Key: "group", New: alias.Group}},
+			})
+			continue
+		}
+
+		changes.Add(identityChange("vault_identity_group_alias", identityAliasName(alias), remote, desired))
+	}
+
+	return changes, nil
+}
+
+// identityGroupData returns the payload for identity/group/name/<name>, with member names resolved to IDs
+func identityGroupData(client *api.Client, group *config.IdentityGroup) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"type":     group.Type,
+		"policies": group.Policies,
+		"metadata": group.Metadata,
+	}
+
+	if group.IsExternal() {
+		return data, nil
+	}
+
+	groupIDs := make([]string, 0, len(group.MemberGroupNames))
+	for _, name := range group.MemberGroupNames {
+		id, err := identityID(client, "identity/group/name/"+name)
+		if err != nil {
+			return nil, err
+		}
+		groupIDs = append(groupIDs, id)
+	}
+
+	entityIDs := make([]string, 0, len(group.MemberEntityNames))
+	for _, name := range group.MemberEntityNames {
+		id, err := identityID(client, "identity/entity/name/"+name)
+		if err != nil {
+			return nil, err
+		}
+		entityIDs = append(entityIDs, id)
+	}
+
+	data["member_group_ids"] = groupIDs
+	data["member_entity_ids"] = entityIDs
+
+	return data, nil
+}
+
+// identityGroupAliasData returns the payload for a group alias and the existing alias of the group, if any.
+// The mount_accessor is resolved from the auth backend name in auths.
+func identityGroupAliasData(client *api.Client, alias *config.IdentityGroupAlias, auths map[string]*api.AuthMount) (map[string]interface{}, map[string]interface{}, error) {
+	auth, ok := auths[alias.Auth+"/"]
+	if !ok {
+		return nil, nil, fmt.Errorf("identity_group_alias %s references auth backend %s, which does not exist", alias.Name, alias.Auth)
+	}
+
+	group, err := readIdentity(client, "identity/group/name/"+alias.Group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if group == nil {
+		return nil, nil, fmt.Errorf("identity_group_alias %s references identity_group %s, which does not exist", alias.Name, alias.Group)
+	}
+
+	remote, _ := group["alias"].(map[string]interface{})
+	if len(remote) == 0 {
+		remote = nil
+	}
+
+	data := map[string]interface{}{
+		"name":           alias.Name,
+		"mount_accessor": auth.Accessor,
+		"canonical_id":   group["id"],
+	}
+
+	return data, remote, nil
+}
+
+// identityID returns the ID of the entity or group at path
+func identityID(client *api.Client, path string) (string, error) {
+	data, err := readIdentity(client, path)
+	if err != nil {
+		return "", err
+	}
+
+	if data == nil {
+		return "", fmt.Errorf("Could not find %s in Vault", path)
+	}
+
+	id, _ := data["id"].(string)
+	return id, nil
+}
+
+func readIdentity(client *api.Client, path string) (map[string]interface{}, error) {
+	s, err := client.Logical().Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if s == nil {
+		return nil, nil
+	}
+
+	return s.Data, nil
+}
+
+// identityChange compares remote and desired identity data, ignoring the order of lists
+// and the difference between missing and empty values
+func identityChange(resource, name string, remote, desired map[string]interface{}) *plan.Change {
+	if remote == nil {
+		return &plan.Change{Resource: resource, Name: name, Action: plan.ActionCreate, Fields: plan.DiffData(nil, normalizeIdentity(desired), false)}
+	}
+
+	return plan.NewChange(resource, name, plan.DiffData(normalizeIdentity(remote), normalizeIdentity(desired), false))
+}
+
+func normalizeIdentity(data map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(data))
+
+	for k, v := range data {
+		switch t := v.(type) {
+		case []string:
+			list := append([]string{}, t...)
+			sort.Strings(list)
+			result[k] = list
+		case []interface{}:
+			list := make([]string, 0, len(t))
+			for _, item := range t {
+				list = append(list, fmt.Sprintf("%v", item))
+			}
+			sort.Strings(list)
+			result[k] = list
+		case map[string]string:
+			m := make(map[string]interface{}, len(t))
+			for mk, mv := range t {
+				m[mk] = mv
+			}
+			result[k] = emptyMapToNil(m)
+		case map[string]interface{}:
+			result[k] = emptyMapToNil(t)
+		default:
+			result[k] = v
+		}
+	}
+
+	return result
+}
+
+func emptyMapToNil(m map[string]interface{}) interface{} {
+	if len(m) == 0 {
+		return nil
+	}
+
+	return m
+}
+
+func identityAliasName(alias *config.IdentityGroupAlias) string {
+	return alias.Auth + "/" + alias.Name
+}
@@ -1,10 +1,17 @@
 package vault
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/executor"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	"github.com/seatgeek/hashi-helper/command/report"
 	"github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
 )
@@ -21,84 +28,361 @@ func MountsPush(c *cli.Context) error {
 
 // MountsPushWithConfig ...
 func MountsPushWithConfig(c *cli.Context, config *config.Config) error {
+	return pushWithCLI(c, config, PushMounts)
+}
+
+// PushMounts will create, update and optionally prune the mounts in all Vault namespaces
+func PushMounts(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
 	log.Info("Pushing Vault Mounts")
 
-	env := c.GlobalString("environment")
-	if env == "" {
-		return fmt.Errorf("Pushing mounts require a 'environment' value (--environment or ENV[ENVIRONMENT])")
+	if err := checkPushConfig(config, opts, "Pushing mounts"); err != nil {
+		return err
 	}
 
-	if !config.Environments.Contains(env) {
-		return fmt.Errorf("Could not find any environment with name %s in configuration", env)
-	}
+	return forEachVaultNamespace(ctx, client, config, opts, pushNamespaceMounts)
+}
 
-	client, err := api.NewClient(nil)
-	if err != nil {
-		return err
-	}
+// pushNamespaceMounts writes the mounts of a single Vault namespace
+func pushNamespaceMounts(ctx context.Context, client *api.Client, config *config.Config, opts PushOptions) error {
 
 	mounts, err := client.Sys().ListMounts()
 	if err != nil {
 		return err
 	}
 
+	exec := opts.executor()
+
 	for _, mount := range config.VaultMounts {
+		mount := mount
 
 		// MOUNT POINT
 
+		// config and roles can't be written without the mount, so they wait for it to be created
+		var mountTask *executor.Task
+
 		mountLogicalName := mount.Name + "/"
-		if _, ok := mounts[mountLogicalName]; !ok {
-			log.Printf("Creating mount %s", mountLogicalName)
-			err := client.Sys().Mount(mount.Name, mount.MountInput())
-			if err != nil {
-				log.Panic(err)
-			}
-		} else {
-			log.Printf("Mount %s already exist", mountLogicalName)
+		remote, exists := mounts[mountLogicalName]
+		from, fromErr := previousPath(mounts, "mount", mount.Name, mount.PreviousPaths)
+
+		switch {
+		case mount.IsPlaceholder():
+			log.Debugf("Mount %s is a prevent_destroy placeholder, not managing the mount itself", mountLogicalName)
+		case fromErr != nil:
+			mountTask = exec.Add("vault_mount", mount.Name, func() error {
+				return startRecord(client, "vault_mount", mount.Name).Fail(fromErr)
+			})
+		case exists:
+			mountTask = exec.Add("vault_mount", mount.Name, func() error {
+				return tuneMount(client, mount, remote, opts)
+			})
+		case from != "":
+			previous := mounts[from+"/"]
+			mountTask = exec.Add("vault_mount", mount.Name, func() error {
+				log.Printf("Moving mount %s/ to %s", from, mountLogicalName)
+				return moveMount(ctx, client, mount, from, previous, opts)
+			})
+		default:
+			mountTask = exec.Add("vault_mount", mount.Name, func() error {
+				log.Printf("Creating mount %s", mountLogicalName)
+				return createMount(client, mount, opts)
+			})
 		}
 
 		// MOUNT CONFIG
 
-		for _, config := range mount.Config {
-			configPath := fmt.Sprintf("%s/config/%s", mount.Name, config.Name)
-
-			log.Printf("  Writing mount config %s", configPath)
+		// roles usually reference the config, e.g. the connection of a database role
+		configTasks := []*executor.Task{mountTask}
 
-			s, err := client.Logical().Write(configPath, config.Data)
-			if err != nil {
-				log.Panic(err)
-			}
+		for _, config := range mount.Config {
+			configPath := mountConfigPath(mount, config)
+			data := config.Data
 
-			printRemoteSecretWarnings(s)
+			configTasks = append(configTasks, exec.Add("vault_mount_config", configPath, func() error {
+				return writePath(client, "vault_mount_config", configPath, data, opts)
+			}, mountTask))
 		}
 
 		// MOUNT ROLES
 
 		for _, role := range mount.Roles {
+			rolePath := mountRolePath(mount, role)
+			data := role.Data
+
+			exec.Add("vault_mount_role", rolePath, func() error {
+				return writePath(client, "vault_mount_role", rolePath, data, opts)
+			}, configTasks...)
+		}
+	}
+
+	failures := opts.failures()
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	if !opts.Prune {
+		return failures.Err()
+	}
+
+	names := mountsToPrune(mounts, config)
+	if !confirmPrune(opts, "mounts", names) {
+		return failures.Err()
+	}
+
+	exec = opts.executor()
+	for _, name := range names {
+		name := name
+		exec.Add("vault_mount", name, func() error {
+			log.Printf("Unmounting %s", name)
+			return unmount(client, name, opts)
+		})
+	}
+
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
 
-			rolePath := fmt.Sprintf("%s/roles/%s", mount.Name, role.Name)
-			if mount.Type == "nomad" {
-				rolePath = fmt.Sprintf("%s/role/%s", mount.Name, role.Name)
+	return failures.Err()
+}
+
+func createMount(client *api.Client, mount *config.Mount, opts PushOptions) error {
+	r := startRecord(client, "vault_mount", mount.Name)
+	if err := opts.Snapshot.CaptureVaultMount(client, mount.Name); err != nil {
+		return r.Fail(err)
+	}
+
+	data, err := enableData(mount.MountInput(), mount.PluginVersion)
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	if _, err := client.Logical().Write("sys/mounts/"+mount.Name, data); err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(report.ActionCreated)
+	return nil
+}
+
+func tuneMount(client *api.Client, mount *config.Mount, remote *api.MountOutput, opts PushOptions) error {
+	r := startRecord(client, "vault_mount", mount.Name)
+
+	tuned, err := tune(client, r, "mount "+mount.Name, "sys/mounts/"+mount.Name+"/tune", mount.MountSettings, remote, opts.Force, func() error {
+		return opts.Snapshot.CaptureVaultMount(client, mount.Name)
+	})
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(tunedAction(tuned))
+	return nil
+}
+
+// moveMount moves the mount from its previous path with sys/remount, keeping its data and leases, and tunes it
+func moveMount(ctx context.Context, client *api.Client, mount *config.Mount, from string, remote *api.MountOutput, opts PushOptions) error {
+	r := startRecord(client, "vault_mount", mount.Name)
+	if err := opts.Snapshot.CaptureVaultRemount(client, from, mount.Name); err != nil {
+		return r.Fail(err)
+	}
+
+	if err := support.Remount(ctx, client, from, mount.Name); err != nil {
+		return r.Fail(err)
+	}
+
+	_, err := tune(client, r, "mount "+mount.Name, "sys/mounts/"+mount.Name+"/tune", mount.MountSettings, remote, opts.Force, func() error {
+		return opts.Snapshot.CaptureVaultMount(client, mount.Name)
+	})
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(report.ActionUpdated)
+	return nil
+}
+
+// previousPath returns the previous path of a mount or auth backend (kind) to move it from, if it doesn't exist
+// at name yet. It fails if it exists at both name and a previous path, or at more than one previous path
+func previousPath(remote map[string]*api.MountOutput, kind, name string, previousPaths []string) (string, error) {
+	found := make([]string, 0)
+	for _, path := range previousPaths {
+		if _, ok := remote[path+"/"]; ok {
+			found = append(found, path)
+		}
+	}
+
+	if len(found) == 0 {
+		return "", nil
+	}
+
+	if _, ok := remote[name+"/"]; ok {
+		return "", fmt.Errorf("%s %s and its previous path %s both exist, remove one of them from Vault or from previous_paths", kind, name, found[0])
+	}
+
+	if len(found) > 1 {
+		return "", fmt.Errorf("%s %s exists at more than one of its previous paths: %s", kind, name, strings.Join(found, ", "))
+	}
+
+	return found[0], nil
+}
+
+// enableData returns the request enabling a mount or auth backend. The Vault api client doesn't
+// know plugin_version, so the input is sent as plain data
+func enableData(input *api.MountInput, pluginVersion string) (map[string]interface{}, error) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+
+	if pluginVersion != "" {
+		data["plugin_version"] = pluginVersion
+	}
+
+	return data, nil
+}
+
+// tune updates the tunable settings of an existing mount or auth backend (what) at tunePath, unless
+// Vault already returns the same settings, and returns if they were written. With force they're always
+// written. Settings that can only be set when the mount is enabled are not changed, but reported as a warning
+func tune(client *api.Client, r *report.Record, what, tunePath string, settings config.MountSettings, remote *api.MountOutput, force bool, capture func() error) (bool, error) {
+	if changed := settings.ImmutableChanges(remote); len(changed) > 0 {
+		warning := fmt.Sprintf("%s of %s can only be changed by enabling it again", strings.Join(changed, ", "), what)
+		log.Warn(warning)
+		r.Warn(warning)
+	}
+
+	data := settings.TuneData()
+	if len(data) == 0 {
+		return false, nil
+	}
+
+	if !force {
+		current, err := client.Logical().Read(tunePath)
+		switch {
+		case err != nil:
+			log.Debugf("  Could not read %s, tuning anyway: %s", tunePath, err)
+		case current != nil:
+			fields := plan.DiffDataForWrite(current.Data, data, false)
+			if len(fields) == 0 {
+				log.Debugf("  %s is up to date", what)
+				return false, nil
 			}
 
-			log.Printf("  Writing role %s", rolePath)
+			for _, field := range fields {
+				log.Debugf("  %s: %s changed", what, field.Key)
+			}
+		}
+	}
+
+	log.Printf("Tuning %s", what)
+
+	if err := capture(); err != nil {
+		return false, err
+	}
 
-			s, err := client.Logical().Write(rolePath, role.Data)
-			if err != nil {
-				log.Panic(err)
+	if _, err := client.Logical().Write(tunePath, data); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// tunedAction returns the report action of tuning an existing mount or auth backend
+func tunedAction(tuned bool) report.Action {
+	if tuned {
+		return report.ActionUpdated
+	}
+
+	return report.ActionUnchanged
+}
+
+func unmount(client *api.Client, name string, opts PushOptions) error {
+	r := startRecord(client, "vault_mount", name)
+	if err := opts.Snapshot.CaptureVaultMount(client, name); err != nil {
+		return r.Fail(err)
+	}
+
+	if err := client.Sys().Unmount(name); err != nil {
+		return r.Fail(err)
+	}
+
+	r.Done(report.ActionDeleted)
+	return nil
+}
+
+// writePath writes the config or role of a mount or auth backend at path, unless Vault already
+// returns the same data. With Force it's always written
+func writePath(client *api.Client, resourceType, path string, data map[string]interface{}, opts PushOptions) error {
+	r := startRecord(client, resourceType, path)
+
+	action := report.ActionUpdated
+	if !opts.Force {
+		remote, err := client.Logical().Read(path)
+		switch {
+		case err != nil:
+			// not every path can be read back, those are always written
+			log.Debugf("  Could not read %s, writing it anyway: %s", path, err)
+		case remote == nil || remote.Data == nil:
+			action = report.ActionCreated
+		default:
+			fields := plan.DiffDataForWrite(remote.Data, data, true)
+			if len(fields) == 0 {
+				log.Debugf("  %s is up to date", path)
+				r.Done(report.ActionUnchanged)
+				return nil
 			}
 
-			printRemoteSecretWarnings(s)
+			for _, field := range fields {
+				log.Debugf("  %s: %s changed", path, field.Key)
+			}
 		}
 	}
 
+	log.Printf("  Writing %s", path)
+
+	if err := opts.Snapshot.CaptureVaultPath(client, path, data); err != nil {
+		return r.Fail(err)
+	}
+
+	s, err := client.Logical().Write(path, data)
+	if err != nil {
+		return r.Fail(err)
+	}
+
+	printRemoteSecretWarnings(s, r)
+	r.Done(action)
 	return nil
 }
 
-func printRemoteSecretWarnings(s *api.Secret) {
+func mountConfigPath(mount *config.Mount, config *config.MountConfig) string {
+	return fmt.Sprintf("%s/config/%s", mount.Name, config.Name)
+}
+
+func mountRolePath(mount *config.Mount, role *config.MountRole) string {
+	if mount.Type == "nomad" {
+		return fmt.Sprintf("%s/role/%s", mount.Name, role.Name)
+	}
+
+	return fmt.Sprintf("%s/roles/%s", mount.Name, role.Name)
+}
+
+// printRemoteSecretWarnings logs the warnings Vault returned, and adds them to the report record
+func printRemoteSecretWarnings(s *api.Secret, r *report.Record) {
 	if s != nil && len(s.Warnings) > 0 {
 		for _, warn := range s.Warnings {
 			log.Warn("    REMOTE WARNING: " + warn)
 		}
+
+		if r != nil {
+			r.Warn(s.Warnings...)
+		}
 	}
 }
+
+// startRecord begins a report record for a resource in the namespace of client
+func startRecord(client *api.Client, resourceType, path string) *report.Record {
+	return report.Start(resourceType, path).In(client.Headers().Get("X-Vault-Namespace"))
+}
@@ -0,0 +1,206 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/executor"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	"github.com/seatgeek/hashi-helper/command/report"
+	cfg "github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// NamespacesPush ...
+func NamespacesPush(c *cli.Context) error {
+	config, err := cfg.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	return NamespacesPushWithConfig(c, config)
+}
+
+// NamespacesPushWithConfig ...
+func NamespacesPushWithConfig(c *cli.Context, config *cfg.Config) error {
+	return pushWithCLI(c, config, PushNamespaces)
+}
+
+// PushNamespaces will create all Vault Enterprise namespaces in the configuration
+// that don't exist yet. Namespaces are never deleted.
+func PushNamespaces(ctx context.Context, config *cfg.Config, client *api.Client, opts PushOptions) error {
+	log.Info("Pushing Vault Namespaces")
+
+	if err := checkPushConfig(config, opts, "Pushing namespaces"); err != nil {
+		return err
+	}
+
+	changes, err := planNamespaces(client, config)
+	if err != nil {
+		return err
+	}
+
+	exec := opts.executor()
+
+	// children are created once their parent namespace exists
+	tasks := map[string]*executor.Task{}
+
+	for _, change := range changes {
+		if change.Action == plan.ActionNoop {
+			log.Debugf("  Namespace %s already exist", change.Name)
+			report.Start("vault_namespace", change.Name).Done(report.ActionUnchanged)
+			continue
+		}
+
+		namespace := &cfg.VaultNamespace{Path: change.Name}
+		tasks[namespace.Path] = exec.Add("vault_namespace", namespace.Path, func() error {
+			log.Printf("  Creating namespace %s", namespace.Path)
+			return createNamespace(client, namespace, opts)
+		}, tasks[namespace.Parent()])
+	}
+
+	return exec.Run(ctx)
+}
+
+func createNamespace(client *api.Client, namespace *cfg.VaultNamespace, opts PushOptions) error {
+	parent, err := namespacedClient(client, namespace.Parent())
+	if err != nil {
+		return err
+	}
+
+	r := startRecord(parent, "vault_namespace", namespace.Name())
+
+	// only missing namespaces are created, rolling back deletes them again
+	if err := opts.Snapshot.CaptureVaultPathCreated(parent, "sys/namespaces/"+namespace.Name()); err != nil {
+		return r.Fail(err)
+	}
+
+	s, err := parent.Logical().Write("sys/namespaces/"+namespace.Name(), nil)
+	if err != nil {
+		return r.Fail(fmt.Errorf("Could not create namespace %s: %s", namespace.Path, err))
+	}
+
+	printRemoteSecretWarnings(s, r)
+	r.Done(report.ActionCreated)
+	return nil
+}
+
+// planNamespaces checks which namespaces exist in Vault, each namespace is read from its parent
+func planNamespaces(client *api.Client, config *cfg.Config) (plan.Changes, error) {
+	log.Info("  Planning Vault Namespaces")
+
+	changes := plan.Changes{}
+	missing := map[string]bool{}
+
+	for _, path := range config.VaultNamespacePaths() {
+		if path == "" {
+			continue
+		}
+
+		namespace := &cfg.VaultNamespace{Path: path}
+
+		// children of a namespace that will be created can't exist either
+		if missing[namespace.Parent()] {
+			missing[path] = true
+			changes.Add(&plan.Change{Resource: "vault_namespace", Name: path, Action: plan.ActionCreate})
+			continue
+		}
+
+		parent, err := namespacedClient(client, namespace.Parent())
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := parent.Logical().Read("sys/namespaces/" + namespace.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if s == nil {
+			missing[path] = true
+			changes.Add(&plan.Change{Resource: "vault_namespace", Name: path, Action: plan.ActionCreate})
+			continue
+		}
+
+		changes.Add(&plan.Change{Resource: "vault_namespace", Name: path, Action: plan.ActionNoop})
+	}
+
+	return changes, nil
+}
+
+// namespacedClient returns a copy of client which sends the X-Vault-Namespace header on
+// every request, or client itself if namespace is the root namespace
+func namespacedClient(client *api.Client, namespace string) (*api.Client, error) {
+	if namespace == "" {
+		return client, nil
+	}
+
+	clone, err := client.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	// only the api.Config is cloned, so the token and headers have to be copied
+	clone.SetToken(client.Token())
+	clone.SetHeaders(client.Headers())
+	clone.SetNamespace(namespace)
+
+	return clone, nil
+}
+
+// namespacePusher pushes one kind of Vault resource in a single namespace
+type namespacePusher func(ctx context.Context, client *api.Client, config *cfg.Config, opts PushOptions) error
+
+// forEachVaultNamespace calls fn once per namespace, with a client scoped to the namespace
+// and a copy of the config only containing the resources in that namespace
+func forEachVaultNamespace(ctx context.Context, client *api.Client, config *cfg.Config, opts PushOptions, fn namespacePusher) error {
+	failures := opts.failures()
+
+	for _, namespace := range config.VaultNamespacePaths() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		namespaced, err := namespacedClient(client, namespace)
+		if err != nil {
+			return err
+		}
+
+		if namespace != "" {
+			log.Infof("  Namespace %s", namespace)
+		}
+
+		namespaceConfig := config.ForVaultNamespace(namespace)
+		if err := failures.Add(fn(ctx, namespaced, namespaceConfig, namespaceOptions(opts, namespace, namespaceConfig))); err != nil {
+			return err
+		}
+	}
+
+	return failures.Err()
+}
+
+// namespaceOptions returns opts for the namespace with the resources in config. Only namespaces config
+// declares Vault resources in are pruned, so a config that only manages a child namespace doesn't
+// delete everything in the root namespace
+func namespaceOptions(opts PushOptions, namespace string, config *cfg.Config) PushOptions {
+	if !opts.Prune || config.HasVaultResources() {
+		return opts
+	}
+
+	if namespace == "" {
+		namespace = "root"
+	}
+
+	log.Infof("  Not pruning namespace %s, config has no resources in it", namespace)
+	opts.Prune = false
+	return opts
+}
+
+// skipNamespacedAudit returns true if client is scoped to a child namespace without any audit devices
+// in config. Audit devices are usually managed in the root namespace only, and pruning shouldn't
+// touch namespaces that don't configure any.
+func skipNamespacedAudit(client *api.Client, config *cfg.Config) bool {
+	return client.Headers().Get("X-Vault-Namespace") != "" && len(config.VaultAudits) == 0
+}
@@ -0,0 +1,474 @@
+package vault
+
+import (
+	"os"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	"github.com/seatgeek/hashi-helper/command/vault/helper"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// planner compares one kind of Vault resource with the local config. Deletes are only
+// planned with opts.Prune, like a push only deletes with --prune
+type planner func(*api.Client, *config.Config, PushOptions) (plan.Changes, error)
+
+// Plan ...
+func Plan(c *cli.Context) error {
+	config, err := config.NewConfigFromCLI(c)
+	if err != nil {
+		return err
+	}
+
+	changes, err := PlanWithConfig(c, config)
+	if err != nil {
+		return err
+	}
+
+	changes.Print(os.Stdout)
+
+	return plan.ExitCode(c, changes)
+}
+
+// PlanWithConfig will compare the remote Vault state with the local config
+// and return the changes a push would make
+func PlanWithConfig(c *cli.Context, config *config.Config) (plan.Changes, error) {
+	log.Info("Planning Vault changes")
+
+	opts := PushOptions{Prune: c.Bool("prune")}
+	if err := checkPushConfig(config, opts, "Planning"); err != nil {
+		return nil, err
+	}
+
+	root, err := newClient(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return planWithClient(root, config, opts)
+}
+
+// planWithClient compares the Vault state read with the root client with the local config
+func planWithClient(root *api.Client, config *config.Config, opts PushOptions) (plan.Changes, error) {
+	changes, err := planNamespaces(root, config)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := map[string]bool{}
+	for _, change := range changes {
+		missing[change.Name] = change.Action == plan.ActionCreate
+	}
+
+	planners := []planner{
+		planAudit,
+		planAuth,
+		planMounts,
+		planPolicies,
+		planSecrets,
+		planIdentity,
+	}
+
+	for _, namespace := range config.VaultNamespacePaths() {
+		// nothing can be read from a namespace that doesn't exist yet
+		if missing[namespace] {
+			log.Warnf("  Namespace %s does not exist yet, skipping its resources", namespace)
+			continue
+		}
+
+		client, err := namespacedClient(root, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		namespaceConfig := config.ForVaultNamespace(namespace)
+		namespaceOpts := namespaceOptions(opts, namespace, namespaceConfig)
+
+		for _, fn := range planners {
+			result, err := fn(client, namespaceConfig, namespaceOpts)
+			if err != nil {
+				return nil, err
+			}
+
+			if namespace != "" {
+				for _, change := range result {
+					change.Name = namespace + "/" + change.Name
+				}
+			}
+
+			changes.Append(result)
+		}
+	}
+
+	return changes, nil
+}
+
+func planAudit(client *api.Client, config *config.Config, opts PushOptions) (plan.Changes, error) {
+	log.Info("  Planning Vault Audit")
+
+	if skipNamespacedAudit(client, config) {
+		return nil, nil
+	}
+
+	audits, err := client.Sys().ListAudit()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := plan.Changes{}
+
+	for _, audit := range config.VaultAudits {
+		if audit.IsPlaceholder() {
+			continue
+		}
+
+		remote, ok := audits[audit.Path+"/"]
+		if !ok {
+			changes.Add(&plan.Change{
+				Resource: "vault_audit",
+				Name:     audit.Path,
+				Action:   plan.ActionCreate,
+				Fields:   plan.DiffData(nil, audit.ToMap(), false),
+			})
+			continue
+		}
+
+		changes.Add(plan.NewChange("vault_audit", audit.Path, diffAudit(remote, audit)))
+	}
+
+	if opts.Prune {
+		for _, path := range auditsToPrune(audits, config) {
+			changes.Add(&plan.Change{Resource: "vault_audit", Name: path, Action: plan.ActionDelete})
+		}
+	}
+
+	return changes, nil
+}
+
+func planAuth(client *api.Client, config *config.Config, opts PushOptions) (plan.Changes, error) {
+	log.Info("  Planning Vault Auth")
+
+	auths, err := client.Sys().ListAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := plan.Changes{}
+
+	for _, auth := range config.VaultAuths {
+		remote, exists := auths[auth.Name+"/"]
+		readFrom := auth
+		from, err := previousPath(auths, "auth backend", auth.Name, auth.PreviousPaths)
+		if err != nil && !auth.IsPlaceholder() {
+			return nil, err
+		}
+
+		switch {
+		case auth.IsPlaceholder():
+			// managed outside of hashi-helper, only protected from pruning
+		case !exists && from != "":
+			fields, err := planTune(client, "sys/auth/"+from+"/tune", auth.MountSettings, auths[from+"/"])
+			if err != nil {
+				return nil, err
+			}
+
+			changes.Add(&plan.Change{
+				Resource: "vault_auth",
+				Name:     auth.Name,
+				Action:   plan.ActionUpdate,
+				Fields:   append([]plan.FieldChange{{Key: "path", Old: from, New: auth.Name}}, fields...),
+			})
+
+			// the config and roles are moved with it, so they are read from the previous path
+			moved := *auth
+			moved.Name = from
+			readFrom, exists = &moved, true
+		case !exists:
+			changes.Add(&plan.Change{
+				Resource: "vault_auth",
+				Name:     auth.Name,
+				Action:   plan.ActionCreate,
+				Fields:   append([]plan.FieldChange{{Key: "type", New: auth.Type}}, plan.DiffData(nil, auth.TuneData(), false)...),
+			})
+		default:
+			fields, err := planTune(client, "sys/auth/"+auth.Name+"/tune", auth.MountSettings, remote)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(plan.DiffData(map[string]interface{}{"type": remote.Type}, map[string]interface{}{"type": auth.Type}, false), fields...)
+			changes.Add(plan.NewChange("vault_auth", auth.Name, fields))
+		}
+
+		for _, config := range auth.Config {
+			change, err := planLogical(client, "vault_auth_config", authConfigPath(readFrom, config), config.Data, true, exists)
+			if err != nil {
+				return nil, err
+			}
+			change.Name = authConfigPath(auth, config)
+			changes.Add(change)
+		}
+
+		for _, role := range auth.Roles {
+			change, err := planLogical(client, "vault_auth_role", authRolePath(readFrom, role), role.Data, false, exists)
+			if err != nil {
+				return nil, err
+			}
+			change.Name = authRolePath(auth, role)
+			changes.Add(change)
+		}
+
+		for _, amap := range auth.Maps {
+			change, err := planLogical(client, "vault_auth_map", authMapPath(readFrom, amap), amap.Data, false, exists)
+			if err != nil {
+				return nil, err
+			}
+			change.Name = authMapPath(auth, amap)
+			changes.Add(change)
+		}
+	}
+
+	if opts.Prune {
+		for _, path := range authsToPrune(auths, config) {
+			changes.Add(&plan.Change{Resource: "vault_auth", Name: path, Action: plan.ActionDelete})
+		}
+	}
+
+	return changes, nil
+}
+
+func planMounts(client *api.Client, config *config.Config, opts PushOptions) (plan.Changes, error) {
+	log.Info("  Planning Vault Mounts")
+
+	mounts, err := client.Sys().ListMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := plan.Changes{}
+
+	for _, mount := range config.VaultMounts {
+		remote, exists := mounts[mount.Name+"/"]
+		readFrom := mount
+		from, err := previousPath(mounts, "mount", mount.Name, mount.PreviousPaths)
+		if err != nil && !mount.IsPlaceholder() {
+			return nil, err
+		}
+
+		switch {
+		case mount.IsPlaceholder():
+			// managed outside of hashi-helper, only protected from pruning
+		case !exists && from != "":
+			fields, err := planTune(client, "sys/mounts/"+from+"/tune", mount.MountSettings, mounts[from+"/"])
+			if err != nil {
+				return nil, err
+			}
+
+			changes.Add(&plan.Change{
+				Resource: "vault_mount",
+				Name:     mount.Name,
+				Action:   plan.ActionUpdate,
+				Fields:   append([]plan.FieldChange{{Key: "path", Old: from, New: mount.Name}}, fields...),
+			})
+
+			// the config and roles are moved with it, so they are read from the previous path
+			moved := *mount
+			moved.Name = from
+			readFrom, exists = &moved, true
+		case !exists:
+			changes.Add(&plan.Change{
+				Resource: "vault_mount",
+				Name:     mount.Name,
+				Action:   plan.ActionCreate,
+				Fields:   append([]plan.FieldChange{{Key: "type", New: mount.Type}}, plan.DiffData(nil, mount.TuneData(), false)...),
+			})
+		default:
+			fields, err := planTune(client, "sys/mounts/"+mount.Name+"/tune", mount.MountSettings, remote)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(plan.DiffData(map[string]interface{}{"type": remote.Type}, map[string]interface{}{"type": mount.Type}, false), fields...)
+			changes.Add(plan.NewChange("vault_mount", mount.Name, fields))
+		}
+
+		for _, config := range mount.Config {
+			change, err := planLogical(client, "vault_mount_config", mountConfigPath(readFrom, config), config.Data, true, exists)
+			if err != nil {
+				return nil, err
+			}
+			change.Name = mountConfigPath(mount, config)
+			changes.Add(change)
+		}
+
+		for _, role := range mount.Roles {
+			change, err := planLogical(client, "vault_mount_role", mountRolePath(readFrom, role), role.Data, false, exists)
+			if err != nil {
+				return nil, err
+			}
+			change.Name = mountRolePath(mount, role)
+			changes.Add(change)
+		}
+	}
+
+	if opts.Prune {
+		for _, path := range mountsToPrune(mounts, config) {
+			changes.Add(&plan.Change{Resource: "vault_mount", Name: path, Action: plan.ActionDelete})
+		}
+	}
+
+	return changes, nil
+}
+
+func planPolicies(client *api.Client, config *config.Config, opts PushOptions) (plan.Changes, error) {
+	log.Info("  Planning Vault Policies")
+
+	remotePolicies, err := client.Sys().ListPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := plan.Changes{}
+
+	for _, policy := range config.VaultPolicies {
+		if policy.IsPlaceholder() {
+			continue
+		}
+
+		remote, err := client.Sys().GetPolicy(policy.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if remote == "" {
+			changes.Add(&plan.Change{
+				Resource: "vault_policy",
+				Name:     policy.Name,
+				Action:   plan.ActionCreate,
+				Fields:   []plan.FieldChange{{Key: "rules", New: policy.Raw}},
+			})
+			continue
+		}
+
+		fields := make([]plan.FieldChange, 0)
+		if normalizePolicy(remote) != normalizePolicy(policy.Raw) {
+			fields = append(fields, plan.FieldChange{Key: "rules", Old: remote, New: policy.Raw})
+		}
+
+		changes.Add(plan.NewChange("vault_policy", policy.Name, fields))
+	}
+
+	if opts.Prune {
+		for _, name := range policiesToPrune(remotePolicies, config) {
+			changes.Add(&plan.Change{Resource: "vault_policy", Name: name, Action: plan.ActionDelete})
+		}
+	}
+
+	return changes, nil
+}
+
+func planSecrets(client *api.Client, config *config.Config, opts PushOptions) (plan.Changes, error) {
+	log.Info("  Planning Vault Secrets")
+
+	changes := plan.Changes{}
+
+	kvMounts, err := helper.LoadKVMounts(client)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range config.VaultSecrets {
+		path := helper.SecretPath(secret)
+
+		remote, err := kvMounts.ReadSecret(client, path, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if remote == nil || remote.Data == nil {
+			changes.Add(&plan.Change{
+				Resource: "vault_secret",
+				Name:     path,
+				Action:   plan.ActionCreate,
+				Fields:   plan.DiffData(nil, secret.VaultSecret.Data, true),
+			})
+			continue
+		}
+
+		fields := plan.DiffDataStrict(remote.Data, secret.VaultSecret.Data, true)
+
+		if kvMounts.IsV2(path) && secret.KVOptions.HasMetadata() {
+			metadata, err := planKVMetadata(client, kvMounts.MetadataPath(path), secret.KVOptions)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, metadata...)
+		}
+
+		changes.Add(plan.NewChange("vault_secret", path, fields))
+	}
+
+	return changes, nil
+}
+
+// planKVMetadata compares max_versions and custom_metadata of a KV version 2 secret
+func planKVMetadata(client *api.Client, path string, options *config.KVOptions) ([]plan.FieldChange, error) {
+	remote, err := client.Logical().Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]interface{}{}
+	if remote != nil {
+		current = remote.Data
+	}
+
+	return plan.DiffData(current, helper.KVMetadata(options), false), nil
+}
+
+// planTune compares the tunable settings of an existing mount or auth backend with the ones read from tunePath
+func planTune(client *api.Client, tunePath string, settings config.MountSettings, remote *api.MountOutput) ([]plan.FieldChange, error) {
+	if changed := settings.ImmutableChanges(remote); len(changed) > 0 {
+		log.Warnf("  %s of %s can only be changed by enabling it again", strings.Join(changed, ", "), strings.TrimSuffix(tunePath, "/tune"))
+	}
+
+	data := settings.TuneData()
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	current, err := client.Logical().Read(tunePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == nil {
+		return plan.DiffData(nil, data, false), nil
+	}
+
+	return plan.DiffDataForWrite(current.Data, data, false), nil
+}
+
+// planLogical reads a generic logical path (config, role, map) and compare it with the desired data
+func planLogical(client *api.Client, resource, path string, data map[string]interface{}, sensitive, parentExists bool) (*plan.Change, error) {
+	// if the mount or auth backend doesn't exist yet, there is nothing to read
+	if !parentExists {
+		return &plan.Change{Resource: resource, Name: path, Action: plan.ActionCreate, Fields: plan.DiffData(nil, data, sensitive)}, nil
+	}
+
+	remote, err := client.Logical().Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if remote == nil || remote.Data == nil {
+		return &plan.Change{Resource: resource, Name: path, Action: plan.ActionCreate, Fields: plan.DiffData(nil, data, sensitive)}, nil
+	}
+
+	// compared like a push does, so fields Vault doesn't return (e.g. passwords) are planned as a change
+	return plan.NewChange(resource, path, plan.DiffDataForWrite(remote.Data, data, sensitive)), nil
+}
@@ -1,9 +1,16 @@
 package vault
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/printer"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/report"
 	"github.com/seatgeek/hashi-helper/config"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
@@ -21,36 +28,142 @@ func PoliciesPush(c *cli.Context) error {
 
 // PoliciesPushWithConfig ...
 func PoliciesPushWithConfig(c *cli.Context, config *config.Config) error {
+	return pushWithCLI(c, config, PushPolicies)
+}
+
+// PushPolicies will create, update and optionally prune the policies in all Vault namespaces
+func PushPolicies(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
 	log.Info("Pushing Vault Policies")
 
-	env := c.GlobalString("environment")
-	if env == "" {
-		return fmt.Errorf("Pushing policies require a 'environment' value (--environment or ENV[ENVIRONMENT])")
+	if err := checkPushConfig(config, opts, "Pushing policies"); err != nil {
+		return err
 	}
 
-	if !config.Environments.Contains(env) {
-		return fmt.Errorf("Could not find any environment with name %s in configuration", env)
-	}
+	return forEachVaultNamespace(ctx, client, config, opts, pushNamespacePolicies)
+}
 
-	client, err := api.NewClient(nil)
+// pushNamespacePolicies writes the policies of a single Vault namespace
+func pushNamespacePolicies(ctx context.Context, client *api.Client, config *config.Config, opts PushOptions) error {
+	remotePolicies, err := client.Sys().ListPolicies()
 	if err != nil {
 		return err
 	}
 
+	exec := opts.executor()
+
 	for _, policy := range config.VaultPolicies {
-		var policyName, policyContent string
+		if policy.IsPlaceholder() {
+			log.Debugf("Policy %s is a prevent_destroy placeholder, skipping", policy.Name)
+			continue
+		}
+
+		policy := policy
+		exec.Add("vault_policy", policy.Name, func() error {
+			return writePolicy(client, policy, contains(remotePolicies, policy.Name), opts)
+		})
+	}
 
-		policyName = policy.Name
-		policyContent = policy.Raw
+	failures := opts.failures()
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
 
-		log.Printf("Writing policy %s", policyName)
-		log.Debugf("  content: %s", policyContent)
+	if !opts.Prune {
+		return failures.Err()
+	}
 
-		err := client.Sys().PutPolicy(policyName, policyContent)
+	names := policiesToPrune(remotePolicies, config)
+	if !confirmPrune(opts, "policies", names) {
+		return failures.Err()
+	}
+
+	exec = opts.executor()
+	for _, name := range names {
+		name := name
+		exec.Add("vault_policy", name, func() error {
+			log.Printf("Deleting policy %s", name)
+			return deletePolicy(client, name, opts)
+		})
+	}
+
+	if err := failures.Add(exec.Run(ctx)); err != nil {
+		return err
+	}
+
+	return failures.Err()
+}
+
+// writePolicy writes policy, unless the existing policy only differs in formatting. With Force it's always written
+func writePolicy(client *api.Client, policy *config.Policy, exists bool, opts PushOptions) error {
+	r := startRecord(client, "vault_policy", policy.Name)
+	if policy.Application != nil {
+		r.App(policy.Application.Name)
+	}
+
+	if exists && !opts.Force {
+		remote, err := client.Sys().GetPolicy(policy.Name)
 		if err != nil {
-			log.Panic(err)
+			return r.Fail(err)
 		}
+
+		if normalizePolicy(remote) == normalizePolicy(policy.Raw) {
+			log.Debugf("  Policy %s is up to date", policy.Name)
+			r.Done(report.ActionUnchanged)
+			return nil
+		}
+	}
+
+	log.Printf("Writing policy %s", policy.Name)
+	log.Debugf("  content: %s", policy.Raw)
+
+	if err := opts.Snapshot.CaptureVaultPolicy(client, policy.Name); err != nil {
+		return r.Fail(err)
+	}
+
+	if err := client.Sys().PutPolicy(policy.Name, policy.Raw); err != nil {
+		return r.Fail(err)
+	}
+
+	if exists {
+		r.Done(report.ActionUpdated)
+	} else {
+		r.Done(report.ActionCreated)
+	}
+
+	return nil
+}
+
+func deletePolicy(client *api.Client, name string, opts PushOptions) error {
+	r := startRecord(client, "vault_policy", name)
+	if err := opts.Snapshot.CaptureVaultPolicy(client, name); err != nil {
+		return r.Fail(err)
+	}
+
+	if err := client.Sys().DeletePolicy(name); err != nil {
+		return r.Fail(err)
 	}
 
+	r.Done(report.ActionDeleted)
 	return nil
 }
+
+// normalizePolicy re-prints policy rules through the HCL printer, so policies that only
+// differ in formatting (or were written as JSON) compare equal
+func normalizePolicy(rules string) string {
+	root, err := hcl.Parse(rules)
+	if err != nil {
+		return strings.TrimSpace(rules)
+	}
+
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return strings.TrimSpace(rules)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := printer.Fprint(buf, list); err != nil {
+		return strings.TrimSpace(rules)
+	}
+
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,91 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/seatgeek/hashi-helper/config"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// PolicyCheck evaluates local policies with Vault's matching rules, and explains which path granted or denied access
+func PolicyCheck(c *cli.Context) error {
+	names := c.StringSlice("policy")
+	paths := c.StringSlice("path")
+	capabilities := c.StringSlice("capability")
+
+	if len(names) == 0 {
+		return fmt.Errorf("At least one --policy is required")
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("At least one --path is required")
+	}
+	if len(capabilities) == 0 {
+		capabilities = []string{config.ReadCapability}
+	}
+
+	local, err := config.Load(config.OptionsFromCLI(c))
+	if err != nil {
+		return err
+	}
+
+	policies, err := findPolicies(local, c.String("namespace"), names)
+	if err != nil {
+		return err
+	}
+
+	acl := config.NewACL(policies...)
+	denied := false
+
+	for _, path := range paths {
+		for _, capability := range capabilities {
+			result, err := acl.Check(path, capability)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(result.Explain())
+			if !result.Allowed {
+				denied = true
+			}
+		}
+	}
+
+	if denied {
+		return cli.NewExitError("", 1)
+	}
+
+	return nil
+}
+
+// findPolicies returns the local policies with names in the Vault namespace
+func findPolicies(c *config.Config, namespace string, names []string) ([]*config.Policy, error) {
+	result := make([]*config.Policy, 0, len(names))
+
+	for _, name := range names {
+		// root isn't defined in config, but is known to the ACL
+		if name == "root" {
+			result = append(result, &config.Policy{Name: name})
+			continue
+		}
+
+		var found *config.Policy
+		for _, policy := range c.VaultPolicies {
+			if policy.Name != name || policy.Namespace != namespace {
+				continue
+			}
+
+			if found != nil {
+				return nil, fmt.Errorf("Policy %s is defined more than once, use --environment to select one", name)
+			}
+			found = policy
+		}
+
+		if found == nil {
+			return nil, fmt.Errorf("Unknown policy %s in namespace %q", name, namespace)
+		}
+
+		result = append(result, found)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package vault
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/vault/helper"
+	"github.com/seatgeek/hashi-helper/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// protectedPolicies are created by Vault itself and can never be pruned
+var protectedPolicies = []string{"root", "default"}
+
+// protectedPaths are mounts and auth backends created by Vault itself and can never be pruned
+var protectedPaths = []string{"token/", "sys/", "cubbyhole/", "identity/"}
+
+// confirmPrune will list the resources about to be deleted and ask for confirmation,
+// unless opts has no ConfirmPrune (--yes was provided)
+func confirmPrune(opts PushOptions, kind string, names []string) bool {
+	if len(names) == 0 {
+		return false
+	}
+
+	log.Warnf("The following %s exist in Vault but not in config, and will be deleted:", kind)
+	for _, name := range names {
+		log.Warnf("  - %s", name)
+	}
+
+	if opts.ConfirmPrune == nil {
+		return true
+	}
+
+	return opts.ConfirmPrune(kind, names)
+}
+
+// policiesToPrune returns remote policies not found in config
+func policiesToPrune(remote []string, config *config.Config) []string {
+	seen := make(map[string]bool)
+	for _, policy := range config.VaultPolicies {
+		seen[policy.Name] = true
+	}
+
+	result := make([]string, 0)
+	for _, name := range remote {
+		if !seen[name] && !contains(protectedPolicies, name) {
+			result = append(result, name)
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// mountsToPrune returns remote mounts not found in config
+func mountsToPrune(remote map[string]*api.MountOutput, config *config.Config) []string {
+	seen := make(map[string]bool)
+	for _, mount := range config.VaultMounts {
+		seen[mount.Name+"/"] = true
+
+		// previous paths are moved by the push, or refused while the mount exists at both paths
+		for _, path := range mount.PreviousPaths {
+			seen[path+"/"] = true
+		}
+	}
+
+	// mounts used as target for secrets are managed implicitly
+	for _, secret := range config.VaultSecrets {
+		path := helper.SecretPath(secret)
+		for name := range remote {
+			if strings.HasPrefix(path, name) {
+				seen[name] = true
+			}
+		}
+	}
+
+	return pathsToPrune(remote, seen)
+}
+
+// authsToPrune returns remote auth backends not found in config
+func authsToPrune(remote map[string]*api.AuthMount, config *config.Config) []string {
+	seen := make(map[string]bool)
+	for _, auth := range config.VaultAuths {
+		seen[auth.Name+"/"] = true
+
+		for _, path := range auth.PreviousPaths {
+			seen[path+"/"] = true
+		}
+	}
+
+	return pathsToPrune(remote, seen)
+}
+
+// auditsToPrune returns remote audit devices not found in config
+func auditsToPrune(remote map[string]*api.Audit, config *config.Config) []string {
+	seen := make(map[string]bool)
+	for _, audit := range config.VaultAudits {
+		seen[audit.Path+"/"] = true
+	}
+
+	result := make([]string, 0)
+	for path := range remote {
+		if !seen[path] {
+			result = append(result, strings.TrimSuffix(path, "/"))
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func pathsToPrune(remote map[string]*api.MountOutput, seen map[string]bool) []string {
+	result := make([]string, 0)
+	for path := range remote {
+		if !seen[path] && !contains(protectedPaths, path) {
+			result = append(result, strings.TrimSuffix(path, "/"))
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
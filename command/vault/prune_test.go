@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushPolicies_prune(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		deleted []string
+	}{
+		{
+			name: "namespace only config doesn't prune root",
+			config: `
+			environment "production" {
+				namespace "team-a" {
+					policy "team" {
+						path "secret/*" {
+							capabilities = ["read"]
+						}
+					}
+				}
+			}`,
+			deleted: []string{"team-a/old"},
+		},
+		{
+			name: "root is pruned once config declares resources in it",
+			config: `
+			environment "production" {
+				policy "admin" {
+					path "*" {
+						capabilities = ["sudo"]
+					}
+				}
+
+				namespace "team-a" {
+					policy "team" {
+						path "secret/*" {
+							capabilities = ["read"]
+						}
+					}
+				}
+			}`,
+			deleted: []string{"/ops", "team-a/old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := map[string][]string{
+				"":       {"default", "root", "admin", "ops"},
+				"team-a": {"default", "old"},
+			}
+
+			var lock sync.Mutex
+			var deleted []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				namespace := r.Header.Get("X-Vault-Namespace")
+
+				switch {
+				case r.Method == http.MethodGet && r.URL.Query().Get("list") == "true":
+					json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"keys": remote[namespace]}})
+				case r.Method == http.MethodGet:
+					json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"name": filepath.Base(r.URL.Path), "policy": ""}})
+				case r.Method == http.MethodDelete:
+					lock.Lock()
+					deleted = append(deleted, namespace+"/"+filepath.Base(r.URL.Path))
+					lock.Unlock()
+					w.WriteHeader(http.StatusNoContent)
+				default:
+					w.WriteHeader(http.StatusNoContent)
+				}
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "policies.hcl"), []byte(tt.config), 0644))
+
+			cfg, err := config.Load(config.Options{Dirs: []string{dir}, Environment: "production"})
+			require.NoError(t, err)
+
+			client, err := api.NewClient(&api.Config{Address: server.URL})
+			require.NoError(t, err)
+
+			require.NoError(t, PushPolicies(context.Background(), cfg, client, PushOptions{Prune: true}))
+			require.Equal(t, tt.deleted, deleted)
+		})
+	}
+}
@@ -0,0 +1,550 @@
+package vault
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
+	log "github.com/sirupsen/logrus"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+// mountConfigNames are the well-known config endpoints for secret backends
+// that don't support listing their config (database mounts are listed instead)
+var mountConfigNames = map[string][]string{
+	"aws":      {"root", "lease"},
+	"consul":   {"access"},
+	"nomad":    {"access", "lease"},
+	"rabbitmq": {"connection", "lease"},
+	"pki":      {"urls", "crl"},
+}
+
+// mountTypesWithRoles are secret backends that have roles at <mount>/roles/. totp keys live at
+// <mount>/keys/ and can't be read back, so they are not pulled
+var mountTypesWithRoles = []string{"aws", "consul", "database", "nomad", "pki", "rabbitmq", "ssh"}
+
+// authConfigNames are the well-known config endpoints for auth backends
+var authConfigNames = map[string][]string{
+	"approle":    {},
+	"aws":        {"client"},
+	"github":     {""},
+	"gcp":        {""},
+	"jwt":        {""},
+	"kubernetes": {""},
+	"ldap":       {""},
+	"oidc":       {""},
+	"okta":       {""},
+	"radius":     {""},
+}
+
+// authTypesWithRoles are auth backends that have roles at auth/<name>/role/
+var authTypesWithRoles = []string{"approle", "aws", "gcp", "jwt", "kubernetes", "oidc", "token"}
+
+// authMapNames are auth backends that support auth/<name>/map/<map>/
+var authMapNames = map[string][]string{
+	"github": {"teams", "users"},
+}
+
+// PullAll will write all mounts, auth backends, policies and audit devices from
+// the root namespace of the remote Vault server to the first --config-dir
+func PullAll(c *cli.Context) error {
+	log.Info("Pulling Vault configuration")
+
+	env := c.GlobalString("environment")
+	if env == "" {
+		return fmt.Errorf("Pulling require a 'environment' value (--environment or ENV[ENVIRONMENT])")
+	}
+
+	dirs := c.GlobalStringSlice("config-dir")
+	if len(dirs) == 0 {
+		return fmt.Errorf("Pulling require a '--config-dir' to write files into")
+	}
+	dir := filepath.Join(dirs[0], env)
+
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	puller := &puller{
+		client:      client,
+		environment: env,
+		directory:   dir,
+		overwrite:   c.Bool("overwrite"),
+	}
+
+	return puller.pullAll()
+}
+
+type puller struct {
+	client      *api.Client
+	environment string
+	directory   string
+	overwrite   bool
+}
+
+// pullAll pulls the namespace of the client, child namespaces are not pulled
+func (p *puller) pullAll() error {
+	if err := p.pullMounts(); err != nil {
+		return err
+	}
+
+	if err := p.pullAuths(); err != nil {
+		return err
+	}
+
+	if err := p.pullPolicies(); err != nil {
+		return err
+	}
+
+	return p.pullAudits()
+}
+
+func (p *puller) pullMounts() error {
+	log.Info("  Pulling Vault mounts")
+
+	mounts, err := p.client.Sys().ListMounts()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range sortedPaths(mounts) {
+		if contains(protectedPaths, path) {
+			continue
+		}
+
+		remote := mounts[path]
+		mount := &config.Mount{
+			MountSettings: mountSettings(remote),
+			Name:          strings.TrimSuffix(path, "/"),
+			Type:          remote.Type,
+		}
+
+		if mount.Config, err = p.readMountConfig(mount); err != nil {
+			return err
+		}
+
+		if mount.Roles, err = p.readMountRoles(mount); err != nil {
+			return err
+		}
+
+		w := p.newEnvironmentWriter()
+		w.Block("mount", mount.Name)
+		w.Attribute("type", mount.Type)
+		writeMountSettings(w, mount.MountSettings)
+
+		for _, config := range mount.Config {
+			w.Comment("write-only fields (passwords, secret keys) are not returned by Vault and must be added manually")
+			w.Block("config", config.Name)
+			w.Attributes(config.Data)
+			w.End()
+		}
+
+		for _, role := range mount.Roles {
+			w.Block("role", role.Name)
+			w.Attributes(role.Data)
+			w.End()
+		}
+
+		w.End()
+		w.End()
+
+		if err := p.write(w, "mounts", mount.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *puller) readMountConfig(mount *config.Mount) ([]*config.MountConfig, error) {
+	names := mountConfigNames[mount.Type]
+
+	// database connections can be listed
+	if mount.Type == "database" {
+		var err error
+		if names, err = p.list(mount.Name + "/config"); err != nil {
+			return nil, err
+		}
+	}
+
+	configs := make([]*config.MountConfig, 0)
+	for _, name := range names {
+		cfg := &config.MountConfig{Name: name}
+
+		data, err := p.read(mountConfigPath(mount, cfg))
+		if err != nil {
+			return nil, err
+		}
+
+		if data == nil {
+			continue
+		}
+
+		// database connection details are returned nested, but written flat
+		if details, ok := data["connection_details"].(map[string]interface{}); ok {
+			delete(data, "connection_details")
+			for k, v := range details {
+				data[k] = v
+			}
+		}
+
+		cfg.Data = data
+		configs = append(configs, cfg)
+	}
+
+	return configs, nil
+}
+
+func (p *puller) readMountRoles(mount *config.Mount) (config.MountRoles, error) {
+	roles := config.MountRoles{}
+	if !contains(mountTypesWithRoles, mount.Type) {
+		return roles, nil
+	}
+
+	listPath := filepath.Dir(mountRolePath(mount, &config.MountRole{Name: "x"}))
+	names, err := p.list(listPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		role := &config.MountRole{Name: name}
+
+		data, err := p.read(mountRolePath(mount, role))
+		if err != nil {
+			return nil, err
+		}
+
+		if data != nil {
+			role.Data = data
+			roles.Add(role)
+		}
+	}
+
+	return roles, nil
+}
+
+func (p *puller) pullAuths() error {
+	log.Info("  Pulling Vault auth backends")
+
+	auths, err := p.client.Sys().ListAuth()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range sortedPaths(auths) {
+		if contains(protectedPaths, path) {
+			continue
+		}
+
+		remote := auths[path]
+		auth := &config.Auth{
+			MountSettings: mountSettings(remote),
+			Name:          strings.TrimSuffix(path, "/"),
+			Type:          remote.Type,
+		}
+
+		w := p.newEnvironmentWriter()
+		w.Block("auth", auth.Name)
+		w.Attribute("type", auth.Type)
+		writeMountSettings(w, auth.MountSettings)
+
+		for _, name := range authConfigNames[auth.Type] {
+			cfg := &config.AuthConfig{Name: name}
+
+			data, err := p.read(authConfigPath(auth, cfg))
+			if err != nil {
+				return err
+			}
+
+			if data != nil {
+				w.Comment("write-only fields (passwords, secret keys) are not returned by Vault and must be added manually")
+				w.Block("config", name)
+				w.Attributes(data)
+				w.End()
+			}
+		}
+
+		if contains(authTypesWithRoles, auth.Type) {
+			listPath := filepath.Dir(authRolePath(auth, &config.AuthRole{Name: "x"}))
+			names, err := p.list(listPath)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				data, err := p.read(authRolePath(auth, &config.AuthRole{Name: name}))
+				if err != nil {
+					return err
+				}
+
+				if data != nil {
+					w.Block("role", name)
+					w.Attributes(data)
+					w.End()
+				}
+			}
+		}
+
+		for _, mapName := range authMapNames[auth.Type] {
+			names, err := p.list(fmt.Sprintf("auth/%s/map/%s", auth.Name, mapName))
+			if err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				amap := &config.AuthMap{Name: mapName + "/" + name}
+
+				data, err := p.read(authMapPath(auth, amap))
+				if err != nil {
+					return err
+				}
+
+				// the map key is part of the path, only the value can be written
+				if data != nil {
+					w.Block("map", amap.Name)
+					w.Attribute("value", data["value"])
+					w.End()
+				}
+			}
+		}
+
+		w.End()
+		w.End()
+
+		if err := p.write(w, "auth", auth.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *puller) pullPolicies() error {
+	log.Info("  Pulling Vault policies")
+
+	names, err := p.client.Sys().ListPolicies()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// policies created by Vault itself are not managed in config
+		if contains(protectedPolicies, name) {
+			continue
+		}
+
+		raw, err := p.client.Sys().GetPolicy(name)
+		if err != nil {
+			return err
+		}
+
+		// Vault accepts both HCL and JSON policies, re-print them as HCL
+		if _, err := hcl.Parse(raw); err != nil {
+			return fmt.Errorf("Could not parse policy %s: %s", name, err)
+		}
+
+		w := p.newEnvironmentWriter()
+		w.Block("policy", name)
+		w.Raw(normalizePolicy(raw))
+		w.End()
+		w.End()
+
+		if err := p.write(w, "policies", name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *puller) pullAudits() error {
+	log.Info("  Pulling Vault audit devices")
+
+	audits, err := p.client.Sys().ListAudit()
+	if err != nil {
+		return err
+	}
+
+	if len(audits) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(audits))
+	for path := range audits {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	w := p.newEnvironmentWriter()
+	for _, path := range paths {
+		audit := audits[path]
+
+		w.Block("audit", strings.TrimSuffix(path, "/"))
+		w.Attribute("type", audit.Type)
+		writeNonEmpty(w, "description", audit.Description)
+		if audit.Local {
+			w.Attribute("local", true)
+		}
+		if len(audit.Options) > 0 {
+			w.Attribute("options", audit.Options)
+		}
+		w.End()
+	}
+	w.End()
+
+	return p.write(w, "", "audit")
+}
+
+func (p *puller) newEnvironmentWriter() *support.HCLWriter {
+	w := support.NewHCLWriter()
+	w.Comment("Generated by hashi-helper vault-pull-all")
+	w.Block("environment", p.environment)
+	return w
+}
+
+func (p *puller) write(w *support.HCLWriter, kind, name string) error {
+	file := filepath.Join(p.directory, kind, strings.Replace(name, "/", "_", -1)+".hcl")
+	if err := w.WriteFile(file, p.overwrite); err != nil {
+		return err
+	}
+
+	log.Infof("    Wrote file: %s", file)
+	return nil
+}
+
+// list returns the keys at path, or nothing if the path doesn't exist
+func (p *puller) list(path string) ([]string, error) {
+	secret, err := p.client.Logical().List(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	keys, ok := secret.Data["keys"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key.(string))
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// read returns the data at path without empty values, or nil if the path doesn't exist
+func (p *puller) read(path string) (map[string]interface{}, error) {
+	secret, err := p.client.Logical().Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	data := make(map[string]interface{}, len(secret.Data))
+	for k, v := range secret.Data {
+		if !support.IsEmptyValue(v) {
+			data[k] = v
+		}
+	}
+
+	return data, nil
+}
+
+// mountSettings returns the settings of a remote mount or auth backend, leaving out Vault defaults
+func mountSettings(remote *api.MountOutput) config.MountSettings {
+	settings := config.MountSettings{
+		Description:               remote.Description,
+		ForceNoCache:              remote.Config.ForceNoCache,
+		Options:                   remote.Options,
+		SealWrap:                  remote.SealWrap,
+		Local:                     remote.Local,
+		AuditNonHMACRequestKeys:   remote.Config.AuditNonHMACRequestKeys,
+		AuditNonHMACResponseKeys:  remote.Config.AuditNonHMACResponseKeys,
+		ListingVisibility:         remote.Config.ListingVisibility,
+		PassthroughRequestHeaders: remote.Config.PassthroughRequestHeaders,
+		AllowedResponseHeaders:    remote.Config.AllowedResponseHeaders,
+	}
+
+	if remote.Config.DefaultLeaseTTL > 0 {
+		settings.DefaultLeaseTTL = fmt.Sprintf("%ds", remote.Config.DefaultLeaseTTL)
+	}
+
+	if remote.Config.MaxLeaseTTL > 0 {
+		settings.MaxLeaseTTL = fmt.Sprintf("%ds", remote.Config.MaxLeaseTTL)
+	}
+
+	if remote.Config.TokenType != "default-service" {
+		settings.TokenType = remote.Config.TokenType
+	}
+
+	return settings
+}
+
+func writeMountSettings(w *support.HCLWriter, settings config.MountSettings) {
+	writeNonEmpty(w, "description", settings.Description)
+	writeNonEmpty(w, "default_lease_ttl", settings.DefaultLeaseTTL)
+	writeNonEmpty(w, "max_lease_ttl", settings.MaxLeaseTTL)
+
+	bools := []struct {
+		key   string
+		value bool
+	}{
+		{"force_no_cache", settings.ForceNoCache},
+		{"seal_wrap", settings.SealWrap},
+		{"local", settings.Local},
+	}
+	for _, b := range bools {
+		if b.value {
+			w.Attribute(b.key, true)
+		}
+	}
+
+	if len(settings.Options) > 0 {
+		w.Attribute("options", settings.Options)
+	}
+
+	writeNonEmptyList(w, "audit_non_hmac_request_keys", settings.AuditNonHMACRequestKeys)
+	writeNonEmptyList(w, "audit_non_hmac_response_keys", settings.AuditNonHMACResponseKeys)
+	writeNonEmpty(w, "listing_visibility", settings.ListingVisibility)
+	writeNonEmptyList(w, "passthrough_request_headers", settings.PassthroughRequestHeaders)
+	writeNonEmptyList(w, "allowed_response_headers", settings.AllowedResponseHeaders)
+	writeNonEmpty(w, "token_type", settings.TokenType)
+}
+
+func writeNonEmptyList(w *support.HCLWriter, key string, value []string) {
+	if len(value) > 0 {
+		w.Attribute(key, value)
+	}
+}
+
+func writeNonEmpty(w *support.HCLWriter, key, value string) {
+	if value != "" {
+		w.Attribute(key, value)
+	}
+}
+
+func sortedPaths(m map[string]*api.MountOutput) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
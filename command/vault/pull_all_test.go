@@ -0,0 +1,157 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/plan"
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullAll_roundTrip(t *testing.T) {
+	// the responses of a Vault server, keyed by path without /v1/
+	reads := map[string]interface{}{
+		"sys/mounts": map[string]interface{}{
+			"cubbyhole/": map[string]interface{}{"type": "cubbyhole", "config": map[string]interface{}{}},
+			"identity/":  map[string]interface{}{"type": "identity", "config": map[string]interface{}{}},
+			"sys/":       map[string]interface{}{"type": "system", "config": map[string]interface{}{}},
+			"secret/": map[string]interface{}{
+				"type":        "kv",
+				"description": `app "secrets" ${not} interpolated`,
+				"options":     map[string]interface{}{"version": "1"},
+				"config":      map[string]interface{}{"default_lease_ttl": 0, "max_lease_ttl": 0},
+			},
+			"ssh/": map[string]interface{}{
+				"type":   "ssh",
+				"config": map[string]interface{}{"default_lease_ttl": 3600, "max_lease_ttl": 86400, "listing_visibility": "unauth"},
+			},
+		},
+		"sys/mounts/secret/tune": map[string]interface{}{
+			"description":       `app "secrets" ${not} interpolated`,
+			"default_lease_ttl": 2764800,
+			"max_lease_ttl":     2764800,
+			"force_no_cache":    false,
+			"options":           map[string]interface{}{"version": "1"},
+		},
+		"sys/mounts/ssh/tune": map[string]interface{}{
+			"description":        "",
+			"default_lease_ttl":  3600,
+			"max_lease_ttl":      86400,
+			"force_no_cache":     false,
+			"listing_visibility": "unauth",
+		},
+		"ssh/roles/otp": map[string]interface{}{
+			"key_type":     "otp",
+			"default_user": "ubuntu",
+			"cidr_list":    "10.0.0.0/8",
+			"port":         22,
+			"exclude_list": "",
+		},
+		"sys/auth": map[string]interface{}{
+			"token/": map[string]interface{}{"type": "token", "config": map[string]interface{}{}},
+			"approle/": map[string]interface{}{
+				"type":        "approle",
+				"description": "apps",
+				"config":      map[string]interface{}{"token_type": "default-service"},
+			},
+		},
+		"sys/auth/approle/tune": map[string]interface{}{
+			"description":       "apps",
+			"default_lease_ttl": 2764800,
+			"max_lease_ttl":     2764800,
+			"force_no_cache":    false,
+			"token_type":        "default-service",
+		},
+		"auth/approle/role/app": map[string]interface{}{
+			"token_ttl":          3600,
+			"token_policies":     []string{"app"},
+			"bind_secret_id":     true,
+			"secret_id_num_uses": 0,
+			"token_bound_cidrs":  []string{},
+		},
+		"sys/policies/acl/app": map[string]interface{}{
+			"name":   "app",
+			"policy": "path \"secret/app/*\" {\n  capabilities = [\"read\", \"list\"]\n}\n",
+		},
+		"sys/audit": map[string]interface{}{
+			"file/": map[string]interface{}{
+				"type":    "file",
+				"path":    "file/",
+				"options": map[string]interface{}{"file_path": "/var/log/vault/audit.log"},
+			},
+		},
+	}
+
+	lists := map[string][]string{
+		"ssh/roles":         {"otp"},
+		"auth/approle/role": {"app"},
+		"sys/policies/acl":  {"app", "default", "root"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v1/")
+
+		if r.Method != http.MethodGet && r.Method != "LIST" {
+			t.Errorf("unexpected %s %s, pulling and planning must not write", r.Method, path)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var data interface{}
+		var ok bool
+		if r.Method == "LIST" || r.URL.Query().Get("list") == "true" {
+			var keys []string
+			keys, ok = lists[path]
+			data = map[string]interface{}{"keys": keys}
+		} else {
+			data, ok = reads[path]
+		}
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	client, err := api.NewClient(&api.Config{Address: server.URL})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	puller := &puller{client: client, environment: "production", directory: filepath.Join(dir, "production")}
+	require.NoError(t, puller.pullAll())
+
+	for _, file := range []string{"mounts/secret.hcl", "mounts/ssh.hcl", "auth/approle.hcl", "policies/app.hcl", "audit.hcl"} {
+		require.FileExists(t, filepath.Join(dir, "production", file))
+	}
+
+	cfg, err := config.Load(config.Options{Dirs: []string{dir}, Environment: "production"})
+	require.NoError(t, err)
+	require.Len(t, cfg.VaultMounts, 2)
+	require.Len(t, cfg.VaultAuths, 1)
+	require.Len(t, cfg.VaultPolicies, 1)
+	require.Len(t, cfg.VaultAudits, 1)
+
+	changes, err := planWithClient(client, cfg, PushOptions{Prune: true})
+	require.NoError(t, err)
+
+	// every pulled resource is planned, and nothing is left to prune
+	require.Len(t, changes, 7)
+
+	changed := make([]string, 0)
+	for _, change := range changes {
+		if change.Action != plan.ActionNoop {
+			changed = append(changed, string(change.Action)+" "+change.Resource+" "+change.Name)
+		}
+	}
+	require.Empty(t, changed)
+}
@@ -1,40 +1,182 @@
 package vault
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/seatgeek/hashi-helper/command/executor"
+	"github.com/seatgeek/hashi-helper/command/report"
+	"github.com/seatgeek/hashi-helper/command/snapshot"
 	cfg "github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
 )
 
-// PushAll ...
-func PushAll(cli *cli.Context) error {
-	config, err := cfg.NewConfigFromCLI(cli)
+// PushOptions configures the Vault push functions, it's the library equivalent of the push command flags
+type PushOptions struct {
+	// Prune deletes remote resources that don't exist in config
+	Prune bool
+
+	// ConfirmPrune is called with the resources about to be pruned, nothing is deleted if it returns false.
+	// All resources missing from config are deleted without asking if it's nil
+	ConfirmPrune func(kind string, names []string) bool
+
+	// SecretPrefix only writes secrets with a remote path starting with the prefix
+	SecretPrefix string
+
+	// KeepGoing continues with the other resources after a resource failed to push, all errors are returned at the end
+	KeepGoing bool
+
+	// Concurrency is how many resources are written at the same time, resources are written one by one if it's 0
+	Concurrency int
+
+	// Force writes every resource, instead of only those that differ from what Vault returns
+	Force bool
+
+	// Snapshot captures the state of every resource before it's written, nothing is captured if it's nil
+	Snapshot *snapshot.Recorder
+}
+
+// failures returns the collector for the errors of a push with these options
+func (o PushOptions) failures() *report.Failures {
+	return &report.Failures{KeepGoing: o.KeepGoing}
+}
+
+// executor returns the executor to run the writes of a push with these options
+func (o PushOptions) executor() *executor.Executor {
+	return executor.New(o.Concurrency, o.KeepGoing)
+}
+
+// pushFunc pushes one kind of Vault resource in all namespaces
+type pushFunc func(ctx context.Context, config *cfg.Config, client *api.Client, opts PushOptions) error
+
+// PushAllFromCLI ...
+func PushAllFromCLI(c *cli.Context) error {
+	config, err := cfg.NewConfigFromCLI(c)
 	if err != nil {
 		return err
 	}
 
-	return PushAllWithConfig(cli, config)
+	return PushAllWithConfig(c, config)
 }
 
 // PushAllWithConfig ...
-func PushAllWithConfig(cli *cli.Context, config *cfg.Config) error {
+func PushAllWithConfig(c *cli.Context, config *cfg.Config) error {
+	client, err := newClient(c)
+	if err != nil {
+		return err
+	}
+
+	return PushAllWithOptions(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// PushAll will push all Vault configuration, resources missing from config are never pruned
+func PushAll(ctx context.Context, config *cfg.Config, client *api.Client) error {
+	return PushAllWithOptions(ctx, config, client, PushOptions{})
+}
+
+// PushAllWithOptions is PushAll with options, Prune is ignored as PushAll never prunes
+func PushAllWithOptions(ctx context.Context, config *cfg.Config, client *api.Client, opts PushOptions) error {
 	log.Info("Pushing all configuration")
 
-	if err := AuditPushWithConfig(cli, config); err != nil {
-		return err
+	opts.Prune = false
+	failures := opts.failures()
+
+	pushers := []pushFunc{
+		PushNamespaces,
+		PushAudit,
+		PushAuth,
+		PushMounts,
+		PushPolicies,
+		PushIdentity,
+		PushSecrets,
 	}
 
-	if err := AuthPushWithConfig(cli, config); err != nil {
-		return err
+	for _, fn := range pushers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := failures.Add(fn(ctx, config, client, opts)); err != nil {
+			return err
+		}
 	}
 
-	if err := MountsPushWithConfig(cli, config); err != nil {
+	return failures.Err()
+}
+
+// pushWithCLI calls fn with a Vault client configured from the environment and the push options of the CLI flags
+func pushWithCLI(c *cli.Context, config *cfg.Config, fn pushFunc) error {
+	client, err := newClient(c)
+	if err != nil {
 		return err
 	}
 
-	if err := PoliciesPushWithConfig(cli, config); err != nil {
-		return err
+	return fn(context.Background(), config, client, pushOptionsFromCLI(c))
+}
+
+// newClient returns a Vault client configured from the environment, retrying transient errors as often as --retries
+func newClient(c *cli.Context) (*api.Client, error) {
+	config := api.DefaultConfig()
+	if config.Error != nil {
+		return nil, config.Error
+	}
+
+	client, err := api.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// the transport is only wrapped once the client is created, as reading the TLS settings from
+	// the environment requires an *http.Transport. Vault's own retries are disabled, so requests
+	// aren't retried by both
+	config.HttpClient.Transport = &support.RetryTransport{Base: config.HttpClient.Transport, Retries: c.GlobalInt("retries")}
+	client.SetMaxRetries(0)
+
+	return client, nil
+}
+
+// pushOptionsFromCLI returns the push options of the --prune, --yes, --prefix, --keep-going, --concurrency and --force flags,
+// and the snapshot recorder of this run
+func pushOptionsFromCLI(c *cli.Context) PushOptions {
+	opts := PushOptions{
+		Prune:        c.Bool("prune"),
+		SecretPrefix: c.String("prefix"),
+		KeepGoing:    c.GlobalBool("keep-going"),
+		Concurrency:  c.GlobalInt("concurrency"),
+		Force:        c.GlobalBool("force"),
+		Snapshot:     snapshot.FromCLI(c),
+	}
+
+	if !c.Bool("yes") {
+		opts.ConfirmPrune = func(kind string, names []string) bool {
+			return confirm(fmt.Sprintf("Are you sure you want to delete %d %s?", len(names), kind))
+		}
+	}
+
+	return opts
+}
+
+// checkPushConfig returns an error if config wasn't loaded for a single environment, or if pruning
+// was requested while config only contains a single application. what names the command in the
+// error, like "Pushing policies"
+func checkPushConfig(config *cfg.Config, opts PushOptions, what string) error {
+	env := config.TargetEnvironment()
+	if env == "" {
+		return fmt.Errorf("%s require a 'environment' value (--environment or ENV[ENVIRONMENT])", what)
+	}
+
+	if !config.Environments.Contains(env) {
+		return fmt.Errorf("Could not find any environment with name %s in configuration", env)
+	}
+
+	// with an application filter only a subset of the config is loaded, and
+	// everything belonging to other applications would be considered missing
+	if opts.Prune && config.TargetApplication() != "" {
+		return fmt.Errorf("--prune can't be used together with --application")
 	}
 
-	return SecretsPushWithConfig(cli, config)
+	return nil
 }
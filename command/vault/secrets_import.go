@@ -2,90 +2,75 @@ package vault
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
-	"strings"
+	"path/filepath"
+	"sort"
 
 	"github.com/seatgeek/hashi-helper/command/vault/helper"
+	"github.com/seatgeek/hashi-helper/config"
+	"github.com/seatgeek/hashi-helper/support"
 	log "github.com/sirupsen/logrus"
 	cli "gopkg.in/urfave/cli.v1"
 )
 
 // SecretsImport ...
 func SecretsImport(c *cli.Context) error {
+	dirs := c.GlobalStringSlice("config-dir")
+	if len(dirs) == 0 {
+		return fmt.Errorf("Importing secrets require a '--config-dir' to write files into")
+	}
+
 	secrets := helper.IndexRemoteSecrets(c.GlobalString("environment"), c.GlobalInt("concurrency"))
 
-	secrets, err := helper.ReadRemoteSecrets(secrets, c.GlobalInt("concurrency"))
+	secrets, err := helper.ReadRemoteSecrets(secrets, c.GlobalInt("concurrency"), c.Int("version"))
 	if err != nil {
 		return err
 	}
 
-	output := make(map[string]map[string]string)
+	// environment -> application -> secrets
+	output := make(map[string]map[string]config.VaultSecrets)
 
 	for _, secret := range secrets {
 		env := secret.Environment.Name
 		app := secret.Application.Name
 
 		if _, ok := output[env]; !ok {
-			output[env] = make(map[string]string)
-		}
-
-		if _, ok := output[env][app]; !ok {
-			output[env][app] = `policy "{app}-read-only" {
-				path "secret/{app}/*" {
-					capabilities = ["read", "list"]
-				}
-			}`
-
-			output[env][app] = strings.Replace(output[env][app], "{app}", app, -1)
-		}
-
-		output[env][app] = output[env][app] + fmt.Sprintf("\t\tsecret \"%s\" {\n", secret.Key)
-
-		for k, v := range secret.VaultSecret.Data {
-			switch vv := v.(type) {
-			case string:
-				output[env][app] = output[env][app] + fmt.Sprintf("\t\t\t%s = \"%s\"\n", k, helper.EscapeValue(vv))
-			case int:
-				output[env][app] = output[env][app] + fmt.Sprintf("\t\t\t%s = %d\n", k, vv)
-			default:
-				log.Panic("  ⇛ ", k, "is of a type I don't know how to handle")
-			}
+			output[env] = make(map[string]config.VaultSecrets)
 		}
 
-		output[env][app] = output[env][app] + "\t\t}\n"
+		output[env][app] = append(output[env][app], secret)
 	}
 
-	for _env, _apps := range output {
-		for _app, _output := range _apps {
-			content := fmt.Sprintf("environment \"%s\" {\n", _env)
-			content = content + fmt.Sprintf("\tapplication \"%s\" {\n", _app)
-			content = content + _output
-			content = content + fmt.Sprintf("\t}\n")
-
-			content = content + fmt.Sprintf("}\n\n")
-
-			dir := c.GlobalString("config-dir") + "/" + _env
-
-			if _, err := os.Stat(dir); os.IsNotExist(err) {
-				var mode os.FileMode
-				mode = 0777
-				os.Mkdir(dir, mode)
+	for env, apps := range output {
+		for app, appSecrets := range apps {
+			sort.Slice(appSecrets, func(i, j int) bool {
+				return appSecrets[i].Key < appSecrets[j].Key
+			})
+
+			w := support.NewHCLWriter()
+			w.Block("environment", env)
+			w.Block("application", app)
+
+			w.Block("policy", app+"-read-only")
+			w.Block("path", fmt.Sprintf("secret/%s/*", app))
+			w.Attribute("capabilities", []string{"read", "list"})
+			w.End()
+			w.End()
+
+			for _, secret := range appSecrets {
+				w.Block("secret", secret.Key)
+				w.Attributes(secret.VaultSecret.Data)
+				w.End()
 			}
 
-			file := dir + "/app-" + _app + ".hcl"
-			err := ioutil.WriteFile(file, []byte(content), 0644)
-			if err != nil {
-				return err
-			}
-			log.Infof("Wrote file: %s", file)
+			w.End()
+			w.End()
 
-			output, err := helper.FormatHclFile(file)
-			if err != nil {
+			file := filepath.Join(dirs[0], env, "app-"+app+".hcl")
+			if err := w.WriteFile(file, true); err != nil {
 				return err
 			}
 
-			log.Infof("Formatted file: %s (%s)", file, output.String())
+			log.Infof("Wrote file: %s", file)
 		}
 	}
 
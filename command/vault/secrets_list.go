@@ -10,7 +10,7 @@ import (
 
 // SecretsList ...
 func SecretsList(c *cli.Context) error {
-	if c.GlobalBool("remote") {
+	if c.Bool("remote") {
 		return secretListRemote(c)
 	}
 
@@ -47,7 +47,7 @@ func secretListRemote(c *cli.Context) error {
 	secrets := helper.IndexRemoteSecrets(c.GlobalString("environment"), c.GlobalInt("concurrency"))
 
 	if c.Bool("detailed") {
-		printDetailedSecrets(secrets, c.GlobalInt("concurrency"))
+		printDetailedSecrets(secrets, c.GlobalInt("concurrency"), c.Int("version"))
 		return nil
 	}
 
@@ -59,8 +59,8 @@ func secretListRemote(c *cli.Context) error {
 	return nil
 }
 
-func printDetailedSecrets(paths config.VaultSecrets, concurrency int) {
-	secrets, err := helper.ReadRemoteSecrets(paths, concurrency)
+func printDetailedSecrets(paths config.VaultSecrets, concurrency, version int) {
+	secrets, err := helper.ReadRemoteSecrets(paths, concurrency, version)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -1,8 +1,9 @@
 package vault
 
 import (
-	"fmt"
+	"context"
 
+	"github.com/hashicorp/vault/api"
 	"github.com/seatgeek/hashi-helper/command/vault/helper"
 	"github.com/seatgeek/hashi-helper/config"
 	log "github.com/sirupsen/logrus"
@@ -21,29 +22,38 @@ func SecretsPush(c *cli.Context) error {
 
 // SecretsPushWithConfig ...
 func SecretsPushWithConfig(c *cli.Context, config *config.Config) error {
+	return pushWithCLI(c, config, PushSecrets)
+}
+
+// PushSecrets will write the secrets in all Vault namespaces
+func PushSecrets(ctx context.Context, config *config.Config, client *api.Client, opts PushOptions) error {
 	log.Info("Pushing Vault Secrets")
 
-	env := c.GlobalString("environment")
-	if env == "" {
-		return fmt.Errorf("Secret writer requires a environment value (--environment or ENV[ENVIRONMENT])")
+	if err := checkPushConfig(config, opts, "Pushing secrets"); err != nil {
+		return err
 	}
 
-	if !config.Environments.Contains(env) {
-		return fmt.Errorf("Could not find any environment with name %s in configuration", env)
-	}
+	return forEachVaultNamespace(ctx, client, config, opts, pushNamespaceSecrets)
+}
 
+// pushNamespaceSecrets writes the secrets of a single Vault namespace
+func pushNamespaceSecrets(ctx context.Context, client *api.Client, config *config.Config, opts PushOptions) error {
 	writeConfig := make(map[string]string)
-	if prefix := c.String("prefix"); prefix != "" {
+	if prefix := opts.SecretPrefix; prefix != "" {
 		writeConfig["only-prefix"] = prefix
 	}
 
-	engine := helper.SecretWriter{}
+	exec := opts.executor()
+
+	engine := helper.NewSecretWriter(client)
+	engine.Force = opts.Force
+	engine.Snapshot = opts.Snapshot
 	for _, secret := range config.VaultSecrets {
-		err := engine.WriteSecret(secret, writeConfig)
-		if err != nil {
-			log.Panic(err)
-		}
+		secret := secret
+		exec.Add("vault_secret", helper.SecretPath(secret), func() error {
+			return engine.WriteSecret(secret, writeConfig)
+		})
 	}
 
-	return nil
+	return exec.Run(ctx)
 }
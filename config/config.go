@@ -7,44 +7,124 @@ import (
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/seatgeek/hashi-helper/support"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/urfave/cli.v1"
 )
 
 // Config ...
 type Config struct {
-	Applications      Applications
-	concurrency       int
-	ConsulKVs         ConsulKVs
-	ConsulServices    ConsulServices
-	Environments      Environments
-	logger            *log.Entry
-	renderer          *renderer
-	targetApplication string
-	targetEnvironment string
-	VaultAuths        VaultAuths
-	VaultMounts       VaultMounts
-	VaultPolicies     VaultPolicies
-	VaultSecrets      VaultSecrets
-	VaultAudits       VaultAudits
+	Applications              Applications
+	concurrency               int
+	decrypter                 *support.Decrypter
+	ConsulACLBindingRules     ConsulACLBindingRules
+	ConsulACLPolicies         ConsulACLPolicies
+	ConsulACLRoles            ConsulACLRoles
+	ConsulACLTokens           ConsulACLTokens
+	ConsulConfigEntries       ConsulConfigEntries
+	ConsulIntentions          ConsulIntentions
+	ConsulKVs                 ConsulKVs
+	ConsulServices            ConsulServices
+	duplicateSecrets          VaultSecrets
+	Environments              Environments
+	file                      string
+	inheritedFrom             string
+	layer                     int
+	lint                      bool
+	logger                    *log.Entry
+	NomadACLPolicies          NomadACLPolicies
+	NomadNamespaces           NomadNamespaces
+	NomadQuotas               NomadQuotas
+	overrideErrors            error
+	overrides                 map[string]int
+	parsedFiles               []*parsedFile
+	renderer                  *Renderer
+	targetApplication         string
+	targetEnvironment         string
+	VaultAuths                VaultAuths
+	VaultIdentityEntities     VaultIdentityEntities
+	VaultIdentityGroupAliases VaultIdentityGroupAliases
+	VaultIdentityGroups       VaultIdentityGroups
+	VaultMounts               VaultMounts
+	vaultNamespace            string
+	VaultNamespaces           VaultNamespaces
+	VaultPolicies             VaultPolicies
+	VaultPolicyTests          VaultPolicyTests
+	VaultSecrets              VaultSecrets
+	VaultAudits               VaultAudits
 }
 
-// NewConfigFromCLI will take a CLI context and create config from it
-func NewConfigFromCLI(c *cli.Context) (*Config, error) {
+// Options configures Load, each field matches the global CLI flag of the same name
+type Options struct {
+	// Dirs are scanned recursively for config files
+	Dirs []string
+
+	// Files are read as single config files
+	Files []string
+
+	// Variables are key=value pairs exposed to templates
+	Variables []string
+
+	// VariableFiles are .hcl, .yaml or .json files exposed to templates
+	VariableFiles []string
+
+	// TemplateFixtures is a JSON file the live lookup template funcs read from, instead of Consul and Vault
+	TemplateFixtures string
+
+	// Environment only loads the named environment, all environments are loaded if empty
+	Environment string
+
+	// Application only loads the named application, all applications are loaded if empty
+	Application string
+
+	// Concurrency is the number of parallel requests against remote servers
+	Concurrency int
+
+	// Lint turns warnings about the config into errors
+	Lint bool
+
+	// Decrypter decrypts ENC[...] values and encrypted files, they fail to decrypt if nil
+	Decrypter *support.Decrypter
+}
+
+// Load reads and parses all config files in opts, it never exits the process
+func Load(opts Options) (*Config, error) {
+	decrypter := opts.Decrypter
+	if decrypter == nil {
+		decrypter = &support.Decrypter{}
+	}
+
 	config := &Config{
-		targetEnvironment: c.GlobalString("environment"),
-		targetApplication: c.GlobalString("application"),
-		concurrency:       c.GlobalInt("concurrency"),
+		targetEnvironment: opts.Environment,
+		targetApplication: opts.Application,
+		concurrency:       opts.Concurrency,
+		decrypter:         decrypter,
+		lint:              opts.Lint,
 	}
 
 	// create a templater we can use for future rendering
-	templater, err := newRenderer(c.GlobalStringSlice("variable"), c.GlobalStringSlice("variable-file"))
+	templater, err := NewRenderer(opts.Variables, opts.VariableFiles)
 	if err != nil {
 		return nil, err
 	}
+	config.renderer = templater
+
+	if opts.TemplateFixtures != "" {
+		if err := templater.UseTemplateFixtures(opts.TemplateFixtures); err != nil {
+			return nil, err
+		}
+	}
+
+	// template partials must be known before any config file is rendered
+	for _, dir := range opts.Dirs {
+		if err := templater.LoadPartials(dir); err != nil {
+			return nil, err
+		}
+	}
 
 	// scan all config-dirs provided
-	for _, dir := range c.GlobalStringSlice("config-dir") {
+	for _, dir := range opts.Dirs {
 		scanner := newConfigScanner(dir, config, templater)
 		if err := scanner.scan(); err != nil {
 			return nil, err
@@ -52,13 +132,28 @@ func NewConfigFromCLI(c *cli.Context) (*Config, error) {
 	}
 
 	// scan all config-files provided
-	for _, file := range c.GlobalStringSlice("config-file") {
+	for _, file := range opts.Files {
 		scanner := newConfigScanner(file, config, templater)
 		if err := scanner.scan(); err != nil {
 			return nil, err
 		}
 	}
 
+	// environments can inherit from environments in any file, so files are only processed once all are parsed
+	if err := config.processParsedFiles(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// NewConfigFromCLI will take a CLI context and create config from it
+func NewConfigFromCLI(c *cli.Context) (*Config, error) {
+	config, err := Load(OptionsFromCLI(c))
+	if err != nil {
+		return nil, err
+	}
+
 	if c.GlobalBool("lint") {
 		log.Warn("Lint mode detected, shutting down")
 		os.Exit(0)
@@ -67,6 +162,37 @@ func NewConfigFromCLI(c *cli.Context) (*Config, error) {
 	return config, nil
 }
 
+// OptionsFromCLI returns the Load options of the global CLI flags
+func OptionsFromCLI(c *cli.Context) Options {
+	return Options{
+		Dirs:             c.GlobalStringSlice("config-dir"),
+		Files:            c.GlobalStringSlice("config-file"),
+		Variables:        c.GlobalStringSlice("variable"),
+		VariableFiles:    c.GlobalStringSlice("variable-file"),
+		TemplateFixtures: c.GlobalString("template-fixtures"),
+		Environment:      c.GlobalString("environment"),
+		Application:      c.GlobalString("application"),
+		Concurrency:      c.GlobalInt("concurrency"),
+		Lint:             c.GlobalBool("lint"),
+		Decrypter:        NewDecrypterFromCLI(c),
+	}
+}
+
+// Renderer returns the template renderer used to render the config files
+func (c *Config) Renderer() *Renderer {
+	return c.renderer
+}
+
+// TargetEnvironment returns the environment the config was loaded for, empty if all environments were loaded
+func (c *Config) TargetEnvironment() string {
+	return c.targetEnvironment
+}
+
+// TargetApplication returns the application the config was loaded for, empty if all applications were loaded
+func (c *Config) TargetApplication() string {
+	return c.targetApplication
+}
+
 func (c *Config) parseContent(content, file string) (*ast.ObjectList, error) {
 	// Parse into HCL AST
 	log.WithField("file", file).Debug("Parsing content")
@@ -84,14 +210,21 @@ func (c *Config) parseContent(content, file string) (*ast.ObjectList, error) {
 }
 
 func (c *Config) processContent(list *ast.ObjectList, file string) error {
+	c.file = file
 	c.logger = log.WithField("file", file)
 	defer func() {
+		c.file = ""
 		c.logger = log.WithField("file", "")
 	}()
 
 	return c.processEnvironments(list)
 }
 
+// source returns the location of pos in the file being processed
+func (c *Config) source(pos token.Pos) Source {
+	return Source{File: c.file, Line: pos.Line}
+}
+
 // c.checkHCLKeys
 // Simply checks if there is any unexpected keys in the AST node provided, nice way to avoid a typo
 func (c *Config) checkHCLKeys(node ast.Node, valid []string) error {
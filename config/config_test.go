@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -312,7 +314,7 @@ test = "[[ scratch.Get "foo" ]]"
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			renderer, err := newRenderer(nil, nil)
+			renderer, err := NewRenderer(nil, nil)
 			renderer.variables = tt.templateVariables
 			require.NoError(t, err)
 
@@ -328,3 +330,1085 @@ test = "[[ scratch.Get "foo" ]]"
 		})
 	}
 }
+
+func TestConfig_PreventDestroy(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		mount "legacy" {
+			prevent_destroy = true
+		}
+
+		auth "github" {
+			type            = "github"
+			prevent_destroy = true
+		}
+
+		policy "admin" {
+			prevent_destroy = true
+
+			path "secret/*" {
+				capabilities = ["read"]
+			}
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	require.True(t, c.VaultMounts.Find("legacy").IsPlaceholder())
+	require.False(t, c.VaultAuths[0].IsPlaceholder())
+	require.True(t, c.VaultAuths[0].PreventDestroy)
+	require.False(t, c.VaultPolicies[0].IsPlaceholder())
+	require.NotContains(t, c.VaultPolicies[0].Raw, "prevent_destroy")
+
+	list, err = c.parseContent(`
+	environment "test" {
+		mount "missing-type" {}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.EqualError(t, c.processContent(list, "test.hcl"), "missing mount type in test -> missing-type")
+
+	invalid := []struct {
+		hcl       string
+		expectErr string
+	}{
+		{`mount "a" { prevent_destroy = "yes" }`, "unexpected type string for test -> a -> prevent_destroy"},
+		{`mount "a" { prevent_destroy = ["yes"] }`, "prevent_destroy must be a boolean in test -> a"},
+		{`auth "a" { prevent_destroy = { yes = true } }`, "prevent_destroy must be a boolean in test -> a"},
+		{`auth "a" { type = ["github"] }`, "auth type must be a string in test -> a"},
+	}
+
+	for _, tt := range invalid {
+		list, err = c.parseContent(`environment "test" { `+tt.hcl+` }`, "test.hcl")
+		require.NoError(t, err)
+		require.EqualError(t, c.processContent(list, "test.hcl"), tt.expectErr)
+	}
+}
+
+func TestConfig_MountSettings(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		mount "secret" {
+			type                        = "kv"
+			description                 = "app secrets"
+			default_lease_ttl           = "1h"
+			seal_wrap                   = true
+			listing_visibility          = "unauth"
+			audit_non_hmac_request_keys = ["role"]
+
+			options {
+				version = 2
+			}
+		}
+
+		auth "github" {
+			type              = "github"
+			max_lease_ttl     = "24h"
+			token_type        = "batch"
+			plugin_version    = "v1.2.0"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	mount := c.VaultMounts.Find("secret")
+	require.Equal(t, map[string]string{"version": "2"}, mount.Options)
+	require.True(t, mount.MountInput().SealWrap)
+	require.Equal(t, []string{"role"}, mount.MountInput().Config.AuditNonHMACRequestKeys)
+	require.Equal(t, map[string]interface{}{
+		"description":                 "app secrets",
+		"default_lease_ttl":           "1h",
+		"listing_visibility":          "unauth",
+		"audit_non_hmac_request_keys": []string{"role"},
+		"options":                     map[string]interface{}{"version": "2"},
+	}, mount.TuneData())
+
+	auth := c.VaultAuths[0]
+	require.Equal(t, "24h", auth.AuthInput().Config.MaxLeaseTTL)
+	require.Equal(t, map[string]interface{}{
+		"max_lease_ttl":  "24h",
+		"token_type":     "batch",
+		"plugin_version": "v1.2.0",
+	}, auth.TuneData())
+
+	// a mount declared again is merged with the first declaration
+	c = &Config{targetEnvironment: "test"}
+	list, err = c.parseContent(`
+	environment "test" {
+		mount "db" {
+			type              = "database"
+			default_lease_ttl = "1h"
+
+			config "a" {
+				value = "first"
+			}
+
+			role "app" {
+				db_name = "db"
+			}
+		}
+
+		mount "db" {
+			max_lease_ttl = "24h"
+
+			config "a" {
+				other = "second"
+			}
+
+			role "app" {
+				max_ttl = "1h"
+			}
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	require.Len(t, c.VaultMounts, 1)
+	mount = c.VaultMounts[0]
+	require.Equal(t, "1h", mount.DefaultLeaseTTL)
+	require.Equal(t, "24h", mount.MaxLeaseTTL)
+	require.Equal(t, map[string]interface{}{"value": "first", "other": "second"}, mount.Config[0].Data)
+	require.Len(t, mount.Roles, 1)
+	require.Equal(t, map[string]interface{}{"db_name": "db", "max_ttl": "1h"}, mount.Roles[0].Data)
+
+	conflicts := []struct {
+		hcl       string
+		expectErr string
+	}{
+		{
+			hcl:       `mount "secret" { type = "kv" } mount "secret" { type = "generic" }`,
+			expectErr: "mount test -> secret is declared more than once with a different type",
+		},
+		{
+			hcl:       `mount "secret" { type = "kv" options { version = 1 } } mount "secret" { options { version = 2 } description = "a" }`,
+			expectErr: "mount test -> secret is declared more than once with a different options -> version",
+		},
+		{
+			hcl:       `mount "secret" { type = "kv" config "a" { value = "first" } } mount "secret" { config "a" { value = "second" } }`,
+			expectErr: "mount config secret -> a is declared more than once in test with a different value",
+		},
+		{
+			hcl:       `mount "db" { type = "database" role "app" { db_name = "a" } } mount "db" { role "app" { db_name = "b" } }`,
+			expectErr: "mount role db -> app is declared more than once in test with a different db_name",
+		},
+	}
+
+	for _, tt := range conflicts {
+		c = &Config{targetEnvironment: "test"}
+		list, err = c.parseContent(`environment "test" { `+tt.hcl+` }`, "test.hcl")
+		require.NoError(t, err)
+		require.EqualError(t, c.processContent(list, "test.hcl"), tt.expectErr)
+	}
+
+	list, err = c.parseContent(`
+	environment "test" {
+		auth "ldap" {
+			type      = "ldap"
+			seal_wrap = "yes"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.Error(t, c.processContent(list, "test.hcl"))
+}
+
+func TestConfig_PreviousPaths(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		mount "db-api" {
+			type           = "database"
+			previous_paths = ["/db-legacy/", "db-old"]
+		}
+
+		auth "github" {
+			type           = "github"
+			previous_paths = ["github-old"]
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	require.Equal(t, []string{"db-legacy", "db-old"}, c.VaultMounts.Find("db-api").PreviousPaths)
+	require.Equal(t, []string{"github-old"}, c.VaultAuths[0].PreviousPaths)
+
+	// a mount declared again adds its previous_paths
+	list, err = c.parseContent(`
+	environment "test" {
+		mount "db-api" {
+			previous_paths = ["db-old", "db-older"]
+		}
+	}`, "more.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "more.hcl"))
+	require.Equal(t, []string{"db-legacy", "db-old", "db-older"}, c.VaultMounts.Find("db-api").PreviousPaths)
+
+	list, err = c.parseContent(`
+	environment "test" {
+		mount "secret" {
+			type           = "kv"
+			previous_paths = ["secret"]
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.EqualError(t, c.processContent(list, "test.hcl"), "previous_paths of test -> secret can't contain its own path")
+}
+
+func TestConfig_ConsulACL(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		consul_acl_policy "web" {
+			description = "web service"
+			rules       = <<EOF
+service "web" {
+  policy = "write"
+}
+EOF
+		}
+
+		consul_acl_role "web" {
+			policies           = ["web"]
+			service_identities = ["web"]
+		}
+
+		consul_acl_token "web deploy" {
+			roles = ["web"]
+		}
+
+		consul_acl_binding_rule "k8s services" {
+			auth_method = "kubernetes"
+			bind_type   = "service"
+			bind_name   = "${serviceaccount.name}"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	require.Len(t, c.ConsulACLPolicies, 1)
+	require.Contains(t, c.ConsulACLPolicies[0].Rules, `service "web"`)
+	require.Equal(t, []string{"web"}, c.ConsulACLRoles[0].Policies)
+	require.Equal(t, "web deploy", c.ConsulACLTokens[0].Name)
+	require.Equal(t, "${serviceaccount.name}", c.ConsulACLBindingRules[0].BindName)
+
+	list, err = c.parseContent(`
+	environment "test" {
+		consul_acl_binding_rule "invalid" {
+			auth_method = "kubernetes"
+			bind_type   = "user"
+			bind_name   = "x"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.EqualError(t, c.processContent(list, "test.hcl"), "Invalid bind_type 'user' in consul_acl_binding_rule test -> invalid, must be one of service, role or node")
+}
+
+func TestConfig_ConsulConfigEntries(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		config_entry "service-defaults" "web" {
+			protocol = "http"
+
+			mesh_gateway {
+				mode = "local"
+			}
+
+			meta {
+				owner_team = "platform"
+			}
+		}
+
+		config_entry "service-resolver" "web" {
+			default_subset  = "v1"
+			connect_timeout = "15s"
+
+			subsets {
+				v1 {
+					filter = "Service.Meta.version == v1"
+				}
+			}
+		}
+
+		config_entry "service-router" "web" {
+			routes {
+				match {
+					http {
+						path_prefix = "/admin"
+					}
+				}
+
+				destination {
+					service = "admin"
+				}
+			}
+		}
+
+		intention "web" "db" {
+			action = "allow"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	require.Len(t, c.ConsulConfigEntries, 3)
+
+	defaults := c.ConsulConfigEntries[0].Entry.(*api.ServiceConfigEntry)
+	require.Equal(t, "http", defaults.Protocol)
+	require.Equal(t, api.MeshGatewayModeLocal, defaults.MeshGateway.Mode)
+	require.Equal(t, "platform", defaults.Meta["owner_team"])
+
+	resolver := c.ConsulConfigEntries[1].Entry.(*api.ServiceResolverConfigEntry)
+	require.Equal(t, "15s", resolver.ConnectTimeout.String())
+	require.Equal(t, "Service.Meta.version == v1", resolver.Subsets["v1"].Filter)
+
+	router := c.ConsulConfigEntries[2].Entry.(*api.ServiceRouterConfigEntry)
+	require.Equal(t, "/admin", router.Routes[0].Match.HTTP.PathPrefix)
+	require.Equal(t, "admin", router.Routes[0].Destination.Service)
+
+	require.Equal(t, "db", c.ConsulIntentions[0].Destination)
+
+	list, err = c.parseContent(`
+	environment "test" {
+		config_entry "service-defaults" "web" {
+			protocoll = "http"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.Contains(t, c.processContent(list, "test.hcl").Error(), "invalid key 'protocoll'")
+}
+
+func TestConfig_Nomad(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		nomad_quota "web" {
+			description = "web team"
+
+			limit "global" {
+				cpu    = 2500
+				memory = 1000
+			}
+		}
+
+		nomad_namespace "web" {
+			quota = "web"
+
+			meta {
+				owner = "web"
+			}
+		}
+
+		nomad_acl_policy "web-deploy" {
+			rules = "namespace \"web\" { policy = \"write\" }"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	quota := c.NomadQuotas[0].ToNomadQuota()
+	require.Equal(t, "web team", quota.Description)
+	require.Equal(t, "global", quota.Limits[0].Region)
+	require.Equal(t, 2500, *quota.Limits[0].RegionLimit.CPU)
+	require.Nil(t, quota.Limits[0].RegionLimit.Cores)
+
+	require.Equal(t, "web", c.NomadNamespaces[0].Quota)
+	require.Equal(t, "web", c.NomadNamespaces[0].Meta["owner"])
+	require.Equal(t, "web-deploy", c.NomadACLPolicies[0].Name)
+}
+
+func TestConfig_VaultIdentity(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		identity_entity "alice" {
+			policies = ["admin"]
+		}
+
+		identity_group "engineering" {
+			member_group_names  = ["platform"]
+			member_entity_names = ["alice"]
+		}
+
+		identity_group "platform" {
+			type     = "external"
+			policies = ["platform"]
+		}
+
+		identity_group_alias "platform-team" {
+			auth  = "github"
+			group = "platform"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	require.Equal(t, "internal", c.VaultIdentityGroups.Find("engineering").Type)
+	require.True(t, c.VaultIdentityGroups.Find("platform").IsExternal())
+	require.Equal(t, "github", c.VaultIdentityGroupAliases[0].Auth)
+
+	sorted, err := c.VaultIdentityGroups.Sorted()
+	require.NoError(t, err)
+	require.Equal(t, "platform", sorted[0].Name)
+	require.Equal(t, "engineering", sorted[1].Name)
+
+	cycle := VaultIdentityGroups{
+		{Name: "a", MemberGroupNames: []string{"b"}},
+		{Name: "b", MemberGroupNames: []string{"a"}},
+	}
+	_, err = cycle.Sorted()
+	require.Error(t, err)
+}
+
+func TestConfig_VaultNamespaces(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		namespace = "team-a"
+
+		mount "secret" {
+			type = "kv"
+		}
+
+		namespace "billing" {
+			mount "secret" {
+				type = "kv"
+			}
+
+			namespace "eu" {
+				policy "read" {
+					path "secret/*" {
+						capabilities = ["read"]
+					}
+				}
+
+				identity_group "billing" {
+					policies = ["read"]
+				}
+			}
+		}
+	}`, "team-a.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "team-a.hcl"))
+
+	list, err = c.parseContent(`
+	environment "test" {
+		mount "secret" {
+			type = "kv"
+		}
+
+		secret "foo" {
+			value = "bar"
+		}
+
+		identity_group "billing" {
+			policies = ["admin"]
+		}
+	}`, "root.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "root.hcl"))
+
+	require.Equal(t, []string{"", "team-a", "team-a/billing", "team-a/billing/eu"}, c.VaultNamespacePaths())
+	require.Len(t, c.VaultMounts, 3)
+	require.NotNil(t, c.VaultMounts.Find("secret"))
+	require.NotNil(t, c.VaultMounts.FindInNamespace("team-a/billing", "secret"))
+
+	scoped := c.ForVaultNamespace("team-a/billing/eu")
+	require.Len(t, scoped.VaultMounts, 0)
+	require.Len(t, scoped.VaultPolicies, 1)
+	require.Equal(t, "read", scoped.VaultPolicies[0].Name)
+	require.Len(t, scoped.VaultIdentityGroups, 1)
+	require.Equal(t, []string{"read"}, scoped.VaultIdentityGroups[0].Policies)
+
+	root := c.ForVaultNamespace("")
+	require.Len(t, root.VaultMounts, 1)
+	require.Len(t, root.VaultSecrets, 1)
+	require.Len(t, root.VaultIdentityGroups, 1)
+	require.Equal(t, []string{"admin"}, root.VaultIdentityGroups[0].Policies)
+}
+
+func TestConfig_VaultSecretKVOptions(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		secret "with-options" {
+			value           = "bar"
+			cas             = 0
+			max_versions    = 5
+			custom_metadata = {
+				owner = "platform"
+			}
+		}
+
+		secret "plain" {
+			value = "bar"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+	require.Len(t, c.VaultSecrets, 2)
+
+	withOptions := c.VaultSecrets[0]
+	require.Equal(t, map[string]interface{}{"value": "bar"}, withOptions.VaultSecret.Data)
+	require.NotNil(t, withOptions.KVOptions.CAS)
+	require.Equal(t, 0, *withOptions.KVOptions.CAS)
+	require.Equal(t, 5, withOptions.KVOptions.MaxVersions)
+	require.Equal(t, map[string]string{"owner": "platform"}, withOptions.KVOptions.CustomMetadata)
+	require.True(t, withOptions.KVOptions.HasMetadata())
+
+	plain := c.VaultSecrets[1]
+	require.Nil(t, plain.KVOptions)
+	require.False(t, plain.KVOptions.HasMetadata())
+}
+
+func TestConfig_FindSecretValues(t *testing.T) {
+	content := `
+	environment "test" {
+		policy "read" {
+			path "secret/*" {
+				capabilities = ["read"]
+			}
+		}
+
+		secret "encrypted" {
+			value           = "ENC[age,YWdl]"
+			max_versions    = 3
+			custom_metadata = {
+				owner = "platform"
+			}
+		}
+
+		secrets {
+			PLAIN = "plaintext"
+		}
+	}`
+
+	values, err := FindSecretValues(content)
+	require.NoError(t, err)
+	require.Len(t, values, 2)
+	require.True(t, values[0].IsEncrypted())
+	require.False(t, values[1].IsEncrypted())
+	require.Equal(t, "plaintext", values[1].Value)
+	require.Equal(t, `"plaintext"`, content[values[1].Pos.Offset:values[1].Pos.Offset+len(values[1].Text)])
+
+	require.Error(t, checkEncryptedOnly(content, "test.enc.hcl"))
+	require.True(t, IsEncryptedOnlyFile("apps/api.enc.hcl"))
+	require.False(t, IsEncryptedOnlyFile("apps/api.hcl"))
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mounts.hcl"), []byte(`
+	environment "[[ .env ]]" {
+		mount "db-[[ .env ]]" {
+			type = "database"
+		}
+	}`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.hcl"), []byte(`
+	environment "other" {
+		mount "ignored" {
+			type = "kv"
+		}
+	}`), 0644))
+
+	config, err := Load(Options{
+		Dirs:        []string{dir},
+		Variables:   []string{"env=test"},
+		Environment: "test",
+		Lint:        true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "test", config.TargetEnvironment())
+	require.Len(t, config.VaultMounts, 1)
+	require.Equal(t, "db-test", config.VaultMounts[0].Name)
+
+	rendered, err := config.Renderer().Render(`mount "[[ .env ]]" {}`, "inline")
+	require.NoError(t, err)
+	require.Equal(t, `mount "test" {}`, rendered)
+
+	_, err = Load(Options{Files: []string{filepath.Join(dir, "missing.hcl")}})
+	require.Error(t, err)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		mount "db" {
+			type              = "database"
+			default_lease_ttl = "48h"
+			max_lease_ttl     = "24h"
+
+			config "main" {
+				plugin_name = "mysql"
+			}
+
+			role "good" {
+				db_name = "main"
+			}
+
+			role "bad" {
+				db_name = "other"
+			}
+		}
+
+		auth "github" {
+			type = "github"
+
+			map "teams/dev" {
+				value = "dev,default,missing"
+			}
+		}
+
+		policy "dev" {
+			path "db/creds/*" {
+				capabilities = ["read"]
+			}
+
+			path "auth/github/login" {
+				capabilities = ["create"]
+			}
+
+			path "kv/*" {
+				capabilities = ["read"]
+			}
+		}
+
+		secret "foo" {
+			value = "a"
+		}
+
+		secret "foo" {
+			value = "b"
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	summarize := func(findings []*Finding) []string {
+		result := make([]string, 0, len(findings))
+		for _, finding := range findings {
+			result = append(result, fmt.Sprintf("%s %s", finding.Source, finding.Rule))
+		}
+		return result
+	}
+
+	require.Equal(t, []string{
+		"test.hcl:3 lease-ttl",
+		"test.hcl:16 database-role-config",
+		"test.hcl:24 auth-policy-exists",
+		"test.hcl:38 policy-path-mount",
+		"test.hcl:47 duplicate-secret",
+	}, summarize(c.Validate(ValidationRules)))
+
+	require.Equal(t, []string{"test.hcl:16 database-role-config"}, summarize(c.Validate([]*ValidationRule{FindValidationRule("database-role-config")})))
+}
+
+func TestACL_Check(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		policy "a" {
+			path "secret/*" {
+				capabilities = ["read", "list"]
+			}
+
+			path "secret/+/admin" {
+				capabilities = ["deny"]
+			}
+
+			path "secret/foo/bar" {
+				capabilities = ["update"]
+			}
+
+			path "auth/+/login*" {
+				capabilities = ["create"]
+			}
+		}
+
+		policy "b" {
+			path "secret/foo/bar" {
+				capabilities = ["read"]
+			}
+
+			path "secret/foo/baz" {
+				capabilities = ["deny"]
+			}
+
+			path "secret/foo/dir" {
+				capabilities = ["list"]
+			}
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	acl := NewACL(c.VaultPolicies...)
+
+	tests := []struct {
+		path       string
+		capability string
+		allowed    bool
+		rule       string
+	}{
+		{"secret/other", "read", true, "secret/*"},
+		{"secret/other", "update", false, "secret/*"},
+		{"/secret/foo/bar", "read", true, "secret/foo/bar"},
+		{"secret/foo/bar", "update", true, "secret/foo/bar"},
+		{"secret/foo/bar", "delete", false, "secret/foo/bar"},
+		{"secret/foo/baz", "read", false, "secret/foo/baz"},
+		{"secret/x/admin", "read", false, "secret/+/admin"},
+		{"secret/x/admin/y", "read", true, "secret/*"},
+		{"secret/foo/dir/", "list", true, "secret/foo/dir"},
+		{"auth/github/login/x", "create", true, "auth/+/login*"},
+		{"auth/github", "create", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.capability+" "+tt.path, func(t *testing.T) {
+			result, err := acl.Check(tt.path, tt.capability)
+			require.NoError(t, err)
+			require.Equal(t, tt.allowed, result.Allowed)
+
+			if tt.rule == "" {
+				require.Nil(t, result.Rule)
+			} else {
+				require.Equal(t, tt.rule, result.Rule.Path)
+			}
+		})
+	}
+
+	root, err := NewACL(&Policy{Name: "root"}).Check("sys/seal", "sudo")
+	require.NoError(t, err)
+	require.True(t, root.Allowed)
+
+	_, err = acl.Check("secret/foo", "write")
+	require.Error(t, err)
+}
+
+func TestConfig_RunPolicyTests(t *testing.T) {
+	c := &Config{targetEnvironment: "test"}
+
+	list, err := c.parseContent(`
+	environment "test" {
+		policy "app" {
+			path "secret/__ENV__/app/*" {
+				capabilities = ["read"]
+			}
+		}
+
+		policy_test "app" {
+			policies = ["app"]
+
+			expect "secret/__ENV__/app/db" {
+				allow = ["read"]
+				deny  = ["update"]
+			}
+
+			expect "secret/__ENV__/other" {
+				allow = ["read"]
+			}
+		}
+
+		policy_test "missing" {
+			policies = ["missing"]
+		}
+	}`, "test.hcl")
+	require.NoError(t, err)
+	require.NoError(t, c.processContent(list, "test.hcl"))
+
+	results := c.RunPolicyTests()
+	require.Len(t, results, 2)
+
+	require.NoError(t, results[0].Error)
+	require.Len(t, results[0].Cases, 3)
+	require.Equal(t, 1, results[0].Failures())
+	require.Equal(t, "allow read on secret/test/other", results[0].Cases[2].Name())
+	require.False(t, results[0].Cases[2].Passed())
+	require.Equal(t, Source{File: "test.hcl", Line: 17}, results[0].Cases[2].Source)
+
+	require.EqualError(t, results[1].Error, "policy_test missing references unknown policy missing")
+}
+
+func TestRenderer_templateFixtures(t *testing.T) {
+	fixtures := filepath.Join(t.TempDir(), "fixtures.json")
+	require.NoError(t, ioutil.WriteFile(fixtures, []byte(`{
+		"consul_kv": {
+			"app/port": "8080",
+			"app/db/": "",
+			"app/db/host": "db.local",
+			"app/db/user": "app"
+		},
+		"consul_services": {
+			"web": [
+				{"node": "n1", "address": "10.0.0.1", "port": 80, "tags": ["blue"]},
+				{"node": "n2", "address": "10.0.0.2", "port": 80, "tags": ["green"]}
+			],
+			"db": [{"node": "n3", "address": "10.0.0.3", "port": 5432}]
+		},
+		"vault_secrets": {
+			"secret/app": {"password": "hunter2"}
+		}
+	}`), 0644))
+
+	r, err := NewRenderer(nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, r.UseTemplateFixtures(fixtures))
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "key",
+			template: `port = "[[ key "app/port" ]]"`,
+			want:     `port = "8080"`,
+		},
+		{
+			name:     "key missing",
+			template: `port = "[[ key "app/missing" ]]"`,
+			wantErr:  "Consul KV key 'app/missing' does not exist",
+		},
+		{
+			name:     "keyOrDefault",
+			template: `port = "[[ keyOrDefault "app/missing" "80" ]]"`,
+			want:     `port = "80"`,
+		},
+		{
+			name:     "ls",
+			template: `keys = "[[ range ls "app" ]][[ .Key ]]=[[ .Value ]] [[ end ]]"`,
+			want:     `keys = "port=8080 "`,
+		},
+		{
+			name:     "tree",
+			template: `keys = "[[ range tree "app/" ]][[ .Key ]] [[ end ]]"`,
+			want:     `keys = "db/host db/user port "`,
+		},
+		{
+			name:     "secret",
+			template: `password = "[[ with secret "secret/app" ]][[ .Data.password ]][[ end ]]"`,
+			want:     `password = "hunter2"`,
+		},
+		{
+			name:     "secret missing",
+			template: `password = "[[ with secret "secret/missing" ]][[ .Data.password ]][[ end ]]"`,
+			wantErr:  "Vault secret 'secret/missing' does not exist",
+		},
+		{
+			name:     "services",
+			template: `services = "[[ range services ]][[ .Name ]] [[ end ]]"`,
+			want:     `services = "db web "`,
+		},
+		{
+			name:     "catalogService with tag",
+			template: `address = "[[ range catalogService "green.web" ]][[ .Address ]]:[[ .Port ]][[ end ]]"`,
+			want:     `address = "10.0.0.2:80"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Render(tt.template, "test.hcl")
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+type countingLookups struct {
+	templateFixtures
+	calls int
+}
+
+func (c *countingLookups) kvList(prefix string) (map[string]string, error) {
+	c.calls++
+	return c.templateFixtures.kvList(prefix)
+}
+
+func TestRenderer_lookupsAreCached(t *testing.T) {
+	counting := &countingLookups{templateFixtures: templateFixtures{ConsulKV: map[string]string{"a": "1"}}}
+
+	r, err := NewRenderer(nil, nil)
+	require.NoError(t, err)
+	r.lookups = newCachedLookups(counting)
+
+	for i := 0; i < 3; i++ {
+		got, err := r.Render(`a = "[[ key "a" ]]"`, "test.hcl")
+		require.NoError(t, err)
+		require.Equal(t, `a = "1"`, got)
+	}
+
+	require.Equal(t, 1, counting.calls)
+}
+
+func TestLoad_templatePartials(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "db_mount.tmpl"), []byte(`
+mount "db-[[ .name ]]" {
+	type = "database"
+
+	role "[[ .role ]]" {
+		db_name = "[[ .name ]]"
+	}
+}`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mounts.hcl"), []byte(`
+	environment "test" {
+		[[ range $name := split "," "users,orders" ]]
+		[[ template "db_mount" (dict "name" $name "role" "full") ]]
+		[[ end ]]
+	}`), 0644))
+
+	config, err := Load(Options{Dirs: []string{dir}, Environment: "test"})
+	require.NoError(t, err)
+	require.Len(t, config.VaultMounts, 2)
+	require.Equal(t, "db-users", config.VaultMounts[0].Name)
+	require.Equal(t, "db-orders", config.VaultMounts[1].Name)
+	require.Equal(t, "orders", config.VaultMounts[1].Roles[0].Data["db_name"])
+
+	_, err = config.Renderer().Render(`[[ dict "odd" ]]`, "inline")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "dict requires an even number of arguments, got 1")
+}
+
+func TestLoad_inherits(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "production.hcl"), []byte(`
+	environment "production" {
+		inherits = ["base"]
+
+		secret "db" {
+			override = true
+			password = "production"
+		}
+
+		mount "db" {
+			type = "database"
+
+			role "app" {
+				override = true
+				db_name  = "production"
+			}
+		}
+	}`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "base.hcl"), []byte(`
+	environment "base" {
+		inherits = ["common"]
+
+		secret "db" {
+			password = "base"
+		}
+
+		secret "api" {
+			override = true
+			key      = "base"
+		}
+
+		policy "app" {
+			path "secret/__ENV__/*" {
+				capabilities = ["read"]
+			}
+		}
+
+		mount "db" {
+			type              = "database"
+			default_lease_ttl = "1h"
+
+			role "app" {
+				db_name = "base"
+			}
+
+			role "readonly" {
+				db_name = "base"
+			}
+		}
+	}`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "common.hcl"), []byte(`
+	environment "common" {
+		secret "api" {
+			key = "common"
+		}
+
+		kv "shared" {
+			value = "common"
+		}
+	}`), 0644))
+
+	config, err := Load(Options{Dirs: []string{dir}, Environment: "production"})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"production"}, config.Environments.list())
+	require.Empty(t, config.duplicateSecrets)
+
+	values := make(map[string]interface{})
+	for _, secret := range config.VaultSecrets {
+		require.Equal(t, "production", secret.Environment.Name)
+		require.Len(t, secret.VaultSecret.Data, 1)
+		for _, v := range secret.VaultSecret.Data {
+			values[secret.Path] = v
+		}
+	}
+	require.Equal(t, map[string]interface{}{"db": "production", "api": "base"}, values)
+
+	require.Len(t, config.VaultPolicies, 1)
+	require.Equal(t, "production", config.VaultPolicies[0].Environment.Name)
+	require.Equal(t, "secret/production/", config.VaultPolicies[0].Paths[0].Prefix)
+
+	require.Len(t, config.VaultMounts, 1)
+	require.Equal(t, "db", config.VaultMounts[0].Name)
+	require.Equal(t, "1h", config.VaultMounts[0].DefaultLeaseTTL)
+	require.Len(t, config.VaultMounts[0].Roles, 2)
+	require.Equal(t, "production", config.VaultMounts[0].Roles[0].Data["db_name"])
+	require.NotContains(t, config.VaultMounts[0].Roles[0].Data, "override")
+	require.Equal(t, "readonly", config.VaultMounts[0].Roles[1].Name)
+
+	require.Len(t, config.ConsulKVs, 1)
+	require.Equal(t, "production", config.ConsulKVs[0].Environment.Name)
+
+	// the parent environment can still be loaded on its own
+	config, err = Load(Options{Dirs: []string{dir}, Environment: "base"})
+	require.NoError(t, err)
+	require.Len(t, config.VaultSecrets, 2)
+
+	// overriding an inherited resource without override = true is an error
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "base.hcl"), []byte(`
+	environment "base" {
+		inherits = ["common"]
+
+		secret "api" {
+			key = "base"
+		}
+	}`), 0644))
+	_, err = Load(Options{Dirs: []string{dir}, Environment: "base"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "secret 'api' overrides the one inherited from environment common, add override = true to it if this is intended")
+
+	_, err = Load(Options{Dirs: []string{dir}, Environment: "production"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "secret 'api' overrides the one inherited from environment common")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "common.hcl"), []byte(`
+	environment "common" {
+		inherits = ["production"]
+	}`), 0644))
+	_, err = Load(Options{Dirs: []string{dir}, Environment: "production"})
+	require.EqualError(t, err, "environment production inherits from itself ([production base common production])")
+}
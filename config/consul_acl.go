@@ -0,0 +1,278 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// ConsulACLPolicy is a Consul ACL policy, matched by name
+type ConsulACLPolicy struct {
+	Environment *Environment
+	Name        string
+	Description string   `hcl:"description"`
+	Datacenters []string `hcl:"datacenters"`
+	Rules       string   `hcl:"rules"`
+}
+
+// ConsulACLRole is a Consul ACL role, matched by name
+type ConsulACLRole struct {
+	Environment       *Environment
+	Name              string
+	Description       string   `hcl:"description"`
+	Policies          []string `hcl:"policies"`
+	ServiceIdentities []string `hcl:"service_identities"`
+}
+
+// ConsulACLToken is a Consul ACL token. Tokens don't have a name in Consul,
+// so the stanza name is used as (and matched by) the token description
+type ConsulACLToken struct {
+	Environment       *Environment
+	Name              string
+	Policies          []string `hcl:"policies"`
+	Roles             []string `hcl:"roles"`
+	ServiceIdentities []string `hcl:"service_identities"`
+	Local             bool     `hcl:"local"`
+}
+
+// ConsulACLBindingRule is a Consul auth method binding rule. Binding rules don't have
+// a name in Consul, so the stanza name is used as (and matched by) the rule description
+type ConsulACLBindingRule struct {
+	Environment *Environment
+	Name        string
+	AuthMethod  string `hcl:"auth_method"`
+	Selector    string `hcl:"selector"`
+	BindType    string `hcl:"bind_type"`
+	BindName    string `hcl:"bind_name"`
+}
+
+// ConsulACLPolicies ...
+type ConsulACLPolicies []*ConsulACLPolicy
+
+// add returns false if a policy with the same name already exist
+func (p *ConsulACLPolicies) add(policy *ConsulACLPolicy) bool {
+	for _, existing := range *p {
+		if existing.Name == policy.Name {
+			return false
+		}
+	}
+
+	*p = append(*p, policy)
+	return true
+}
+
+// ConsulACLRoles ...
+type ConsulACLRoles []*ConsulACLRole
+
+// add returns false if a role with the same name already exist
+func (r *ConsulACLRoles) add(role *ConsulACLRole) bool {
+	for _, existing := range *r {
+		if existing.Name == role.Name {
+			return false
+		}
+	}
+
+	*r = append(*r, role)
+	return true
+}
+
+// ConsulACLTokens ...
+type ConsulACLTokens []*ConsulACLToken
+
+// add returns false if a token with the same name already exist
+func (t *ConsulACLTokens) add(token *ConsulACLToken) bool {
+	for _, existing := range *t {
+		if existing.Name == token.Name {
+			return false
+		}
+	}
+
+	*t = append(*t, token)
+	return true
+}
+
+// ConsulACLBindingRules ...
+type ConsulACLBindingRules []*ConsulACLBindingRule
+
+// add returns false if a binding rule with the same name already exist
+func (b *ConsulACLBindingRules) add(rule *ConsulACLBindingRule) bool {
+	for _, existing := range *b {
+		if existing.Name == rule.Name {
+			return false
+		}
+	}
+
+	*b = append(*b, rule)
+	return true
+}
+
+// parseConsulACLPolicyStanza
+// parse out `environment -> consul_acl_policy {}`
+func (c *Config) parseConsulACLPolicyStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d consul_acl_policy{}", len(list.Items))
+	for _, policyAST := range list.Items {
+		if len(policyAST.Keys) != 1 {
+			return fmt.Errorf("Missing consul_acl_policy name in line %+v", policyAST.Pos())
+		}
+
+		valid := []string{"description", "datacenters", "rules", overrideKey}
+		if err := c.checkHCLKeys(policyAST.Val, valid); err != nil {
+			return err
+		}
+
+		var policy ConsulACLPolicy
+		if err := hcl.DecodeObject(&policy, policyAST.Val); err != nil {
+			return err
+		}
+
+		policy.Name = policyAST.Keys[0].Token.Value().(string)
+		policy.Environment = env
+
+		if policy.Rules == "" {
+			return fmt.Errorf("Missing rules in consul_acl_policy %s -> %s", env.Name, policy.Name)
+		}
+
+		if err := c.markOverride(policyAST.Val, "consul_acl_policy", policy.Name); err != nil {
+			return err
+		}
+
+		if !c.ConsulACLPolicies.add(&policy) && !c.overridden("consul_acl_policy", policy.Name) {
+			c.logger.Warnf("Ignored duplicate consul_acl_policy '%s' -> '%s' in line %s", env.Name, policy.Name, policyAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+// parseConsulACLRoleStanza
+// parse out `environment -> consul_acl_role {}`
+func (c *Config) parseConsulACLRoleStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d consul_acl_role{}", len(list.Items))
+	for _, roleAST := range list.Items {
+		if len(roleAST.Keys) != 1 {
+			return fmt.Errorf("Missing consul_acl_role name in line %+v", roleAST.Pos())
+		}
+
+		valid := []string{"description", "policies", "service_identities", overrideKey}
+		if err := c.checkHCLKeys(roleAST.Val, valid); err != nil {
+			return err
+		}
+
+		var role ConsulACLRole
+		if err := hcl.DecodeObject(&role, roleAST.Val); err != nil {
+			return err
+		}
+
+		role.Name = roleAST.Keys[0].Token.Value().(string)
+		role.Environment = env
+
+		if err := c.markOverride(roleAST.Val, "consul_acl_role", role.Name); err != nil {
+			return err
+		}
+
+		if !c.ConsulACLRoles.add(&role) && !c.overridden("consul_acl_role", role.Name) {
+			c.logger.Warnf("Ignored duplicate consul_acl_role '%s' -> '%s' in line %s", env.Name, role.Name, roleAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+// parseConsulACLTokenStanza
+// parse out `environment -> consul_acl_token {}`
+func (c *Config) parseConsulACLTokenStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d consul_acl_token{}", len(list.Items))
+	for _, tokenAST := range list.Items {
+		if len(tokenAST.Keys) != 1 {
+			return fmt.Errorf("Missing consul_acl_token name in line %+v", tokenAST.Pos())
+		}
+
+		valid := []string{"policies", "roles", "service_identities", "local", overrideKey}
+		if err := c.checkHCLKeys(tokenAST.Val, valid); err != nil {
+			return err
+		}
+
+		var token ConsulACLToken
+		if err := hcl.DecodeObject(&token, tokenAST.Val); err != nil {
+			return err
+		}
+
+		token.Name = tokenAST.Keys[0].Token.Value().(string)
+		token.Environment = env
+
+		if len(token.Policies) == 0 && len(token.Roles) == 0 && len(token.ServiceIdentities) == 0 {
+			return fmt.Errorf("consul_acl_token %s -> %s must have at least one of policies, roles or service_identities", env.Name, token.Name)
+		}
+
+		if err := c.markOverride(tokenAST.Val, "consul_acl_token", token.Name); err != nil {
+			return err
+		}
+
+		if !c.ConsulACLTokens.add(&token) && !c.overridden("consul_acl_token", token.Name) {
+			c.logger.Warnf("Ignored duplicate consul_acl_token '%s' -> '%s' in line %s", env.Name, token.Name, tokenAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+// parseConsulACLBindingRuleStanza
+// parse out `environment -> consul_acl_binding_rule {}`
+func (c *Config) parseConsulACLBindingRuleStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d consul_acl_binding_rule{}", len(list.Items))
+	for _, ruleAST := range list.Items {
+		if len(ruleAST.Keys) != 1 {
+			return fmt.Errorf("Missing consul_acl_binding_rule name in line %+v", ruleAST.Pos())
+		}
+
+		valid := []string{"auth_method", "selector", "bind_type", "bind_name", overrideKey}
+		if err := c.checkHCLKeys(ruleAST.Val, valid); err != nil {
+			return err
+		}
+
+		var rule ConsulACLBindingRule
+		if err := hcl.DecodeObject(&rule, ruleAST.Val); err != nil {
+			return err
+		}
+
+		rule.Name = ruleAST.Keys[0].Token.Value().(string)
+		rule.Environment = env
+
+		if rule.AuthMethod == "" || rule.BindType == "" || rule.BindName == "" {
+			return fmt.Errorf("consul_acl_binding_rule %s -> %s requires auth_method, bind_type and bind_name", env.Name, rule.Name)
+		}
+
+		switch rule.BindType {
+		case "service", "role", "node":
+		default:
+			return fmt.Errorf("Invalid bind_type '%s' in consul_acl_binding_rule %s -> %s, must be one of service, role or node", rule.BindType, env.Name, rule.Name)
+		}
+
+		if err := c.markOverride(ruleAST.Val, "consul_acl_binding_rule", rule.Name); err != nil {
+			return err
+		}
+
+		if !c.ConsulACLBindingRules.add(&rule) && !c.overridden("consul_acl_binding_rule", rule.Name) {
+			c.logger.Warnf("Ignored duplicate consul_acl_binding_rule '%s' -> '%s' in line %s", env.Name, rule.Name, ruleAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
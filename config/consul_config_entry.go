@@ -0,0 +1,396 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// ConsulConfigEntry is a Consul Connect config entry, e.g. service-defaults or service-router
+type ConsulConfigEntry struct {
+	Environment *Environment
+	Kind        string
+	Name        string
+	Entry       api.ConfigEntry
+}
+
+// ConsulConfigEntries ...
+type ConsulConfigEntries []*ConsulConfigEntry
+
+// add returns false if an entry with the same kind and name already exist
+func (e *ConsulConfigEntries) add(entry *ConsulConfigEntry) bool {
+	for _, existing := range *e {
+		if existing.Kind == entry.Kind && existing.Name == entry.Name {
+			return false
+		}
+	}
+
+	*e = append(*e, entry)
+	return true
+}
+
+// Sorted returns the entries in the order they must be written to Consul, since
+// routers, splitters and resolvers require the protocol from the defaults
+func (e ConsulConfigEntries) Sorted() ConsulConfigEntries {
+	order := map[string]int{
+		api.ProxyDefaults:   0,
+		api.ServiceDefaults: 1,
+		api.ServiceResolver: 2,
+		api.ServiceSplitter: 3,
+		api.ServiceRouter:   4,
+	}
+
+	result := append(ConsulConfigEntries{}, e...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return order[result[i].Kind] < order[result[j].Kind]
+	})
+
+	return result
+}
+
+// fieldType describe how a config entry field is written in HCL, and how it must be
+// converted before it can be decoded into the Consul API structs
+type fieldType int
+
+const (
+	// fieldValue is a string, number, bool or list of those
+	fieldValue fieldType = iota
+	// fieldObject is a single nested block
+	fieldObject
+	// fieldObjectList is a repeatable nested block
+	fieldObjectList
+	// fieldObjectMap is a block of named nested blocks, e.g. resolver subsets
+	fieldObjectMap
+	// fieldFreeMap is a block with user defined keys, passed as-is, e.g. meta
+	fieldFreeMap
+)
+
+type configEntryField struct {
+	kind   fieldType
+	fields configEntrySchema
+}
+
+type configEntrySchema map[string]configEntryField
+
+var (
+	entryValue     = configEntryField{kind: fieldValue}
+	entryFreeMap   = configEntryField{kind: fieldFreeMap}
+	entryObject    = func(s configEntrySchema) configEntryField { return configEntryField{kind: fieldObject, fields: s} }
+	entryList      = func(s configEntrySchema) configEntryField { return configEntryField{kind: fieldObjectList, fields: s} }
+	entryObjectMap = func(s configEntrySchema) configEntryField { return configEntryField{kind: fieldObjectMap, fields: s} }
+
+	meshGatewaySchema      = entryObject(configEntrySchema{"mode": entryValue})
+	transparentProxySchema = entryObject(configEntrySchema{"outbound_listener_port": entryValue, "dialed_directly": entryValue})
+	exposeSchema           = entryObject(configEntrySchema{
+		"checks": entryValue,
+		"paths":  entryList(configEntrySchema{"listener_port": entryValue, "path": entryValue, "local_path_port": entryValue, "protocol": entryValue}),
+	})
+	headerModifiersSchema = entryObject(configEntrySchema{"add": entryFreeMap, "set": entryFreeMap, "remove": entryValue})
+)
+
+// configEntrySchemas are the known fields of each supported config entry kind
+var configEntrySchemas = map[string]configEntrySchema{
+	api.ServiceDefaults: {
+		"protocol":                    entryValue,
+		"mode":                        entryValue,
+		"transparent_proxy":           transparentProxySchema,
+		"mesh_gateway":                meshGatewaySchema,
+		"expose":                      exposeSchema,
+		"external_sni":                entryValue,
+		"max_inbound_connections":     entryValue,
+		"local_connect_timeout_ms":    entryValue,
+		"local_request_timeout_ms":    entryValue,
+		"balance_inbound_connections": entryValue,
+		"destination":                 entryObject(configEntrySchema{"addresses": entryValue, "port": entryValue}),
+		"meta":                        entryFreeMap,
+	},
+	api.ProxyDefaults: {
+		"config":            entryFreeMap,
+		"mode":              entryValue,
+		"transparent_proxy": transparentProxySchema,
+		"mesh_gateway":      meshGatewaySchema,
+		"expose":            exposeSchema,
+		"meta":              entryFreeMap,
+	},
+	api.ServiceRouter: {
+		"routes": entryList(configEntrySchema{
+			"match": entryObject(configEntrySchema{
+				"http": entryObject(configEntrySchema{
+					"path_exact":  entryValue,
+					"path_prefix": entryValue,
+					"path_regex":  entryValue,
+					"methods":     entryValue,
+					"header": entryList(configEntrySchema{
+						"name": entryValue, "present": entryValue, "exact": entryValue, "prefix": entryValue, "suffix": entryValue, "regex": entryValue, "invert": entryValue,
+					}),
+					"query_param": entryList(configEntrySchema{
+						"name": entryValue, "present": entryValue, "exact": entryValue, "regex": entryValue,
+					}),
+				}),
+			}),
+			"destination": entryObject(configEntrySchema{
+				"service":                  entryValue,
+				"service_subset":           entryValue,
+				"namespace":                entryValue,
+				"partition":                entryValue,
+				"prefix_rewrite":           entryValue,
+				"request_timeout":          entryValue,
+				"idle_timeout":             entryValue,
+				"num_retries":              entryValue,
+				"retry_on_connect_failure": entryValue,
+				"retry_on":                 entryValue,
+				"retry_on_status_codes":    entryValue,
+				"request_headers":          headerModifiersSchema,
+				"response_headers":         headerModifiersSchema,
+			}),
+		}),
+		"meta": entryFreeMap,
+	},
+	api.ServiceSplitter: {
+		"splits": entryList(configEntrySchema{
+			"weight":           entryValue,
+			"service":          entryValue,
+			"service_subset":   entryValue,
+			"namespace":        entryValue,
+			"partition":        entryValue,
+			"request_headers":  headerModifiersSchema,
+			"response_headers": headerModifiersSchema,
+		}),
+		"meta": entryFreeMap,
+	},
+	api.ServiceResolver: {
+		"default_subset": entryValue,
+		"subsets":        entryObjectMap(configEntrySchema{"filter": entryValue, "only_passing": entryValue}),
+		"redirect": entryObject(configEntrySchema{
+			"service": entryValue, "service_subset": entryValue, "namespace": entryValue, "partition": entryValue, "datacenter": entryValue,
+		}),
+		"failover": entryObjectMap(configEntrySchema{
+			"service": entryValue, "service_subset": entryValue, "namespace": entryValue, "datacenters": entryValue,
+		}),
+		"connect_timeout": entryValue,
+		"request_timeout": entryValue,
+		"load_balancer": entryObject(configEntrySchema{
+			"policy":               entryValue,
+			"ring_hash_config":     entryObject(configEntrySchema{"minimum_ring_size": entryValue, "maximum_ring_size": entryValue}),
+			"least_request_config": entryObject(configEntrySchema{"choice_count": entryValue}),
+			"hash_policies": entryList(configEntrySchema{
+				"field":         entryValue,
+				"field_value":   entryValue,
+				"cookie_config": entryObject(configEntrySchema{"session": entryValue, "ttl": entryValue, "path": entryValue}),
+				"source_ip":     entryValue,
+				"terminal":      entryValue,
+			}),
+		}),
+		"meta": entryFreeMap,
+	},
+}
+
+// parseConsulConfigEntryStanza
+// parse out `environment -> config_entry "<kind>" "<name>" {}`
+func (c *Config) parseConsulConfigEntryStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d config_entry{}", len(list.Items))
+	for _, entryAST := range list.Items {
+		if len(entryAST.Keys) != 2 {
+			return fmt.Errorf("config_entry requires a kind and a name, e.g. config_entry \"service-defaults\" \"web\" {} in line %+v", entryAST.Pos())
+		}
+
+		kind := entryAST.Keys[0].Token.Value().(string)
+		name := entryAST.Keys[1].Token.Value().(string)
+
+		schema, ok := configEntrySchemas[kind]
+		if !ok {
+			return fmt.Errorf("Unsupported config_entry kind '%s' in line %+v", kind, entryAST.Pos())
+		}
+
+		if kind == api.ProxyDefaults && name != api.ProxyConfigGlobal {
+			return fmt.Errorf("config_entry \"%s\" must be named \"%s\" in line %+v", kind, api.ProxyConfigGlobal, entryAST.Pos())
+		}
+
+		if err := c.checkConfigEntryKeys(withoutOverride(entryAST.Val), schema); err != nil {
+			return err
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, withoutOverride(entryAST.Val)); err != nil {
+			return err
+		}
+
+		raw, err := convertConfigEntry(m, schema)
+		if err != nil {
+			return fmt.Errorf("Invalid config_entry %s -> %s: %s", kind, name, err)
+		}
+		raw["Kind"] = kind
+		raw["Name"] = name
+
+		entry, err := api.DecodeConfigEntry(raw)
+		if err != nil {
+			return fmt.Errorf("Invalid config_entry %s -> %s: %s", kind, name, err)
+		}
+
+		configEntry := &ConsulConfigEntry{
+			Environment: env,
+			Kind:        kind,
+			Name:        name,
+			Entry:       entry,
+		}
+
+		if err := c.markOverride(entryAST.Val, "config_entry", kind+"/"+name); err != nil {
+			return err
+		}
+
+		if !c.ConsulConfigEntries.add(configEntry) && !c.overridden("config_entry", kind+"/"+name) {
+			c.logger.Warnf("Ignored duplicate config_entry '%s' -> '%s' -> '%s' in line %s", env.Name, kind, name, entryAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+// checkConfigEntryKeys validates the keys of node and all nested blocks against schema
+func (c *Config) checkConfigEntryKeys(node ast.Node, schema configEntrySchema) error {
+	valid := make([]string, 0, len(schema))
+	for key := range schema {
+		valid = append(valid, key)
+	}
+
+	if err := c.checkHCLKeys(node, valid); err != nil {
+		return err
+	}
+
+	objectType, ok := node.(*ast.ObjectType)
+	if !ok {
+		return nil
+	}
+
+	for _, item := range objectType.List.Items {
+		field := schema[item.Keys[0].Token.Value().(string)]
+
+		switch field.kind {
+		case fieldObject, fieldObjectList:
+			if err := c.checkConfigEntryKeys(item.Val, field.fields); err != nil {
+				return err
+			}
+		case fieldObjectMap:
+			nested, ok := item.Val.(*ast.ObjectType)
+			if !ok {
+				continue
+			}
+
+			for _, named := range nested.List.Items {
+				if err := c.checkConfigEntryKeys(named.Val, field.fields); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// convertConfigEntry turns the snake_case HCL decoded map into the CamelCase
+// structure expected by api.DecodeConfigEntry
+func convertConfigEntry(m map[string]interface{}, schema configEntrySchema) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(m))
+
+	for key, v := range m {
+		field := schema[key]
+		name := camelCase(key)
+
+		switch field.kind {
+		case fieldValue:
+			result[name] = v
+
+		case fieldFreeMap:
+			obj, err := singleObject(key, v)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = obj
+
+		case fieldObject:
+			obj, err := singleObject(key, v)
+			if err != nil {
+				return nil, err
+			}
+
+			converted, err := convertConfigEntry(obj, field.fields)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = converted
+
+		case fieldObjectList:
+			items, ok := v.([]map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s must be a block", key)
+			}
+
+			list := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				converted, err := convertConfigEntry(item, field.fields)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, converted)
+			}
+			result[name] = list
+
+		case fieldObjectMap:
+			obj, err := singleObject(key, v)
+			if err != nil {
+				return nil, err
+			}
+
+			named := make(map[string]interface{}, len(obj))
+			for k, nested := range obj {
+				nestedObj, err := singleObject(key+"."+k, nested)
+				if err != nil {
+					return nil, err
+				}
+
+				converted, err := convertConfigEntry(nestedObj, field.fields)
+				if err != nil {
+					return nil, err
+				}
+				named[k] = converted
+			}
+			result[name] = named
+		}
+	}
+
+	return result, nil
+}
+
+// singleObject unwraps HCL decoded blocks, which are always decoded as a list of maps
+func singleObject(key string, v interface{}) (map[string]interface{}, error) {
+	switch obj := v.(type) {
+	case map[string]interface{}:
+		return obj, nil
+	case []map[string]interface{}:
+		if len(obj) != 1 {
+			return nil, fmt.Errorf("%s can only be specified once", key)
+		}
+		return obj[0], nil
+	}
+
+	return nil, fmt.Errorf("%s must be a block", key)
+}
+
+func camelCase(key string) string {
+	parts := strings.Split(key, "_")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// ConsulIntention is a Consul Connect intention between two services
+type ConsulIntention struct {
+	Environment *Environment
+	Source      string
+	Destination string
+	Action      string            `hcl:"action"`
+	Description string            `hcl:"description"`
+	Meta        map[string]string `hcl:"meta"`
+}
+
+// ToConsulIntention ...
+func (i *ConsulIntention) ToConsulIntention() *api.Intention {
+	return &api.Intention{
+		SourceName:      i.Source,
+		DestinationName: i.Destination,
+		SourceType:      api.IntentionSourceConsul,
+		Action:          api.IntentionAction(i.Action),
+		Description:     i.Description,
+		Meta:            i.Meta,
+	}
+}
+
+// ConsulIntentions ...
+type ConsulIntentions []*ConsulIntention
+
+// add returns false if an intention between the same services already exist
+func (c *ConsulIntentions) add(intention *ConsulIntention) bool {
+	for _, existing := range *c {
+		if existing.Source == intention.Source && existing.Destination == intention.Destination {
+			return false
+		}
+	}
+
+	*c = append(*c, intention)
+	return true
+}
+
+// parseConsulIntentionStanza
+// parse out `environment -> intention "<source>" "<destination>" {}`
+func (c *Config) parseConsulIntentionStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d intention{}", len(list.Items))
+	for _, intentionAST := range list.Items {
+		if len(intentionAST.Keys) != 2 {
+			return fmt.Errorf("intention requires a source and destination, e.g. intention \"web\" \"db\" {} in line %+v", intentionAST.Pos())
+		}
+
+		valid := []string{"action", "description", "meta", overrideKey}
+		if err := c.checkHCLKeys(intentionAST.Val, valid); err != nil {
+			return err
+		}
+
+		var intention ConsulIntention
+		if err := hcl.DecodeObject(&intention, intentionAST.Val); err != nil {
+			return err
+		}
+
+		intention.Source = intentionAST.Keys[0].Token.Value().(string)
+		intention.Destination = intentionAST.Keys[1].Token.Value().(string)
+		intention.Environment = env
+
+		switch api.IntentionAction(intention.Action) {
+		case api.IntentionActionAllow, api.IntentionActionDeny:
+		default:
+			return fmt.Errorf("Invalid action '%s' in intention %s -> %s, must be allow or deny", intention.Action, intention.Source, intention.Destination)
+		}
+
+		if err := c.markOverride(intentionAST.Val, "intention", intention.Source+" -> "+intention.Destination); err != nil {
+			return err
+		}
+
+		if !c.ConsulIntentions.add(&intention) && !c.overridden("intention", intention.Source+" -> "+intention.Destination) {
+			c.logger.Warnf("Ignored duplicate intention '%s' -> '%s' -> '%s' in line %s", env.Name, intention.Source, intention.Destination, intentionAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
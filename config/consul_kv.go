@@ -39,6 +39,17 @@ func (cs *ConsulKVs) add(kv *ConsulKV) {
 	*cs = append(*cs, kv)
 }
 
+// exists returns true if a kv with the same path already exist
+func (cs *ConsulKVs) exists(kv *ConsulKV) bool {
+	for _, existing := range *cs {
+		if existing.toPath() == kv.toPath() {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (c *Config) parseConsulKVStanza(list *ast.ObjectList, env *Environment, app *Application) error {
 	if len(list.Items) == 0 {
 		return nil
@@ -48,7 +59,7 @@ func (c *Config) parseConsulKVStanza(list *ast.ObjectList, env *Environment, app
 	for _, kvAST := range list.Items {
 		x := kvAST.Val.(*ast.ObjectType).List
 
-		valid := []string{"value"}
+		valid := []string{"value", overrideKey}
 		if err := c.checkHCLKeys(x, valid); err != nil {
 			return err
 		}
@@ -80,6 +91,14 @@ func (c *Config) parseConsulKVStanza(list *ast.ObjectList, env *Environment, app
 			Value:       []byte(value),
 		}
 
+		if err := c.markOverride(kvAST.Val, "kv", kv.toPath()); err != nil {
+			return err
+		}
+
+		if c.inheritedFrom != "" && c.ConsulKVs.exists(kv) && c.overridden("kv", kv.toPath()) {
+			continue
+		}
+
 		c.ConsulKVs.add(kv)
 	}
 
@@ -32,6 +32,17 @@ func (cs *ConsulServices) add(service *ConsulService) {
 	*cs = append(*cs, service)
 }
 
+// exists returns true if a service with the same ID already exist
+func (cs *ConsulServices) exists(service *ConsulService) bool {
+	for _, existing := range *cs {
+		if existing.Service.ID == service.Service.ID {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (cs *ConsulServices) List() ConsulServices {
 	return *cs
 }
@@ -46,7 +57,7 @@ func (c *Config) parseConsulServiceStanza(list *ast.ObjectList, _ *Environment)
 	for _, serviceAST := range list.Items {
 		x := serviceAST.Val.(*ast.ObjectType).List
 
-		valid := []string{"id", "address", "node", "port", "tags", "meta"}
+		valid := []string{"id", "address", "node", "port", "tags", "meta", overrideKey}
 		if err := c.checkHCLKeys(x, valid); err != nil {
 			return err
 		}
@@ -123,6 +134,14 @@ func (c *Config) parseConsulServiceStanza(list *ast.ObjectList, _ *Environment)
 			},
 		}
 
+		if err := c.markOverride(serviceAST.Val, "service", serviceID); err != nil {
+			return err
+		}
+
+		if c.inheritedFrom != "" && c.ConsulServices.exists(service) && c.overridden("service", serviceID) {
+			continue
+		}
+
 		c.ConsulServices.add(service)
 	}
 
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/seatgeek/hashi-helper/support"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// encryptedOnlySuffix marks files where all secret values must be encrypted
+const encryptedOnlySuffix = ".enc.hcl"
+
+// SecretValue is a string value inside a secret {} or secrets {} stanza
+type SecretValue struct {
+	Pos   token.Pos
+	Text  string // raw token text, including quotes
+	Value string
+}
+
+// IsEncrypted ...
+func (v *SecretValue) IsEncrypted() bool {
+	return support.IsEncryptedValue(v.Value)
+}
+
+// NewDecrypterFromCLI returns a decrypter using the keys provided as global CLI flags
+func NewDecrypterFromCLI(c *cli.Context) *support.Decrypter {
+	return &support.Decrypter{
+		AgeIdentityFile:  c.GlobalString("age-identity-file"),
+		PGPSecretKeyring: c.GlobalString("pgp-secret-keyring"),
+		PGPPassphrase:    os.Getenv("PGP_PASSPHRASE"),
+	}
+}
+
+// IsEncryptedOnlyFile returns true if file must not contain plaintext secret values
+func IsEncryptedOnlyFile(file string) bool {
+	return strings.HasSuffix(file, encryptedOnlySuffix)
+}
+
+// FindSecretValues returns all string values in secret {} and secrets {} stanzas of the HCL content,
+// custom_metadata is not considered secret
+func FindSecretValues(content string) ([]*SecretValue, error) {
+	root, err := hcl.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: root should be an object")
+	}
+
+	result := make([]*SecretValue, 0)
+
+	var addLiteral func(node ast.Node)
+	addLiteral = func(node ast.Node) {
+		switch v := node.(type) {
+		case *ast.LiteralType:
+			if v.Token.Type != token.STRING && v.Token.Type != token.HEREDOC {
+				return
+			}
+
+			value, _ := v.Token.Value().(string)
+			result = append(result, &SecretValue{Pos: v.Token.Pos, Text: v.Token.Text, Value: value})
+
+		case *ast.ListType:
+			for _, item := range v.List {
+				addLiteral(item)
+			}
+		}
+	}
+
+	var walk func(list *ast.ObjectList, inSecret bool)
+	walk = func(list *ast.ObjectList, inSecret bool) {
+		for _, item := range list.Items {
+			key := ""
+			if len(item.Keys) > 0 {
+				key, _ = item.Keys[0].Token.Value().(string)
+			}
+
+			if inSecret && key == "custom_metadata" {
+				continue
+			}
+
+			if objectType, ok := item.Val.(*ast.ObjectType); ok {
+				walk(objectType.List, inSecret || key == "secret" || key == "secrets")
+				continue
+			}
+
+			if inSecret {
+				addLiteral(item.Val)
+			}
+		}
+	}
+
+	walk(list, false)
+	return result, nil
+}
+
+// checkEncryptedOnly returns an error for every plaintext secret value in content
+func checkEncryptedOnly(content, file string) error {
+	values, err := FindSecretValues(content)
+	if err != nil {
+		return err
+	}
+
+	var result error
+	for _, value := range values {
+		if !value.IsEncrypted() {
+			result = multierror.Append(result, fmt.Errorf("plaintext secret value in encrypted-only file %s line %d", file, value.Pos.Line))
+		}
+	}
+
+	return result
+}
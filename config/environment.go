@@ -107,6 +107,16 @@ func (c *Config) processEnvironments(list *ast.ObjectList) error {
 			envName := envKey.Token.Value().(string)
 			c.logger.Debugf("  Found environment %s", envName)
 
+			// when processing an inherited environment, only its resources are added, as if
+			// they were defined in the target environment
+			if c.inheritedFrom != "" {
+				if envName != c.inheritedFrom {
+					continue
+				}
+
+				envName = c.targetEnvironment
+			}
+
 			// check if we are limiting to a specific environment, and skip the current environment
 			// if it does not match the required environment name
 			if c.shouldSkipEnvironment(envName, c.targetEnvironment) {
@@ -121,13 +131,24 @@ func (c *Config) processEnvironments(list *ast.ObjectList) error {
 
 			// check for valid keys inside an environment stanza
 			x := envAST.Val.(*ast.ObjectType).List
-			valid := []string{"application", "auth", "audit", "policy", "mount", "secret", "secrets", "service", "kv"}
+			valid := []string{"application", "auth", "audit", "inherits", "policy", "policy_test", "mount", "secret", "secrets", "service", "kv",
+				"consul_acl_policy", "consul_acl_role", "consul_acl_token", "consul_acl_binding_rule",
+				"config_entry", "intention", "nomad_namespace", "nomad_acl_policy", "nomad_quota",
+				"identity_entity", "identity_group", "identity_group_alias", "namespace"}
 			if err := c.checkHCLKeys(x, valid); err != nil {
 				return err
 			}
 
 			env := c.Environments.getOrSet(&Environment{Name: envName})
 
+			// vault resources in the environment stanza are in the root namespace,
+			// unless the environment has a `namespace = "<path>"` attribute
+			namespace, err := c.parseVaultEnvironmentNamespace(x.Filter("namespace"), env)
+			if err != nil {
+				return err
+			}
+			c.vaultNamespace = namespace
+
 			c.logger.Debug("Scanning for audit{}")
 			if err := c.parseVaultAuditStanza(x.Filter("audit"), env); err != nil {
 				return err
@@ -164,12 +185,43 @@ func (c *Config) processEnvironments(list *ast.ObjectList) error {
 			}
 			c.logger.Debug("Done")
 
+			c.logger.Debug("Scanning for vault policy_test{}")
+			if err := c.parseVaultPolicyTestStanza(x.Filter("policy_test"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
 			c.logger.Debug("Scanning for vault mount{}")
 			if err := c.parseVaultMountStanza(x.Filter("mount"), env); err != nil {
 				return err
 			}
 			c.logger.Debug("Done")
 
+			c.logger.Debug("Scanning for vault namespace{}")
+			if err := c.parseVaultNamespaceStanza(x.Filter("namespace"), env); err != nil {
+				return err
+			}
+			c.vaultNamespace = ""
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for vault identity_entity{}")
+			if err := c.parseVaultIdentityEntityStanza(x.Filter("identity_entity"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for vault identity_group{}")
+			if err := c.parseVaultIdentityGroupStanza(x.Filter("identity_group"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for vault identity_group_alias{}")
+			if err := c.parseVaultIdentityGroupAliasStanza(x.Filter("identity_group_alias"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
 			c.logger.Debug("Scanning for consul service{}")
 			if err := c.parseConsulServiceStanza(x.Filter("service"), env); err != nil {
 				return err
@@ -182,6 +234,60 @@ func (c *Config) processEnvironments(list *ast.ObjectList) error {
 			}
 			c.logger.Debug("Done")
 
+			c.logger.Debug("Scanning for consul_acl_policy{}")
+			if err := c.parseConsulACLPolicyStanza(x.Filter("consul_acl_policy"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for consul_acl_role{}")
+			if err := c.parseConsulACLRoleStanza(x.Filter("consul_acl_role"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for consul_acl_token{}")
+			if err := c.parseConsulACLTokenStanza(x.Filter("consul_acl_token"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for consul_acl_binding_rule{}")
+			if err := c.parseConsulACLBindingRuleStanza(x.Filter("consul_acl_binding_rule"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for consul config_entry{}")
+			if err := c.parseConsulConfigEntryStanza(x.Filter("config_entry"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for consul intention{}")
+			if err := c.parseConsulIntentionStanza(x.Filter("intention"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for nomad_quota{}")
+			if err := c.parseNomadQuotaStanza(x.Filter("nomad_quota"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for nomad_namespace{}")
+			if err := c.parseNomadNamespaceStanza(x.Filter("nomad_namespace"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
+			c.logger.Debug("Scanning for nomad_acl_policy{}")
+			if err := c.parseNomadACLPolicyStanza(x.Filter("nomad_acl_policy"), env); err != nil {
+				return err
+			}
+			c.logger.Debug("Done")
+
 			c.logger.Debugf("Adding env %s to state", env.Name)
 			c.Environments.add(env)
 			c.logger.Debug("Done")
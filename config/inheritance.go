@@ -0,0 +1,232 @@
+package config
+
+import (
+	"fmt"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// parsedFile is a rendered and parsed config file, waiting to be processed
+type parsedFile struct {
+	list *ast.ObjectList
+	file string
+}
+
+// overrideKey is the attribute a resource must set to override the resource of the same name
+// inherited from another environment
+const overrideKey = "override"
+
+// processParsedFiles processes the target environment first, and then every environment it inherits
+// from, nearest first. The first definition of a resource wins, so the inheriting environment overrides
+// the resources of its parents, as long as it marks them with `override = true`.
+//
+// `environment "*"` is processed together with the target environment, so its resources take
+// precedence over inherited ones as well
+func (c *Config) processParsedFiles() error {
+	parents, err := c.inheritedEnvironments()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		c.inheritedFrom = ""
+		c.layer = 0
+		c.overrides = nil
+		c.parsedFiles = nil
+	}()
+
+	c.overrides = make(map[string]int)
+
+	for i, layer := range append([]string{""}, parents...) {
+		c.inheritedFrom = layer
+		c.layer = i
+
+		var result error
+		for _, parsed := range c.parsedFiles {
+			c.overrideErrors = nil
+			if err := c.processContent(parsed.list, parsed.file); err != nil {
+				result = multierror.Append(result, fmt.Errorf("[%s] %s", parsed.file, err))
+			}
+
+			if merr, ok := c.overrideErrors.(*multierror.Error); ok {
+				for _, err := range merr.Errors {
+					result = multierror.Append(result, fmt.Errorf("[%s] %s", parsed.file, err))
+				}
+			}
+		}
+
+		c.overrideErrors = nil
+		if result != nil {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// inheritedEnvironments returns all environments the target environment inherits from, in the order
+// they are applied: each parent in the order of `inherits`, directly followed by its own parents
+func (c *Config) inheritedEnvironments() ([]string, error) {
+	if c.targetEnvironment == "" {
+		return nil, nil
+	}
+
+	known := make(map[string]bool)
+	parents := make(map[string][]string)
+
+	for _, parsed := range c.parsedFiles {
+		for _, envAST := range parsed.list.Filter("environment").Items {
+			inherits, err := parseInherits(envAST)
+			if err != nil {
+				return nil, fmt.Errorf("[%s] %s", parsed.file, err)
+			}
+
+			for _, envKey := range envAST.Keys {
+				name := envKey.Token.Value().(string)
+				known[name] = true
+
+				if name == "*" && len(inherits) > 0 {
+					return nil, fmt.Errorf("[%s] environment \"*\" can't inherit from other environments", parsed.file)
+				}
+
+				for _, parent := range inherits {
+					if !containsString(parents[name], parent) {
+						parents[name] = append(parents[name], parent)
+					}
+				}
+			}
+		}
+	}
+
+	result := make([]string, 0)
+
+	var visit func(name string, chain []string) error
+	visit = func(name string, chain []string) error {
+		for _, parent := range parents[name] {
+			if containsString(chain, parent) {
+				return fmt.Errorf("environment %s inherits from itself (%v)", parent, append(chain, parent))
+			}
+
+			if !known[parent] {
+				return fmt.Errorf("environment %s inherits from unknown environment %s", name, parent)
+			}
+
+			if containsString(result, parent) {
+				continue
+			}
+
+			result = append(result, parent)
+			if err := visit(parent, append(chain, parent)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := visit(c.targetEnvironment, []string{c.targetEnvironment}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func parseInherits(envAST *ast.ObjectItem) ([]string, error) {
+	if _, ok := envAST.Val.(*ast.ObjectType); !ok {
+		return nil, nil
+	}
+
+	var data struct {
+		Inherits []string `hcl:"inherits"`
+	}
+	if err := hcl.DecodeObject(&data, envAST.Val); err != nil {
+		return nil, fmt.Errorf("Invalid inherits in environment: %s", err)
+	}
+
+	return data.Inherits, nil
+}
+
+// parseOverride returns the `override = true` attribute of a resource stanza
+func parseOverride(node ast.Node) (bool, error) {
+	objectType, ok := node.(*ast.ObjectType)
+	if !ok {
+		return false, nil
+	}
+
+	items := objectType.List.Filter(overrideKey).Items
+	if len(items) == 0 {
+		return false, nil
+	}
+
+	if len(items) > 1 {
+		return false, fmt.Errorf("override can only be specified once")
+	}
+
+	if literal, ok := items[0].Val.(*ast.LiteralType); ok {
+		if override, ok := literal.Token.Value().(bool); ok {
+			return override, nil
+		}
+	}
+
+	return false, fmt.Errorf("override must be a boolean")
+}
+
+// withoutOverride returns a copy of node without the `override` attribute, for stanzas where every
+// attribute is data sent to the API
+func withoutOverride(node ast.Node) ast.Node {
+	objectType, ok := node.(*ast.ObjectType)
+	if !ok {
+		return node
+	}
+
+	list := &ast.ObjectList{}
+	for _, item := range objectType.List.Items {
+		if len(item.Keys) == 1 && item.Keys[0].Token.Value() == overrideKey {
+			continue
+		}
+
+		list.Add(item)
+	}
+
+	return &ast.ObjectType{Lbrace: objectType.Lbrace, Rbrace: objectType.Rbrace, List: list}
+}
+
+// markOverride records that the resource kind name defined by node in the environment being processed
+// has `override = true`, so it may override the resource of the same name in the environments it inherits from
+func (c *Config) markOverride(node ast.Node, kind, name string) error {
+	override, err := parseOverride(node)
+	if err != nil {
+		return fmt.Errorf("Invalid override in %s '%s': %s", kind, name, err)
+	}
+
+	if !override || c.overrides == nil {
+		return nil
+	}
+
+	key := kind + "|" + name
+	if _, ok := c.overrides[key]; !ok {
+		c.overrides[key] = c.layer
+	}
+
+	return nil
+}
+
+// overridden returns true, and logs it, if a resource that is already defined is inherited from another
+// environment, rather than being a duplicate in the same environment. The resource that overrides it
+// must have `override = true`, otherwise an error is recorded
+func (c *Config) overridden(kind, name string) bool {
+	if c.inheritedFrom == "" {
+		return false
+	}
+
+	if layer, ok := c.overrides[kind+"|"+name]; !ok || layer >= c.layer {
+		c.overrideErrors = multierror.Append(c.overrideErrors, fmt.Errorf(
+			"%s '%s' overrides the one inherited from environment %s, add override = true to it if this is intended", kind, name, c.inheritedFrom))
+		return true
+	}
+
+	c.logger.Infof("%s '%s' inherited from environment %s is overridden", kind, name, c.inheritedFrom)
+	return true
+}
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/nomad/api"
+)
+
+// NomadNamespace ...
+type NomadNamespace struct {
+	Environment *Environment
+	Name        string
+	Description string            `hcl:"description"`
+	Quota       string            `hcl:"quota"`
+	Meta        map[string]string `hcl:"meta"`
+}
+
+// ToNomadNamespace ...
+func (n *NomadNamespace) ToNomadNamespace() *api.Namespace {
+	return &api.Namespace{
+		Name:        n.Name,
+		Description: n.Description,
+		Quota:       n.Quota,
+		Meta:        n.Meta,
+	}
+}
+
+// NomadACLPolicy ...
+type NomadACLPolicy struct {
+	Environment *Environment
+	Name        string
+	Description string `hcl:"description"`
+	Rules       string `hcl:"rules"`
+}
+
+// ToNomadACLPolicy ...
+func (p *NomadACLPolicy) ToNomadACLPolicy() *api.ACLPolicy {
+	return &api.ACLPolicy{
+		Name:        p.Name,
+		Description: p.Description,
+		Rules:       p.Rules,
+	}
+}
+
+// NomadQuota ...
+type NomadQuota struct {
+	Environment *Environment
+	Name        string
+	Description string
+	Limits      []*NomadQuotaLimit
+}
+
+// NomadQuotaLimit is the limit for a single region, zero values are unlimited
+type NomadQuotaLimit struct {
+	Region         string
+	CPU            int `hcl:"cpu"`
+	Cores          int `hcl:"cores"`
+	MemoryMB       int `hcl:"memory"`
+	MemoryMaxMB    int `hcl:"memory_max"`
+	VariablesLimit int `hcl:"variables_limit"`
+}
+
+// ToNomadQuota ...
+func (q *NomadQuota) ToNomadQuota() *api.QuotaSpec {
+	spec := &api.QuotaSpec{
+		Name:        q.Name,
+		Description: q.Description,
+		Limits:      make([]*api.QuotaLimit, 0, len(q.Limits)),
+	}
+
+	optional := func(v int) *int {
+		if v == 0 {
+			return nil
+		}
+		return &v
+	}
+
+	for _, limit := range q.Limits {
+		spec.Limits = append(spec.Limits, &api.QuotaLimit{
+			Region: limit.Region,
+			RegionLimit: &api.Resources{
+				CPU:         optional(limit.CPU),
+				Cores:       optional(limit.Cores),
+				MemoryMB:    optional(limit.MemoryMB),
+				MemoryMaxMB: optional(limit.MemoryMaxMB),
+			},
+			VariablesLimit: optional(limit.VariablesLimit),
+		})
+	}
+
+	return spec
+}
+
+// NomadNamespaces ...
+type NomadNamespaces []*NomadNamespace
+
+// add returns false if a namespace with the same name already exist
+func (n *NomadNamespaces) add(namespace *NomadNamespace) bool {
+	for _, existing := range *n {
+		if existing.Name == namespace.Name {
+			return false
+		}
+	}
+
+	*n = append(*n, namespace)
+	return true
+}
+
+// NomadACLPolicies ...
+type NomadACLPolicies []*NomadACLPolicy
+
+// add returns false if a policy with the same name already exist
+func (p *NomadACLPolicies) add(policy *NomadACLPolicy) bool {
+	for _, existing := range *p {
+		if existing.Name == policy.Name {
+			return false
+		}
+	}
+
+	*p = append(*p, policy)
+	return true
+}
+
+// NomadQuotas ...
+type NomadQuotas []*NomadQuota
+
+// add returns false if a quota with the same name already exist
+func (q *NomadQuotas) add(quota *NomadQuota) bool {
+	for _, existing := range *q {
+		if existing.Name == quota.Name {
+			return false
+		}
+	}
+
+	*q = append(*q, quota)
+	return true
+}
+
+// parseNomadNamespaceStanza
+// parse out `environment -> nomad_namespace {}`
+func (c *Config) parseNomadNamespaceStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d nomad_namespace{}", len(list.Items))
+	for _, namespaceAST := range list.Items {
+		if len(namespaceAST.Keys) != 1 {
+			return fmt.Errorf("Missing nomad_namespace name in line %+v", namespaceAST.Pos())
+		}
+
+		valid := []string{"description", "quota", "meta", overrideKey}
+		if err := c.checkHCLKeys(namespaceAST.Val, valid); err != nil {
+			return err
+		}
+
+		var namespace NomadNamespace
+		if err := hcl.DecodeObject(&namespace, namespaceAST.Val); err != nil {
+			return err
+		}
+
+		namespace.Name = namespaceAST.Keys[0].Token.Value().(string)
+		namespace.Environment = env
+
+		if err := c.markOverride(namespaceAST.Val, "nomad_namespace", namespace.Name); err != nil {
+			return err
+		}
+
+		if !c.NomadNamespaces.add(&namespace) && !c.overridden("nomad_namespace", namespace.Name) {
+			c.logger.Warnf("Ignored duplicate nomad_namespace '%s' -> '%s' in line %s", env.Name, namespace.Name, namespaceAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+// parseNomadACLPolicyStanza
+// parse out `environment -> nomad_acl_policy {}`
+func (c *Config) parseNomadACLPolicyStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d nomad_acl_policy{}", len(list.Items))
+	for _, policyAST := range list.Items {
+		if len(policyAST.Keys) != 1 {
+			return fmt.Errorf("Missing nomad_acl_policy name in line %+v", policyAST.Pos())
+		}
+
+		valid := []string{"description", "rules", overrideKey}
+		if err := c.checkHCLKeys(policyAST.Val, valid); err != nil {
+			return err
+		}
+
+		var policy NomadACLPolicy
+		if err := hcl.DecodeObject(&policy, policyAST.Val); err != nil {
+			return err
+		}
+
+		policy.Name = policyAST.Keys[0].Token.Value().(string)
+		policy.Environment = env
+
+		if policy.Rules == "" {
+			return fmt.Errorf("Missing rules in nomad_acl_policy %s -> %s", env.Name, policy.Name)
+		}
+
+		if err := c.markOverride(policyAST.Val, "nomad_acl_policy", policy.Name); err != nil {
+			return err
+		}
+
+		if !c.NomadACLPolicies.add(&policy) && !c.overridden("nomad_acl_policy", policy.Name) {
+			c.logger.Warnf("Ignored duplicate nomad_acl_policy '%s' -> '%s' in line %s", env.Name, policy.Name, policyAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+// parseNomadQuotaStanza
+// parse out `environment -> nomad_quota {}`
+func (c *Config) parseNomadQuotaStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d nomad_quota{}", len(list.Items))
+	for _, quotaAST := range list.Items {
+		if len(quotaAST.Keys) != 1 {
+			return fmt.Errorf("Missing nomad_quota name in line %+v", quotaAST.Pos())
+		}
+
+		x := quotaAST.Val.(*ast.ObjectType).List
+
+		valid := []string{"description", "limit", overrideKey}
+		if err := c.checkHCLKeys(x, valid); err != nil {
+			return err
+		}
+
+		quota := &NomadQuota{
+			Environment: env,
+			Name:        quotaAST.Keys[0].Token.Value().(string),
+			Limits:      make([]*NomadQuotaLimit, 0),
+		}
+
+		if descriptionAST := x.Filter("description"); len(descriptionAST.Items) > 0 {
+			if err := hcl.DecodeObject(&quota.Description, descriptionAST.Items[0].Val); err != nil {
+				return err
+			}
+		}
+
+		for _, limitAST := range x.Filter("limit").Items {
+			if len(limitAST.Keys) != 1 {
+				return fmt.Errorf("Missing region name for limit in nomad_quota %s -> %s", env.Name, quota.Name)
+			}
+
+			valid := []string{"cpu", "cores", "memory", "memory_max", "variables_limit"}
+			if err := c.checkHCLKeys(limitAST.Val, valid); err != nil {
+				return err
+			}
+
+			var limit NomadQuotaLimit
+			if err := hcl.DecodeObject(&limit, limitAST.Val); err != nil {
+				return err
+			}
+
+			limit.Region = limitAST.Keys[0].Token.Value().(string)
+			quota.Limits = append(quota.Limits, &limit)
+		}
+
+		if len(quota.Limits) == 0 {
+			return fmt.Errorf("nomad_quota %s -> %s requires at least one limit", env.Name, quota.Name)
+		}
+
+		if err := c.markOverride(quotaAST.Val, "nomad_quota", quota.Name); err != nil {
+			return err
+		}
+
+		if !c.NomadQuotas.add(quota) && !c.overridden("nomad_quota", quota.Name) {
+			c.logger.Warnf("Ignored duplicate nomad_quota '%s' -> '%s' in line %s", env.Name, quota.Name, quotaAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -17,17 +19,27 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
-type renderer struct {
+// Renderer renders config files as go templates with [[ ]] delimiters, before they are parsed as HCL
+type Renderer struct {
 	variables        map[string]interface{}
 	variablesScratch *Scratch
 	scratch          *Scratch
 	readConfigFiles  []string
+	lookups          *cachedLookups
+	partials         map[string]string
+	partialFiles     map[string]string
 }
 
-func newRenderer(variables, variableFiles []string) (*renderer, error) {
-	r := &renderer{
+// NewRenderer returns a Renderer exposing variables (key=value pairs) and the content of
+// variableFiles (.hcl, .yaml or .json) to templates, variables take precedence over files
+func NewRenderer(variables, variableFiles []string) (*Renderer, error) {
+	r := &Renderer{
 		variables: map[string]interface{}{},
 		scratch:   &Scratch{},
+		lookups:   newCachedLookups(&liveLookups{}),
+
+		partials:     map[string]string{},
+		partialFiles: map[string]string{},
 	}
 
 	if err := r.readTemplateVariablesFiles(variableFiles); err != nil {
@@ -41,7 +53,53 @@ func newRenderer(variables, variableFiles []string) (*renderer, error) {
 	return r, nil
 }
 
-func (r *renderer) renderContent(content, file string, depth int) (string, error) {
+// UseTemplateFixtures makes the live lookup template funcs (key, ls, tree, secret, services, ...)
+// read from a JSON file instead of Consul and Vault
+func (r *Renderer) UseTemplateFixtures(file string) error {
+	fixtures, err := readTemplateFixtures(file)
+	if err != nil {
+		return err
+	}
+
+	r.lookups = newCachedLookups(fixtures)
+	return nil
+}
+
+// LoadPartials reads all *.tmpl files in dir (recursively), so config files can include them with
+// [[ template "<file name without .tmpl>" (dict "key" "value") ]]
+func (r *Renderer) LoadPartials(dir string) error {
+	return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || filepath.Ext(file) != ".tmpl" {
+			return nil
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		if existing, ok := r.partialFiles[name]; ok && existing != file {
+			return fmt.Errorf("Template partial %s is defined in both %s and %s", name, existing, file)
+		}
+
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("Loaded template partial %s from %s", name, file)
+		r.partials[name] = string(content)
+		r.partialFiles[name] = file
+		return nil
+	})
+}
+
+// Render renders content as a template and formats the result as HCL, file is only used in errors
+func (r *Renderer) Render(content, file string) (string, error) {
+	return r.renderContent(content, file, 0)
+}
+
+func (r *Renderer) renderContent(content, file string, depth int) (string, error) {
 	log.Debugf("Rendering file %s (depth %d)", file, depth)
 
 	if depth > 10 {
@@ -53,23 +111,28 @@ func (r *renderer) renderContent(content, file string, depth int) (string, error
 		"base64Encode":           r.base64EncodeFunc,
 		"base64URLDecode":        r.base64URLDecodeFunc,
 		"base64URLEncode":        r.base64URLEncodeFunc,
+		"catalogService":         r.catalogServiceFunc,
 		"consulDomain":           r.consulDomainFunc,
 		"contains":               r.containsFunc,
 		"containsAll":            r.containsSomeFunc(true, true),
 		"containsAny":            r.containsSomeFunc(false, false),
 		"containsNone":           r.containsSomeFunc(true, false),
 		"containsNotAll":         r.containsSomeFunc(false, true),
+		"dict":                   r.dictFunc,
 		"env":                    r.envFunc,
 		"githubAssignTeamPolicy": r.githubAssignTeamPolicyFunc,
 		"grantCredentials":       r.grantCredentialsFunc,
 		"grantCredentialsPolicy": r.grantCredentialsPolicyFunc,
 		"in":                     r.in,
 		"join":                   r.joinFunc,
+		"key":                    r.keyFunc,
+		"keyOrDefault":           r.keyOrDefaultFunc,
 		"ldapAssignGroupPolicy":  r.ldapAssignTeamPolicyFunc,
 		"lookup":                 r.lookupVarFunc,
 		"lookupDefault":          r.lookupVarDefaultFunc,
 		"lookupMap":              r.lookupVarMapFunc,
 		"lookupMapDefault":       r.lookupVarMapDefaultFunc,
+		"ls":                     r.lsFunc,
 		"parseBool":              r.parseBoolFunc,
 		"parseFloat":             r.parseFloatFunc,
 		"parseInt":               r.parseIntFunc,
@@ -80,8 +143,10 @@ func (r *renderer) renderContent(content, file string, depth int) (string, error
 		"regexReplaceAll":        r.regexReplaceAllFunc,
 		"replaceAll":             r.replaceAllFunc,
 		"scratch":                r.createScratch(),
+		"secret":                 r.secretFunc,
 		"service":                r.consulServiceFunc,
 		"serviceWithTag":         r.consulServiceWithTagFunc,
+		"services":               r.servicesFunc,
 		"split":                  r.splitFunc,
 		"timestamp":              r.timestampFunc,
 		"toJSON":                 r.toJSONFunc,
@@ -90,15 +155,29 @@ func (r *renderer) renderContent(content, file string, depth int) (string, error
 		"toTitle":                r.toTitleFunc,
 		"toUpper":                r.toUpperFunc,
 		"toYAML":                 r.toYAMLFunc,
+		"tree":                   r.treeFunc,
 		"trimSpace":              r.trimSpaceFunc,
 	}
 
-	tmpl, err := template.New(file).
+	tmpl := template.New(file).
 		Funcs(fns).
 		Option("missingkey=error").
-		Delims("[[", "]]").
-		Parse(content)
-	if err != nil {
+		Delims("[[", "]]")
+
+	// make the partials available to the template, they share its funcs and delimiters
+	names := make([]string, 0, len(r.partials))
+	for name := range r.partials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := tmpl.New(name).Parse(r.partials[name]); err != nil {
+			return "", fmt.Errorf("Could not parse template partial %s: %s", r.partialFiles[name], err)
+		}
+	}
+
+	if _, err := tmpl.Parse(content); err != nil {
 		return "", err
 	}
 
@@ -132,7 +211,7 @@ func (r *renderer) renderContent(content, file string, depth int) (string, error
 	return strings.TrimSpace(string(res)), nil
 }
 
-func (r *renderer) parseTemplateVariables(pairs []string) error {
+func (r *Renderer) parseTemplateVariables(pairs []string) error {
 	for _, val := range pairs {
 		chunks := strings.SplitN(val, "=", 2)
 		if len(chunks) != 2 {
@@ -145,7 +224,7 @@ func (r *renderer) parseTemplateVariables(pairs []string) error {
 	return nil
 }
 
-func (r *renderer) readTemplateVariablesFiles(files []string) error {
+func (r *Renderer) readTemplateVariablesFiles(files []string) error {
 	for _, variableFile := range files {
 		ext := path.Ext(variableFile)
 
@@ -179,7 +258,7 @@ func (r *renderer) readTemplateVariablesFiles(files []string) error {
 }
 
 // parseJSONVars will read a file from disk and JSON unmarshal it into a map[string]interface{}
-func (r *renderer) parseJSONVars(variableFile string) (variables map[string]interface{}, err error) {
+func (r *Renderer) parseJSONVars(variableFile string) (variables map[string]interface{}, err error) {
 	jsonFile, err := ioutil.ReadFile(variableFile)
 	if err != nil {
 		return nil, err
@@ -194,7 +273,7 @@ func (r *renderer) parseJSONVars(variableFile string) (variables map[string]inte
 }
 
 // parseYAMLVars will read a file from disk and yaml unmarshal it into a map[string]interface{}
-func (r *renderer) parseYAMLVars(variableFile string) (variables map[string]interface{}, err error) {
+func (r *Renderer) parseYAMLVars(variableFile string) (variables map[string]interface{}, err error) {
 	yamlFile, err := ioutil.ReadFile(variableFile)
 	if err != nil {
 		return nil, err
@@ -209,7 +288,7 @@ func (r *renderer) parseYAMLVars(variableFile string) (variables map[string]inte
 }
 
 // parseHCLVars will read a file from disk and hcl unmarshal it into a map[string]interface{}
-func (r *renderer) parseHCLVars(variableFile string) (variables map[string]interface{}, err error) {
+func (r *Renderer) parseHCLVars(variableFile string) (variables map[string]interface{}, err error) {
 	hclFile, err := ioutil.ReadFile(variableFile)
 	if err != nil {
 		return nil, err
@@ -223,7 +302,7 @@ func (r *renderer) parseHCLVars(variableFile string) (variables map[string]inter
 	return variables, nil
 }
 
-func (r *renderer) createScratch() func() *Scratch {
+func (r *Renderer) createScratch() func() *Scratch {
 	return func() *Scratch {
 		if r.scratch == nil {
 			r.scratch = &Scratch{}
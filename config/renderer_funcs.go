@@ -9,10 +9,12 @@ import (
 	"os/exec"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	vault "github.com/hashicorp/vault/api"
 	"github.com/pkg/errors"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -21,7 +23,7 @@ var now = func() time.Time { return time.Now().UTC() }
 
 // lookupVarFunc will return the template variable identified by `key` or return an error
 // which will abort the template rendering.
-func (r *renderer) lookupVarFunc(key string) (interface{}, error) {
+func (r *Renderer) lookupVarFunc(key string) (interface{}, error) {
 	val, ok := r.variables[key]
 	if !ok {
 		return "", fmt.Errorf("Missing template variable '%s'", key)
@@ -32,7 +34,7 @@ func (r *renderer) lookupVarFunc(key string) (interface{}, error) {
 
 // lookupVarDefaultFunc will return the template variable identified by `key` or a default value
 // provided in `def`.
-func (r *renderer) lookupVarDefaultFunc(key string, def interface{}) (interface{}, error) {
+func (r *Renderer) lookupVarDefaultFunc(key string, def interface{}) (interface{}, error) {
 	val, ok := r.variables[key]
 	if !ok {
 		return def, nil
@@ -47,7 +49,7 @@ func (r *renderer) lookupVarDefaultFunc(key string, def interface{}) (interface{
 // If "key" is not a template variable, an error will be returned
 // If "key" is not a map[string]interface{}, an error will be returned
 // if "mapKey" do not exist in the map of "key", an error will be returnedd
-func (r *renderer) lookupVarMapFunc(key, mapKey string) (interface{}, error) {
+func (r *Renderer) lookupVarMapFunc(key, mapKey string) (interface{}, error) {
 	if r.variablesScratch == nil {
 		r.variablesScratch = &Scratch{values: r.variables}
 	}
@@ -61,7 +63,7 @@ func (r *renderer) lookupVarMapFunc(key, mapKey string) (interface{}, error) {
 // If "key" is not a template variable, an error will be returned
 // If "key" is not a map[string]interface{}, an error will be returned
 // if "mapKey" do not exist in the map of "key", the default value provided in "def" is returned.
-func (r *renderer) lookupVarMapDefaultFunc(key, mapKey string, def interface{}) (interface{}, error) {
+func (r *Renderer) lookupVarMapDefaultFunc(key, mapKey string, def interface{}) (interface{}, error) {
 	v, err := r.lookupVarMapFunc(key, mapKey)
 	if err != nil {
 		return def, nil
@@ -72,21 +74,21 @@ func (r *renderer) lookupVarMapDefaultFunc(key, mapKey string, def interface{})
 
 // consulDomainFunc will return the Consul DNS Domain.
 // It will default to "consul" unless template variable key "consul_domain" is defined
-func (r *renderer) consulDomainFunc() (interface{}, error) {
+func (r *Renderer) consulDomainFunc() (interface{}, error) {
 	return r.lookupVarDefaultFunc("consul_domain", "consul")
 }
 
 // consulServiceFunc will return a Consul Service hostname
-func (r *renderer) consulServiceFunc(service string) (interface{}, error) {
+func (r *Renderer) consulServiceFunc(service string) (interface{}, error) {
 	return fmt.Sprintf(`%s.service.[[ consulDomain ]]`, service), nil
 }
 
 // consulService will return a Consul Service with provided tag
-func (r *renderer) consulServiceWithTagFunc(service, tag string) (interface{}, error) {
+func (r *Renderer) consulServiceWithTagFunc(service, tag string) (interface{}, error) {
 	return fmt.Sprintf(`%s.%s.service.[[ consulDomain ]]`, tag, service), nil
 }
 
-func (r *renderer) grantCredentialsFunc(db, role string) (interface{}, error) {
+func (r *Renderer) grantCredentialsFunc(db, role string) (interface{}, error) {
 	tmpl := `
 path "%s/creds/%s" {
   capabilities = ["read"]
@@ -95,7 +97,7 @@ path "%s/creds/%s" {
 	return fmt.Sprintf(tmpl, db, role), nil
 }
 
-func (r *renderer) grantCredentialsPolicyFunc(db, role string) (interface{}, error) {
+func (r *Renderer) grantCredentialsPolicyFunc(db, role string) (interface{}, error) {
 	tmpl := `
 policy "%s-%s" {
 	[[ grantCredentials "%s" "%s" ]]
@@ -104,7 +106,7 @@ policy "%s-%s" {
 	return fmt.Sprintf(tmpl, db, role, db, role), nil
 }
 
-func (r *renderer) githubAssignTeamPolicyFunc(team, policy string) (interface{}, error) {
+func (r *Renderer) githubAssignTeamPolicyFunc(team, policy string) (interface{}, error) {
 	tmpl := `
 secret "/auth/github/map/teams/%s" {
   value = "%s"
@@ -113,7 +115,7 @@ secret "/auth/github/map/teams/%s" {
 	return fmt.Sprintf(tmpl, team, policy), nil
 }
 
-func (r *renderer) ldapAssignTeamPolicyFunc(group, policy string) (interface{}, error) {
+func (r *Renderer) ldapAssignTeamPolicyFunc(group, policy string) (interface{}, error) {
 	tmpl := `
 secret "/auth/ldap/groups/%s" {
   value = "%s"
@@ -124,13 +126,13 @@ secret "/auth/ldap/groups/%s" {
 
 // replaceAllFunc replaces all occurrences of a value in a string with the given
 // replacement value.
-func (r *renderer) replaceAllFunc(f, x, s string) (string, error) {
+func (r *Renderer) replaceAllFunc(f, x, s string) (string, error) {
 	return strings.Replace(s, f, x, -1), nil
 }
 
 // regexReplaceAllFunc replaces all occurrences of a regular expression with
 // the given replacement value.
-func (r *renderer) regexReplaceAllFunc(re, pl, s string) (string, error) {
+func (r *Renderer) regexReplaceAllFunc(re, pl, s string) (string, error) {
 	compiled, err := regexp.Compile(re)
 	if err != nil {
 		return "", err
@@ -139,13 +141,13 @@ func (r *renderer) regexReplaceAllFunc(re, pl, s string) (string, error) {
 }
 
 // envFunc return a key from the process environment
-func (r *renderer) envFunc(key string) (string, error) {
+func (r *Renderer) envFunc(key string) (string, error) {
 	return os.Getenv(key), nil
 }
 
 // base64DecodeFunc decodes the given string as a base64 string, returning an error
 // if it fails.
-func (r *renderer) base64DecodeFunc(s string) (string, error) {
+func (r *Renderer) base64DecodeFunc(s string) (string, error) {
 	v, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
 		return "", errors.Wrap(err, "base64Decode")
@@ -154,12 +156,12 @@ func (r *renderer) base64DecodeFunc(s string) (string, error) {
 }
 
 // base64EncodeFunc encodes the given value into a string represented as base64.
-func (r *renderer) base64EncodeFunc(s string) (string, error) {
+func (r *Renderer) base64EncodeFunc(s string) (string, error) {
 	return base64.StdEncoding.EncodeToString([]byte(s)), nil
 }
 
 // base64URLDecodeFunc decodes the given string as a URL-safe base64 string.
-func (r *renderer) base64URLDecodeFunc(s string) (string, error) {
+func (r *Renderer) base64URLDecodeFunc(s string) (string, error) {
 	v, err := base64.URLEncoding.DecodeString(s)
 	if err != nil {
 		return "", errors.Wrap(err, "base64URLDecode")
@@ -168,7 +170,7 @@ func (r *renderer) base64URLDecodeFunc(s string) (string, error) {
 }
 
 // base64URLEncodeFunc encodes the given string to be URL-safe.
-func (r *renderer) base64URLEncodeFunc(s string) (string, error) {
+func (r *Renderer) base64URLEncodeFunc(s string) (string, error) {
 	return base64.URLEncoding.EncodeToString([]byte(s)), nil
 }
 
@@ -177,7 +179,7 @@ func (r *renderer) base64URLEncodeFunc(s string) (string, error) {
 //
 // 		{{ l | containsFunc "thing" }}
 //
-func (r *renderer) containsFunc(v, l interface{}) (bool, error) {
+func (r *Renderer) containsFunc(v, l interface{}) (bool, error) {
 	return r.in(l, v)
 }
 
@@ -190,7 +192,7 @@ func (r *renderer) containsFunc(v, l interface{}) (bool, error) {
 //
 // ret_true - return true at end of loop for none/all; false for any/notall
 // invert   - invert block test for all/notall
-func (r *renderer) containsSomeFunc(retTrue, invert bool) func([]interface{}, interface{}) (bool, error) {
+func (r *Renderer) containsSomeFunc(retTrue, invert bool) func([]interface{}, interface{}) (bool, error) {
 	return func(v []interface{}, l interface{}) (bool, error) {
 		for i := 0; i < len(v); i++ {
 			if ok, _ := r.in(l, v[i]); ok != invert {
@@ -202,7 +204,7 @@ func (r *renderer) containsSomeFunc(retTrue, invert bool) func([]interface{}, in
 }
 
 // in searches for a given value in a given interface.
-func (r *renderer) in(l, v interface{}) (bool, error) {
+func (r *Renderer) in(l, v interface{}) (bool, error) {
 	lv := reflect.ValueOf(l)
 	vv := reflect.ValueOf(v)
 
@@ -254,17 +256,17 @@ func (r *renderer) in(l, v interface{}) (bool, error) {
 }
 
 // joinFunc is a version of strings.Join that can be piped
-func (r *renderer) joinFunc(sep string, a []string) (string, error) {
+func (r *Renderer) joinFunc(sep string, a []string) (string, error) {
 	return strings.Join(a, sep), nil
 }
 
 // TrimSpace is a version of strings.TrimSpace that can be piped
-func (r *renderer) trimSpaceFunc(s string) (string, error) {
+func (r *Renderer) trimSpaceFunc(s string) (string, error) {
 	return strings.TrimSpace(s), nil
 }
 
 // parseBoolFunc parses a string into a boolean
-func (r *renderer) parseBoolFunc(s string) (bool, error) {
+func (r *Renderer) parseBoolFunc(s string) (bool, error) {
 	if s == "" {
 		return false, nil
 	}
@@ -277,7 +279,7 @@ func (r *renderer) parseBoolFunc(s string) (bool, error) {
 }
 
 // parseFloatFunc parses a string into a base 10 float
-func (r *renderer) parseFloatFunc(s string) (float64, error) {
+func (r *Renderer) parseFloatFunc(s string) (float64, error) {
 	if s == "" {
 		return 0.0, nil
 	}
@@ -290,7 +292,7 @@ func (r *renderer) parseFloatFunc(s string) (float64, error) {
 }
 
 // parseIntFunc parses a string into a base 10 int
-func (r *renderer) parseIntFunc(s string) (int64, error) {
+func (r *Renderer) parseIntFunc(s string) (int64, error) {
 	if s == "" {
 		return 0, nil
 	}
@@ -303,7 +305,7 @@ func (r *renderer) parseIntFunc(s string) (int64, error) {
 }
 
 // parseJSONFunc returns a structure for valid JSON
-func (r *renderer) parseJSONFunc(s string) (interface{}, error) {
+func (r *Renderer) parseJSONFunc(s string) (interface{}, error) {
 	if s == "" {
 		return map[string]interface{}{}, nil
 	}
@@ -316,7 +318,7 @@ func (r *renderer) parseJSONFunc(s string) (interface{}, error) {
 }
 
 // parseUintFunc parses a string into a base 10 int
-func (r *renderer) parseUintFunc(s string) (uint64, error) {
+func (r *Renderer) parseUintFunc(s string) (uint64, error) {
 	if s == "" {
 		return 0, nil
 	}
@@ -331,7 +333,7 @@ func (r *renderer) parseUintFunc(s string) (uint64, error) {
 // pluginFunc executes a subprocess as the given command string. It is assumed the
 // resulting command returns JSON which is then parsed and returned as the
 // value for use in the template.
-func (r *renderer) pluginFunc(name string, args ...string) (string, error) {
+func (r *Renderer) pluginFunc(name string, args ...string) (string, error) {
 	if name == "" {
 		return "", nil
 	}
@@ -381,7 +383,7 @@ func (r *renderer) pluginFunc(name string, args ...string) (string, error) {
 
 // regexMatchFunc returns true or false if the string matches
 // the given regular expression
-func (r *renderer) regexMatchFunc(re, s string) (bool, error) {
+func (r *Renderer) regexMatchFunc(re, s string) (bool, error) {
 	compiled, err := regexp.Compile(re)
 	if err != nil {
 		return false, err
@@ -390,7 +392,7 @@ func (r *renderer) regexMatchFunc(re, s string) (bool, error) {
 }
 
 // splitFunc is a version of strings.Split that can be piped
-func (r *renderer) splitFunc(sep, s string) ([]string, error) {
+func (r *Renderer) splitFunc(sep, s string) ([]string, error) {
 	s = strings.TrimSpace(s)
 	if s == "" {
 		return []string{}, nil
@@ -400,7 +402,7 @@ func (r *renderer) splitFunc(sep, s string) ([]string, error) {
 
 // timestampFunc returns the current UNIX timestampFunc in UTC. If an argument is
 // specified, it will be used to format the timestampFunc.
-func (r *renderer) timestampFunc(s ...string) (string, error) {
+func (r *Renderer) timestampFunc(s ...string) (string, error) {
 	switch len(s) {
 	case 0:
 		return now().Format(time.RFC3339), nil
@@ -416,12 +418,12 @@ func (r *renderer) timestampFunc(s ...string) (string, error) {
 }
 
 // toLowerFunc converts the given string (usually by a pipe) to lowercase.
-func (r *renderer) toLowerFunc(s string) (string, error) {
+func (r *Renderer) toLowerFunc(s string) (string, error) {
 	return strings.ToLower(s), nil
 }
 
 // toJSONFunc converts the given structure into a deeply nested JSON string.
-func (r *renderer) toJSONFunc(i interface{}) (string, error) {
+func (r *Renderer) toJSONFunc(i interface{}) (string, error) {
 	result, err := json.Marshal(i)
 	if err != nil {
 		return "", errors.Wrap(err, "toJSON")
@@ -431,7 +433,7 @@ func (r *renderer) toJSONFunc(i interface{}) (string, error) {
 
 // toJSONPrettyFunc converts the given structure into a deeply nested pretty JSON
 // string.
-func (r *renderer) toJSONPrettyFunc(m map[string]interface{}) (string, error) {
+func (r *Renderer) toJSONPrettyFunc(m map[string]interface{}) (string, error) {
 	result, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
 		return "", errors.Wrap(err, "toJSONPretty")
@@ -440,20 +442,170 @@ func (r *renderer) toJSONPrettyFunc(m map[string]interface{}) (string, error) {
 }
 
 // toTitleFunc converts the given string (usually by a pipe) to titlecase.
-func (r *renderer) toTitleFunc(s string) (string, error) {
+func (r *Renderer) toTitleFunc(s string) (string, error) {
 	return strings.Title(s), nil
 }
 
 // toUpperFunc converts the given string (usually by a pipe) to uppercase.
-func (r *renderer) toUpperFunc(s string) (string, error) {
+func (r *Renderer) toUpperFunc(s string) (string, error) {
 	return strings.ToUpper(s), nil
 }
 
 // toYAMLFunc converts the given structure into a deeply nested YAML string.
-func (r *renderer) toYAMLFunc(m map[string]interface{}) (string, error) {
+func (r *Renderer) toYAMLFunc(m map[string]interface{}) (string, error) {
 	result, err := yaml.Marshal(m)
 	if err != nil {
 		return "", errors.Wrap(err, "toYAML")
 	}
 	return string(bytes.TrimSpace(result)), nil
 }
+
+// keyFunc returns the value of a Consul KV key, the key must exist
+func (r *Renderer) keyFunc(key string) (string, error) {
+	value, ok, err := r.lookupKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "", fmt.Errorf("Consul KV key '%s' does not exist", key)
+	}
+
+	return value, nil
+}
+
+// keyOrDefaultFunc returns the value of a Consul KV key, or def if the key does not exist
+func (r *Renderer) keyOrDefaultFunc(key, def string) (string, error) {
+	value, ok, err := r.lookupKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return def, nil
+	}
+
+	return value, nil
+}
+
+func (r *Renderer) lookupKey(key string) (string, bool, error) {
+	key = strings.TrimPrefix(key, "/")
+
+	pairs, err := r.lookups.kvList(key)
+	if err != nil {
+		return "", false, errors.Wrap(err, "key")
+	}
+
+	value, ok := pairs[key]
+	return value, ok, nil
+}
+
+// lsFunc returns the Consul KV keys directly under prefix, like consul-template ls
+func (r *Renderer) lsFunc(prefix string) ([]*kvPair, error) {
+	return r.listKeys("ls", prefix, false)
+}
+
+// treeFunc returns all Consul KV keys under prefix, like consul-template tree
+func (r *Renderer) treeFunc(prefix string) ([]*kvPair, error) {
+	return r.listKeys("tree", prefix, true)
+}
+
+func (r *Renderer) listKeys(fn, prefix string, recursive bool) ([]*kvPair, error) {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	pairs, err := r.lookups.kvList(prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, fn)
+	}
+
+	result := make([]*kvPair, 0, len(pairs))
+	for _, path := range sortedKeys(pairs) {
+		key := strings.TrimPrefix(path, prefix)
+
+		// skip the prefix itself and folders
+		if key == "" || strings.HasSuffix(key, "/") {
+			continue
+		}
+
+		if !recursive && strings.Contains(key, "/") {
+			continue
+		}
+
+		result = append(result, &kvPair{Path: path, Key: key, Value: pairs[path]})
+	}
+
+	return result, nil
+}
+
+// secretFunc reads a Vault secret, the secret must exist
+func (r *Renderer) secretFunc(path string) (*vault.Secret, error) {
+	secret, err := r.lookups.secret(strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, "secret")
+	}
+
+	if secret == nil {
+		return nil, fmt.Errorf("Vault secret '%s' does not exist", path)
+	}
+
+	return secret, nil
+}
+
+// servicesFunc returns all Consul services sorted by name, like consul-template services
+func (r *Renderer) servicesFunc() ([]*catalogSnippet, error) {
+	services, err := r.lookups.services()
+	if err != nil {
+		return nil, errors.Wrap(err, "services")
+	}
+
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]*catalogSnippet, 0, len(names))
+	for _, name := range names {
+		result = append(result, &catalogSnippet{Name: name, Tags: services[name]})
+	}
+
+	return result, nil
+}
+
+// catalogServiceFunc returns the instances of a Consul service, query is "name" or "tag.name"
+// like consul-template catalogService
+func (r *Renderer) catalogServiceFunc(query string) ([]*catalogService, error) {
+	name, tag := query, ""
+	if i := strings.LastIndex(query, "."); i >= 0 {
+		tag, name = query[:i], query[i+1:]
+	}
+
+	instances, err := r.lookups.catalogService(name, tag)
+	if err != nil {
+		return nil, errors.Wrap(err, "catalogService")
+	}
+
+	return instances, nil
+}
+
+// dictFunc creates a map from key/value pairs, used to pass arguments to template partials
+func (r *Renderer) dictFunc(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict requires an even number of arguments, got %d", len(pairs))
+	}
+
+	result := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, got %T", pairs[i])
+		}
+
+		result[key] = pairs[i+1]
+	}
+
+	return result, nil
+}
@@ -0,0 +1,319 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	consul "github.com/hashicorp/consul/api"
+	vault "github.com/hashicorp/vault/api"
+)
+
+// kvPair is a Consul KV entry returned by the ls and tree template funcs, Key is relative to the prefix
+type kvPair struct {
+	Path  string
+	Key   string
+	Value string
+}
+
+// catalogSnippet is a Consul service returned by the services template func
+type catalogSnippet struct {
+	Name string
+	Tags []string
+}
+
+// catalogService is a Consul service instance returned by the catalogService template func
+type catalogService struct {
+	ID      string   `json:"id"`
+	Node    string   `json:"node"`
+	Address string   `json:"address"`
+	Name    string   `json:"name"`
+	Port    int      `json:"port"`
+	Tags    []string `json:"tags"`
+}
+
+// lookups is what live lookup template funcs read from, either the real Consul and Vault, or a fixtures file
+type lookups interface {
+	// kvList returns all keys and values under prefix
+	kvList(prefix string) (map[string]string, error)
+
+	// secret reads a Vault secret, nil if it doesn't exist
+	secret(path string) (*vault.Secret, error)
+
+	// services returns all Consul services, with their tags
+	services() (map[string][]string, error)
+
+	// catalogService returns the instances of a Consul service, optionally filtered by tag
+	catalogService(name, tag string) ([]*catalogService, error)
+}
+
+// templateFixtures is the content of a --template-fixtures file
+type templateFixtures struct {
+	ConsulKV       map[string]string                 `json:"consul_kv"`
+	ConsulServices map[string][]*catalogService      `json:"consul_services"`
+	VaultSecrets   map[string]map[string]interface{} `json:"vault_secrets"`
+}
+
+// readTemplateFixtures reads lookups from a JSON file, so templates can be rendered without Consul and Vault
+func readTemplateFixtures(file string) (*templateFixtures, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := &templateFixtures{}
+	if err := json.Unmarshal(content, fixtures); err != nil {
+		return nil, fmt.Errorf("Could not parse template fixtures %s: %s", file, err)
+	}
+
+	return fixtures, nil
+}
+
+func (f *templateFixtures) kvList(prefix string) (map[string]string, error) {
+	result := make(map[string]string)
+	for key, value := range f.ConsulKV {
+		if strings.HasPrefix(key, prefix) {
+			result[key] = value
+		}
+	}
+
+	return result, nil
+}
+
+func (f *templateFixtures) secret(path string) (*vault.Secret, error) {
+	data, ok := f.VaultSecrets[path]
+	if !ok {
+		return nil, nil
+	}
+
+	return &vault.Secret{Data: data}, nil
+}
+
+func (f *templateFixtures) services() (map[string][]string, error) {
+	result := make(map[string][]string)
+	for name, instances := range f.ConsulServices {
+		tags := make([]string, 0)
+		for _, instance := range instances {
+			for _, tag := range instance.Tags {
+				if !containsString(tags, tag) {
+					tags = append(tags, tag)
+				}
+			}
+		}
+
+		result[name] = tags
+	}
+
+	return result, nil
+}
+
+func (f *templateFixtures) catalogService(name, tag string) ([]*catalogService, error) {
+	result := make([]*catalogService, 0)
+	for _, instance := range f.ConsulServices[name] {
+		if tag != "" && !containsString(instance.Tags, tag) {
+			continue
+		}
+
+		found := *instance
+		found.Name = name
+		result = append(result, &found)
+	}
+
+	return result, nil
+}
+
+// liveLookups reads from the Consul and Vault configured by the environment, e.g. CONSUL_HTTP_ADDR and VAULT_ADDR
+type liveLookups struct {
+	sync.Mutex
+
+	consul *consul.Client
+	vault  *vault.Client
+}
+
+func (l *liveLookups) consulClient() (*consul.Client, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.consul == nil {
+		client, err := consul.NewClient(consul.DefaultConfig())
+		if err != nil {
+			return nil, err
+		}
+		l.consul = client
+	}
+
+	return l.consul, nil
+}
+
+func (l *liveLookups) vaultClient() (*vault.Client, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.vault == nil {
+		client, err := vault.NewClient(nil)
+		if err != nil {
+			return nil, err
+		}
+		l.vault = client
+	}
+
+	return l.vault, nil
+}
+
+func (l *liveLookups) kvList(prefix string) (map[string]string, error) {
+	client, err := l.consulClient()
+	if err != nil {
+		return nil, err
+	}
+
+	pairs, _, err := client.KV().List(prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		result[pair.Key] = string(pair.Value)
+	}
+
+	return result, nil
+}
+
+func (l *liveLookups) secret(path string) (*vault.Secret, error) {
+	client, err := l.vaultClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Logical().Read(path)
+}
+
+func (l *liveLookups) services() (map[string][]string, error) {
+	client, err := l.consulClient()
+	if err != nil {
+		return nil, err
+	}
+
+	services, _, err := client.Catalog().Services(nil)
+	return services, err
+}
+
+func (l *liveLookups) catalogService(name, tag string) ([]*catalogService, error) {
+	client, err := l.consulClient()
+	if err != nil {
+		return nil, err
+	}
+
+	instances, _, err := client.Catalog().Service(name, tag, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*catalogService, 0, len(instances))
+	for _, instance := range instances {
+		address := instance.ServiceAddress
+		if address == "" {
+			address = instance.Address
+		}
+
+		result = append(result, &catalogService{
+			ID:      instance.ServiceID,
+			Node:    instance.Node,
+			Address: address,
+			Name:    instance.ServiceName,
+			Port:    instance.ServicePort,
+			Tags:    instance.ServiceTags,
+		})
+	}
+
+	return result, nil
+}
+
+// cachedLookups makes sure every lookup is only done once per run, and all templates see the same data
+type cachedLookups struct {
+	sync.Mutex
+
+	lookups lookups
+	cache   map[string]interface{}
+}
+
+func newCachedLookups(l lookups) *cachedLookups {
+	return &cachedLookups{lookups: l, cache: make(map[string]interface{})}
+}
+
+func (c *cachedLookups) get(key string, fn func() (interface{}, error)) (interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if v, ok := c.cache[key]; ok {
+		return v, nil
+	}
+
+	v, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache[key] = v
+	return v, nil
+}
+
+func (c *cachedLookups) kvList(prefix string) (map[string]string, error) {
+	v, err := c.get("kv:"+prefix, func() (interface{}, error) { return c.lookups.kvList(prefix) })
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(map[string]string), nil
+}
+
+func (c *cachedLookups) secret(path string) (*vault.Secret, error) {
+	v, err := c.get("secret:"+path, func() (interface{}, error) { return c.lookups.secret(path) })
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*vault.Secret), nil
+}
+
+func (c *cachedLookups) services() (map[string][]string, error) {
+	v, err := c.get("services", func() (interface{}, error) { return c.lookups.services() })
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(map[string][]string), nil
+}
+
+func (c *cachedLookups) catalogService(name, tag string) ([]*catalogService, error) {
+	v, err := c.get("service:"+tag+"."+name, func() (interface{}, error) { return c.lookups.catalogService(name, tag) })
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]*catalogService), nil
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
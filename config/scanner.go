@@ -9,16 +9,17 @@ import (
 	"strings"
 
 	multierror "github.com/hashicorp/go-multierror"
+	"github.com/seatgeek/hashi-helper/support"
 	log "github.com/sirupsen/logrus"
 )
 
 type scanner struct {
 	config    *Config
-	templater *renderer
+	templater *Renderer
 	path      string
 }
 
-func newConfigScanner(directory string, config *Config, templater *renderer) *scanner {
+func newConfigScanner(directory string, config *Config, templater *Renderer) *scanner {
 	return &scanner{
 		config:    config,
 		templater: templater,
@@ -127,12 +128,35 @@ func (s *scanner) readAndProcess(file string) error {
 		return err
 	}
 
+	// whole-file encrypted content is decrypted before rendering, like it was never encrypted
+	encryptedFile := support.IsEncryptedFile(content)
+	if encryptedFile {
+		content, err = s.config.decrypter.DecryptFile(content)
+		if err != nil {
+			return fmt.Errorf("Could not decrypt %s: %s", file, err)
+		}
+	}
+
 	content, err = s.templater.renderContent(content, file, 0)
 	if err != nil {
 		return err
 	}
 
 	relativeFile := strings.TrimPrefix(strings.TrimPrefix(file, s.path), "/")
+
+	if !encryptedFile && IsEncryptedOnlyFile(file) {
+		if err := checkEncryptedOnly(content, relativeFile); err != nil {
+			if s.config.lint {
+				return err
+			}
+			log.Warn(err)
+		}
+	}
+
+	content, err = s.config.decrypter.DecryptValues(content)
+	if err != nil {
+		return fmt.Errorf("Could not decrypt values in %s: %s", file, err)
+	}
 	if os.Getenv("PRINT_CONTENT") == "1" {
 		log.WithField("file", relativeFile).Debug(content)
 	}
@@ -142,7 +166,8 @@ func (s *scanner) readAndProcess(file string) error {
 		return err
 	}
 
-	return s.config.processContent(list, relativeFile)
+	s.config.parsedFiles = append(s.config.parsedFiles, &parsedFile{list: list, file: file})
+	return nil
 }
 
 // Read File Content
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+)
+
+// Severity of a validation finding
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Source is the location of a stanza in the (rendered) config files
+type Source struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// String returns the source as file:line
+func (s Source) String() string {
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// Finding is a problem found in the config by a validation rule
+type Finding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Source   Source `json:"source"`
+}
+
+// ValidationRule checks the config for one kind of problem, the config has already been parsed successfully
+type ValidationRule struct {
+	Name        string
+	Description string
+	Severity    string
+	check       func(c *Config, report func(source Source, format string, args ...interface{}))
+}
+
+// ValidationRules are all rules known to Validate
+var ValidationRules = []*ValidationRule{
+	{
+		Name:        "auth-policy-exists",
+		Description: "auth role and map policies must exist as policy{} stanza",
+		Severity:    SeverityError,
+		check:       checkAuthPolicies,
+	},
+	{
+		Name:        "database-role-config",
+		Description: "database mount roles must reference a config{} of the mount with db_name",
+		Severity:    SeverityError,
+		check:       checkDatabaseRoles,
+	},
+	{
+		Name:        "lease-ttl",
+		Description: "default TTLs must not be greater than max TTLs",
+		Severity:    SeverityError,
+		check:       checkLeaseTTLs,
+	},
+	{
+		Name:        "policy-path-mount",
+		Description: "policy paths must be under a mount or auth backend in config",
+		Severity:    SeverityWarning,
+		check:       checkPolicyPaths,
+	},
+	{
+		Name:        "duplicate-secret",
+		Description: "secrets must only be defined once, duplicates are ignored",
+		Severity:    SeverityError,
+		check:       checkDuplicateSecrets,
+	},
+}
+
+// FindValidationRule returns the rule with name, or nil if there is no such rule
+func FindValidationRule(name string) *ValidationRule {
+	for _, rule := range ValidationRules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+
+	return nil
+}
+
+// Validate runs rules over the config and returns the findings ordered by file and line
+func (c *Config) Validate(rules []*ValidationRule) []*Finding {
+	findings := make([]*Finding, 0)
+
+	for _, rule := range rules {
+		rule.check(c, func(source Source, format string, args ...interface{}) {
+			findings = append(findings, &Finding{
+				Rule:     rule.Name,
+				Severity: rule.Severity,
+				Message:  fmt.Sprintf(format, args...),
+				Source:   source,
+			})
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Source.File != findings[j].Source.File {
+			return findings[i].Source.File < findings[j].Source.File
+		}
+		return findings[i].Source.Line < findings[j].Source.Line
+	})
+
+	return findings
+}
+
+// builtinPolicies exist in every Vault (namespace)
+var builtinPolicies = []string{"default", "root"}
+
+func checkAuthPolicies(c *Config, report func(Source, string, ...interface{})) {
+	exists := func(auth *Auth, name string) bool {
+		for _, builtin := range builtinPolicies {
+			if name == builtin {
+				return true
+			}
+		}
+
+		for _, policy := range c.VaultPolicies {
+			if policy.Name == name && policy.Namespace == auth.Namespace && policy.Environment.equal(auth.Environment) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, auth := range c.VaultAuths {
+		for _, role := range auth.Roles {
+			for _, key := range []string{"policies", "token_policies"} {
+				for _, name := range policyNames(role.Data[key]) {
+					if !exists(auth, name) {
+						report(role.Source, "auth %s role %s references unknown policy %s", auth.Name, role.Name, name)
+					}
+				}
+			}
+		}
+
+		for _, amap := range auth.Maps {
+			for _, key := range []string{"value", "policies"} {
+				for _, name := range policyNames(amap.Data[key]) {
+					if !exists(auth, name) {
+						report(amap.Source, "auth %s map %s references unknown policy %s", auth.Name, amap.Name, name)
+					}
+				}
+			}
+		}
+	}
+}
+
+// policyNames returns the policy names of a comma separated string or a list
+func policyNames(v interface{}) []string {
+	result := make([]string, 0)
+
+	switch t := v.(type) {
+	case string:
+		for _, name := range strings.Split(t, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				result = append(result, name)
+			}
+		}
+	case []interface{}:
+		for _, item := range t {
+			result = append(result, policyNames(item)...)
+		}
+	}
+
+	return result
+}
+
+func checkDatabaseRoles(c *Config, report func(Source, string, ...interface{})) {
+	for _, mount := range c.VaultMounts {
+		if mount.Type != "database" {
+			continue
+		}
+
+		for _, role := range mount.Roles {
+			dbName, _ := role.Data["db_name"].(string)
+			if dbName == "" {
+				report(role.Source, "mount %s role %s is missing db_name", mount.Name, role.Name)
+				continue
+			}
+
+			found := false
+			for _, config := range mount.Config {
+				if config.Name == dbName {
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				report(role.Source, "mount %s role %s references db_name %s without a matching config{}", mount.Name, role.Name, dbName)
+			}
+		}
+	}
+}
+
+// roleTTLKeys are the pairs of default and max TTL keys in role data
+var roleTTLKeys = [][2]string{
+	{"default_ttl", "max_ttl"},
+	{"ttl", "max_ttl"},
+	{"token_ttl", "token_max_ttl"},
+}
+
+func checkLeaseTTLs(c *Config, report func(Source, string, ...interface{})) {
+	compare := func(source Source, what, defaultKey, maxKey string, defaultTTL, maxTTL interface{}) {
+		if defaultTTL == nil || maxTTL == nil || defaultTTL == "" || maxTTL == "" {
+			return
+		}
+
+		d, err := parseutil.ParseDurationSecond(defaultTTL)
+		if err != nil {
+			report(source, "%s has an invalid %s: %s", what, defaultKey, err)
+			return
+		}
+
+		m, err := parseutil.ParseDurationSecond(maxTTL)
+		if err != nil {
+			report(source, "%s has an invalid %s: %s", what, maxKey, err)
+			return
+		}
+
+		// a max TTL of 0 means the system default
+		if m > 0 && d > m {
+			report(source, "%s %s (%s) is greater than %s (%s)", what, defaultKey, d, maxKey, m)
+		}
+	}
+
+	compareRoles := func(what string, source Source, data map[string]interface{}) {
+		for _, keys := range roleTTLKeys {
+			compare(source, what, keys[0], keys[1], data[keys[0]], data[keys[1]])
+		}
+	}
+
+	for _, mount := range c.VaultMounts {
+		compare(mount.Source, "mount "+mount.Name, "default_lease_ttl", "max_lease_ttl", mount.DefaultLeaseTTL, mount.MaxLeaseTTL)
+
+		for _, role := range mount.Roles {
+			compareRoles(fmt.Sprintf("mount %s role %s", mount.Name, role.Name), role.Source, role.Data)
+		}
+	}
+
+	for _, auth := range c.VaultAuths {
+		compare(auth.Source, "auth "+auth.Name, "default_lease_ttl", "max_lease_ttl", auth.DefaultLeaseTTL, auth.MaxLeaseTTL)
+
+		for _, role := range auth.Roles {
+			compareRoles(fmt.Sprintf("auth %s role %s", auth.Name, role.Name), role.Source, role.Data)
+		}
+	}
+}
+
+// builtinMounts exist in every Vault (namespace) and can't be configured
+var builtinMounts = []string{"sys", "identity", "cubbyhole", "auth/token"}
+
+func checkPolicyPaths(c *Config, report func(Source, string, ...interface{})) {
+	for _, policy := range c.VaultPolicies {
+		mounts := append([]string{}, builtinMounts...)
+
+		for _, mount := range c.VaultMounts {
+			if mount.Namespace == policy.Namespace && mount.Environment.equal(policy.Environment) {
+				mounts = append(mounts, strings.Trim(mount.Name, "/"))
+			}
+		}
+
+		for _, auth := range c.VaultAuths {
+			if auth.Namespace == policy.Namespace && auth.Environment.equal(policy.Environment) {
+				mounts = append(mounts, "auth/"+strings.Trim(auth.Name, "/"))
+			}
+		}
+
+		// mounts used as target for secrets are managed implicitly
+		for _, secret := range c.VaultSecrets {
+			if secret.Namespace == policy.Namespace && secret.Environment.equal(policy.Environment) {
+				mounts = append(mounts, secretMount(secret))
+			}
+		}
+
+		for _, path := range policy.Paths {
+			if !pathUnderMount(path.Path(), mounts) {
+				report(path.Source, "policy %s grants path %s, which is not under any mount or auth backend in config", policy.Name, path.Path())
+			}
+		}
+	}
+}
+
+// secretMount returns the first path segment of the path a secret is written to
+func secretMount(secret *Secret) string {
+	if strings.HasPrefix(secret.Path, "/") {
+		return strings.SplitN(strings.TrimLeft(secret.Path, "/"), "/", 2)[0]
+	}
+
+	return "secret"
+}
+
+// pathUnderMount returns true if a policy path (which may contain globs and + segments) can match a path under a mount
+func pathUnderMount(path string, mounts []string) bool {
+	path = strings.TrimLeft(path, "/")
+
+	// only the literal part of the path can be compared
+	literal := path
+	if i := strings.IndexAny(path, "*+"); i >= 0 {
+		literal = path[:i]
+	}
+
+	for _, mount := range mounts {
+		if path == mount || strings.HasPrefix(literal, mount+"/") {
+			return true
+		}
+
+		// the glob or + segment may match the rest of the mount name
+		if literal != path && strings.HasPrefix(mount+"/", literal) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkDuplicateSecrets(c *Config, report func(Source, string, ...interface{})) {
+	for _, duplicate := range c.duplicateSecrets {
+		first := Source{}
+		for _, secret := range c.VaultSecrets {
+			if secret.Equal(duplicate) && secret.Environment.equal(duplicate.Environment) {
+				first = secret.Source
+				break
+			}
+		}
+
+		report(duplicate.Source, "secret %s in environment %s is already defined in %s, and is ignored", duplicate.Key, duplicate.Environment.Name, first)
+	}
+}
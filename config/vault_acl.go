@@ -0,0 +1,266 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ACL evaluates a set of policies the way Vault does, so policies can be checked without a Vault server
+type ACL struct {
+	root  bool
+	exact map[string]*ACLRule
+	rules []*ACLRule
+}
+
+// ACLRule is a path of the ACL, merged from all policies granting the same path
+type ACLRule struct {
+	Path               string
+	CapabilitiesBitmap uint32
+	Grants             []*ACLGrant
+
+	prefix      string
+	glob        bool
+	segments    []string
+	firstWildIx int
+	wildcards   int
+}
+
+// ACLGrant is a single path stanza contributing to an ACLRule
+type ACLGrant struct {
+	Policy       string
+	Capabilities []string
+	Source       Source
+}
+
+// ACLResult is the outcome of checking a capability on a path
+type ACLResult struct {
+	Path       string
+	Capability string
+	Allowed    bool
+
+	// Root is true if access was granted by the root policy
+	Root bool
+
+	// Rule is the rule Vault uses for the path, nil if no rule matched
+	Rule *ACLRule
+
+	// Shadowed are other matching rules, which have a lower priority than Rule
+	Shadowed []*ACLRule
+}
+
+// NewACL merges the paths of the policies, like Vault does for a token with multiple policies
+func NewACL(policies ...*Policy) *ACL {
+	acl := &ACL{exact: make(map[string]*ACLRule)}
+	merged := make(map[string]*ACLRule)
+
+	for _, policy := range policies {
+		if policy.Name == "root" {
+			acl.root = true
+			continue
+		}
+
+		for _, pc := range policy.Paths {
+			path := pc.Path()
+
+			rule, ok := merged[path]
+			if !ok {
+				rule = newACLRule(pc.Prefix, pc.Glob)
+				merged[path] = rule
+				acl.rules = append(acl.rules, rule)
+			}
+
+			bitmap := uint32(0)
+			if pc.Permissions != nil {
+				bitmap = pc.Permissions.CapabilitiesBitmap
+			}
+
+			// deny on any policy takes precedence over all other capabilities of the path
+			if bitmap&DenyCapabilityInt > 0 || rule.CapabilitiesBitmap&DenyCapabilityInt > 0 {
+				rule.CapabilitiesBitmap = DenyCapabilityInt
+			} else {
+				rule.CapabilitiesBitmap |= bitmap
+			}
+
+			rule.Grants = append(rule.Grants, &ACLGrant{
+				Policy:       policy.Name,
+				Capabilities: pc.Capabilities,
+				Source:       pc.Source,
+			})
+		}
+	}
+
+	for path, rule := range merged {
+		if !rule.glob && rule.wildcards == 0 {
+			acl.exact[path] = rule
+		}
+	}
+
+	return acl
+}
+
+func newACLRule(prefix string, glob bool) *ACLRule {
+	rule := &ACLRule{
+		prefix:      prefix,
+		glob:        glob,
+		firstWildIx: len(prefix),
+	}
+	rule.Path = prefix
+	if glob {
+		rule.Path += "*"
+	}
+
+	if i := strings.Index(prefix, "+"); i >= 0 {
+		rule.firstWildIx = i
+		rule.segments = strings.Split(prefix, "/")
+		for _, segment := range rule.segments {
+			if segment == "+" {
+				rule.wildcards++
+			}
+		}
+	}
+
+	return rule
+}
+
+// Capabilities returns the capabilities of the rule, in the order Vault lists them
+func (r *ACLRule) Capabilities() []string {
+	result := make([]string, 0)
+	for _, capability := range []string{DenyCapability, CreateCapability, ReadCapability, UpdateCapability, DeleteCapability, ListCapability, SudoCapability} {
+		if r.CapabilitiesBitmap&cap2Int[capability] > 0 {
+			result = append(result, capability)
+		}
+	}
+
+	return result
+}
+
+// matches returns true if the non-exact rule matches path
+func (r *ACLRule) matches(path string) bool {
+	if r.wildcards == 0 {
+		return r.glob && strings.HasPrefix(path, r.prefix)
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) < len(r.segments) || (!r.glob && len(parts) != len(r.segments)) {
+		return false
+	}
+
+	for i, segment := range r.segments {
+		switch {
+		case segment == "+":
+			continue
+		case r.glob && i == len(r.segments)-1:
+			if !strings.HasPrefix(parts[i], segment) {
+				return false
+			}
+		case segment != parts[i]:
+			return false
+		}
+	}
+
+	return true
+}
+
+// higherPriority returns true if rule r wins over rule o when both match a path
+func (r *ACLRule) higherPriority(o *ACLRule) bool {
+	// the later the first wildcard or glob, the more specific the rule
+	if r.firstWildIx != o.firstWildIx {
+		return r.firstWildIx > o.firstWildIx
+	}
+
+	if r.glob != o.glob {
+		return !r.glob
+	}
+
+	if r.wildcards != o.wildcards {
+		return r.wildcards < o.wildcards
+	}
+
+	if len(r.Path) != len(o.Path) {
+		return len(r.Path) > len(o.Path)
+	}
+
+	return r.Path > o.Path
+}
+
+// Check returns if capability is granted on path, and which rule decided it
+func (acl *ACL) Check(path, capability string) (*ACLResult, error) {
+	path = strings.TrimPrefix(path, "/")
+
+	bit, ok := cap2Int[capability]
+	if !ok || capability == DenyCapability {
+		return nil, fmt.Errorf("Invalid capability %s", capability)
+	}
+
+	result := &ACLResult{Path: path, Capability: capability}
+	if acl.root {
+		result.Allowed = true
+		result.Root = true
+		return result, nil
+	}
+
+	// exact rules always win, list operations ignore a trailing slash
+	result.Rule = acl.exact[path]
+	if result.Rule == nil && capability == ListCapability {
+		result.Rule = acl.exact[strings.TrimSuffix(path, "/")]
+	}
+
+	candidates := make([]*ACLRule, 0)
+	for _, rule := range acl.rules {
+		if rule != result.Rule && (rule.glob || rule.wildcards > 0) && rule.matches(path) {
+			candidates = append(candidates, rule)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].higherPriority(candidates[j])
+	})
+
+	if result.Rule == nil && len(candidates) > 0 {
+		result.Rule = candidates[0]
+		candidates = candidates[1:]
+	}
+	result.Shadowed = candidates
+
+	if result.Rule != nil && result.Rule.CapabilitiesBitmap&DenyCapabilityInt == 0 {
+		result.Allowed = result.Rule.CapabilitiesBitmap&bit > 0
+	}
+
+	return result, nil
+}
+
+// Explain describes the result and the rule that decided it
+func (r *ACLResult) Explain() string {
+	verdict := "DENIED"
+	if r.Allowed {
+		verdict = "ALLOWED"
+	}
+
+	lines := []string{fmt.Sprintf("%s %s on %s", verdict, r.Capability, r.Path)}
+
+	switch {
+	case r.Root:
+		lines = append(lines, "  granted by the root policy")
+	case r.Rule == nil:
+		lines = append(lines, "  no path matches, access is implicitly denied")
+	default:
+		reason := fmt.Sprintf("does not include %s", r.Capability)
+		if r.Allowed {
+			reason = fmt.Sprintf("includes %s", r.Capability)
+		} else if r.Rule.CapabilitiesBitmap&DenyCapabilityInt > 0 {
+			reason = "is denied"
+		}
+
+		lines = append(lines, fmt.Sprintf("  matched path %q, capabilities [%s] %s", r.Rule.Path, strings.Join(r.Rule.Capabilities(), ", "), reason))
+		for _, grant := range r.Rule.Grants {
+			lines = append(lines, fmt.Sprintf("    policy %s grants [%s] at %s", grant.Policy, strings.Join(grant.Capabilities, ", "), grant.Source))
+		}
+	}
+
+	for _, rule := range r.Shadowed {
+		lines = append(lines, fmt.Sprintf("  ignored lower priority path %q, capabilities [%s]", rule.Path, strings.Join(rule.Capabilities(), ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -9,18 +9,25 @@ import (
 
 // Secret ...
 type Audit struct {
-	Description string `hcl:"description"`
-	Environment *Environment
-	Key         string
-	Local       bool                   `hcl:"local"`
-	Options     map[string]interface{} `hcl:"options"`
-	Path        string                 `hcl:"path"`
-	Type        string                 `hcl:"type"`
+	Description    string `hcl:"description"`
+	Environment    *Environment
+	Key            string
+	Namespace      string                 `hcl:"-"`
+	Local          bool                   `hcl:"local"`
+	Options        map[string]interface{} `hcl:"options"`
+	Path           string                 `hcl:"path"`
+	PreventDestroy bool                   `hcl:"prevent_destroy"`
+	Type           string                 `hcl:"type"`
+}
+
+// IsPlaceholder returns true if the audit device only exist to be protected from pruning
+func (s *Audit) IsPlaceholder() bool {
+	return s.PreventDestroy && s.Type == ""
 }
 
 // Equal ...
 func (s *Audit) Equal(o *Audit) bool {
-	return s.Path == o.Path && s.Key == o.Key
+	return s.Namespace == o.Namespace && s.Path == o.Path && s.Key == o.Key
 }
 
 func (s *Audit) ToMap() map[string]interface{} {
@@ -114,8 +121,13 @@ func (c *Config) parseVaultAuditStanza(list *ast.ObjectList, env *Environment) e
 		audit.Key = auditName
 		audit.Path = auditName
 		audit.Environment = env
+		audit.Namespace = c.vaultNamespace
+
+		if err := c.markOverride(auditData.Val, "audit", audit.Key); err != nil {
+			return err
+		}
 
-		if c.VaultAudits.Add(&audit) == false {
+		if c.VaultAudits.Add(&audit) == false && !c.overridden("audit", audit.Key) {
 			c.logger.Warnf("Ignored duplicate audit '%s' -> '%s' in line %s", audit.Environment.Name, audit.Key, auditData.Keys[0].Token.Pos)
 		}
 	}
@@ -11,23 +11,28 @@ import (
 
 // Auth struct ...
 type Auth struct {
-	Environment     *Environment
-	Name            string
-	Type            string
-	Description     string
-	DefaultLeaseTTL string
-	MaxLeaseTTL     string
-	Config          []*AuthConfig
-	Roles           []*AuthRole
-	Maps            []*AuthMap
+	MountSettings
+
+	Environment    *Environment
+	Namespace      string
+	Name           string
+	Type           string
+	PreventDestroy bool
+	PreviousPaths  []string
+	Config         []*AuthConfig
+	Roles          []*AuthRole
+	Maps           []*AuthMap
+	Source         Source
+}
+
+// IsPlaceholder returns true if the auth backend only exist to be protected from pruning
+func (a *Auth) IsPlaceholder() bool {
+	return a.PreventDestroy && a.Type == ""
 }
 
 // AuthInput ...
-func (m *Mount) AuthInput() *api.MountInput {
-	return &api.MountInput{
-		Type:        m.Type,
-		Description: m.Description,
-	}
+func (a *Auth) AuthInput() *api.EnableAuthOptions {
+	return a.MountSettings.mountInput(a.Type)
 }
 
 // VaultAuths struct
@@ -40,22 +45,36 @@ func (m *VaultAuths) Add(auth *Auth) {
 	*m = append(*m, auth)
 }
 
+// FindInNamespace returns the auth backend with name in the Vault namespace
+func (m VaultAuths) FindInNamespace(namespace, name string) *Auth {
+	for _, auth := range m {
+		if auth.Namespace == namespace && auth.Name == name {
+			return auth
+		}
+	}
+
+	return nil
+}
+
 // AuthConfig ...
 type AuthConfig struct {
-	Name string
-	Data map[string]interface{}
+	Name   string
+	Data   map[string]interface{}
+	Source Source
 }
 
 // AuthRole ...
 type AuthRole struct {
-	Name string
-	Data map[string]interface{}
+	Name   string
+	Data   map[string]interface{}
+	Source Source
 }
 
 // AuthRole ...
 type AuthMap struct {
-	Name string
-	Data map[string]interface{}
+	Name   string
+	Data   map[string]interface{}
+	Source Source
 }
 
 func (c *Config) parseVaultAuthStanza(list *ast.ObjectList, environment *Environment) error {
@@ -68,7 +87,7 @@ func (c *Config) parseVaultAuthStanza(list *ast.ObjectList, environment *Environ
 	for _, authAST := range list.Items {
 		x := authAST.Val.(*ast.ObjectType).List
 
-		valid := []string{"config", "role", "type", "path", "map"}
+		valid := append([]string{"config", "role", "type", "path", "map", "prevent_destroy", "previous_paths", overrideKey}, mountSettingKeys...)
 		if err := c.checkHCLKeys(x, valid); err != nil {
 			return err
 		}
@@ -79,22 +98,68 @@ func (c *Config) parseVaultAuthStanza(list *ast.ObjectList, environment *Environ
 
 		authName := authAST.Keys[0].Token.Value().(string)
 
+		preventDestroy := false
+		preventDestroyAST := x.Filter("prevent_destroy")
+		if len(preventDestroyAST.Items) == 1 {
+			literal, ok := preventDestroyAST.Items[0].Val.(*ast.LiteralType)
+			if !ok {
+				return fmt.Errorf("prevent_destroy must be a boolean in %s -> %s", environment.Name, authName)
+			}
+
+			v, ok := literal.Token.Value().(bool)
+			if !ok {
+				return fmt.Errorf("prevent_destroy must be a boolean in %s -> %s", environment.Name, authName)
+			}
+			preventDestroy = v
+		} else if len(preventDestroyAST.Items) > 1 {
+			return fmt.Errorf("You can only specify prevent_destroy once per auth in %s -> %s", environment.Name, authName)
+		}
+
+		// an auth with only prevent_destroy is a placeholder protecting an auth backend
+		// managed outside of hashi-helper, and does not need a type
+		authType := ""
 		typeAST := x.Filter("type")
-		if len(typeAST.Items) != 1 {
+		if len(typeAST.Items) == 1 {
+			literal, ok := typeAST.Items[0].Val.(*ast.LiteralType)
+			if !ok {
+				return fmt.Errorf("auth type must be a string in %s -> %s", environment.Name, authName)
+			}
+
+			if authType, ok = literal.Token.Value().(string); !ok {
+				return fmt.Errorf("auth type must be a string in %s -> %s", environment.Name, authName)
+			}
+		} else if len(typeAST.Items) > 1 || !preventDestroy {
 			return fmt.Errorf("missing auth type in %s -> %s", environment.Name, authName)
 		}
 
-		authType := typeAST.Items[0].Val.(*ast.LiteralType).Token.Value().(string)
+		settings, err := c.parseMountSettings(authAST, "auth", authName, environment)
+		if err != nil {
+			return err
+		}
+
+		if err := c.markOverride(authAST.Val, "auth", authName); err != nil {
+			return err
+		}
+
+		previousPaths, err := c.parsePreviousPaths(x, "auth", authName, environment)
+		if err != nil {
+			return err
+		}
 
 		auth := &Auth{
-			Name:        authName,
-			Type:        authType,
-			Environment: environment,
+			MountSettings:  settings,
+			PreviousPaths:  previousPaths,
+			Name:           authName,
+			Type:           authType,
+			Environment:    environment,
+			Namespace:      c.vaultNamespace,
+			PreventDestroy: preventDestroy,
+			Source:         c.source(authAST.Keys[0].Token.Pos),
 		}
 
 		configAST := x.Filter("config")
 		if len(configAST.Items) > 0 {
-			config, err := c.parseAuthConfig(configAST)
+			config, err := c.parseAuthConfig(configAST, authName)
 			if err != nil {
 				return err
 			}
@@ -104,7 +169,7 @@ func (c *Config) parseVaultAuthStanza(list *ast.ObjectList, environment *Environ
 
 		roleAST := x.Filter("role")
 		if len(roleAST.Items) > 0 {
-			roles, err := c.parseAuthRole(roleAST)
+			roles, err := c.parseAuthRole(roleAST, authName)
 			if err != nil {
 				return err
 			}
@@ -114,7 +179,7 @@ func (c *Config) parseVaultAuthStanza(list *ast.ObjectList, environment *Environ
 
 		mapAST := x.Filter("map")
 		if len(mapAST.Items) > 0 {
-			maps, err := c.parseAuthMap(mapAST)
+			maps, err := c.parseAuthMap(mapAST, authName)
 			if err != nil {
 				return err
 			}
@@ -122,13 +187,78 @@ func (c *Config) parseVaultAuthStanza(list *ast.ObjectList, environment *Environ
 			auth.Maps = maps
 		}
 
+		// an auth backend inherited from another environment is merged into the overriding one,
+		// its config, roles and maps by name
+		if c.inheritedFrom != "" {
+			if existing := c.VaultAuths.FindInNamespace(auth.Namespace, auth.Name); existing != nil {
+				c.mergeInheritedAuth(existing, auth)
+				continue
+			}
+		}
+
 		c.VaultAuths.Add(auth)
 	}
 
 	return nil
 }
 
-func (c *Config) parseAuthConfig(list *ast.ObjectList) ([]*AuthConfig, error) {
+func (c *Config) mergeInheritedAuth(auth, inherited *Auth) {
+	// the settings that are not set are inherited, the auth backend itself is only overridden
+	// if it declares something differently
+	conflicts := auth.MountSettings.merge(inherited.MountSettings)
+	if auth.Type == "" {
+		auth.Type = inherited.Type
+	} else if inherited.Type != "" && inherited.Type != auth.Type {
+		conflicts = append(conflicts, "type")
+	}
+	auth.PreventDestroy = auth.PreventDestroy || inherited.PreventDestroy
+	auth.PreviousPaths = mergePreviousPaths(auth.PreviousPaths, inherited.PreviousPaths)
+
+	if len(conflicts) > 0 {
+		c.overridden("auth", auth.Name)
+	}
+
+	for _, config := range inherited.Config {
+		if !c.authHas(auth, "config", config.Name) {
+			auth.Config = append(auth.Config, config)
+		}
+	}
+
+	for _, role := range inherited.Roles {
+		if !c.authHas(auth, "role", role.Name) {
+			auth.Roles = append(auth.Roles, role)
+		}
+	}
+
+	for _, amap := range inherited.Maps {
+		if !c.authHas(auth, "map", amap.Name) {
+			auth.Maps = append(auth.Maps, amap)
+		}
+	}
+}
+
+// authHas returns true, and logs the override, if the auth backend has a config, role or map with name
+func (c *Config) authHas(auth *Auth, kind, name string) bool {
+	names := make([]string, 0)
+	switch kind {
+	case "config":
+		for _, config := range auth.Config {
+			names = append(names, config.Name)
+		}
+	case "role":
+		for _, role := range auth.Roles {
+			names = append(names, role.Name)
+		}
+	case "map":
+		for _, amap := range auth.Maps {
+			names = append(names, amap.Name)
+		}
+	}
+
+	return containsString(names, name) && c.overridden("auth "+kind, auth.Name+" -> "+name)
+}
+
+func (c *Config) parseAuthConfig(list *ast.ObjectList, authName string) ([]*AuthConfig, error) {
 	configs := make([]*AuthConfig, 0)
 
 	for _, authConfigAST := range list.Items {
@@ -137,12 +267,17 @@ func (c *Config) parseAuthConfig(list *ast.ObjectList) ([]*AuthConfig, error) {
 		}
 
 		var m map[string]interface{}
-		if err := hcl.DecodeObject(&m, authConfigAST.Val); err != nil {
+		if err := hcl.DecodeObject(&m, withoutOverride(authConfigAST.Val)); err != nil {
 			return nil, err
 		}
 
 		var config AuthConfig
 		config.Name = authConfigAST.Keys[0].Token.Value().(string)
+		config.Source = c.source(authConfigAST.Keys[0].Token.Pos)
+
+		if err := c.markOverride(authConfigAST.Val, "auth config", authName+" -> "+config.Name); err != nil {
+			return nil, err
+		}
 
 		if err := mapstructure.WeakDecode(m, &config.Data); err != nil {
 			return nil, err
@@ -154,7 +289,7 @@ func (c *Config) parseAuthConfig(list *ast.ObjectList) ([]*AuthConfig, error) {
 	return configs, nil
 }
 
-func (c *Config) parseAuthRole(list *ast.ObjectList) ([]*AuthRole, error) {
+func (c *Config) parseAuthRole(list *ast.ObjectList, authName string) ([]*AuthRole, error) {
 	roles := make([]*AuthRole, 0)
 
 	for _, config := range list.Items {
@@ -163,12 +298,17 @@ func (c *Config) parseAuthRole(list *ast.ObjectList) ([]*AuthRole, error) {
 		}
 
 		var m map[string]interface{}
-		if err := hcl.DecodeObject(&m, config.Val); err != nil {
+		if err := hcl.DecodeObject(&m, withoutOverride(config.Val)); err != nil {
 			return nil, err
 		}
 
 		var role AuthRole
 		role.Name = config.Keys[0].Token.Value().(string)
+		role.Source = c.source(config.Keys[0].Token.Pos)
+
+		if err := c.markOverride(config.Val, "auth role", authName+" -> "+role.Name); err != nil {
+			return nil, err
+		}
 
 		if err := mapstructure.WeakDecode(m, &role.Data); err != nil {
 			return nil, err
@@ -180,7 +320,7 @@ func (c *Config) parseAuthRole(list *ast.ObjectList) ([]*AuthRole, error) {
 	return roles, nil
 }
 
-func (c *Config) parseAuthMap(list *ast.ObjectList) ([]*AuthMap, error) {
+func (c *Config) parseAuthMap(list *ast.ObjectList, authName string) ([]*AuthMap, error) {
 	maps := make([]*AuthMap, 0)
 
 	for _, config := range list.Items {
@@ -189,12 +329,17 @@ func (c *Config) parseAuthMap(list *ast.ObjectList) ([]*AuthMap, error) {
 		}
 
 		var m map[string]interface{}
-		if err := hcl.DecodeObject(&m, config.Val); err != nil {
+		if err := hcl.DecodeObject(&m, withoutOverride(config.Val)); err != nil {
 			return nil, err
 		}
 
 		var amap AuthMap
 		amap.Name = config.Keys[0].Token.Value().(string)
+		amap.Source = c.source(config.Keys[0].Token.Pos)
+
+		if err := c.markOverride(config.Val, "auth map", authName+" -> "+amap.Name); err != nil {
+			return nil, err
+		}
 
 		if err := mapstructure.WeakDecode(m, &amap.Data); err != nil {
 			return nil, err
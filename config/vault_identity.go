@@ -0,0 +1,296 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// IdentityEntity is a Vault identity entity, matched by name
+type IdentityEntity struct {
+	Environment *Environment
+	Namespace   string `hcl:"-"`
+	Name        string
+	Policies    []string          `hcl:"policies"`
+	Metadata    map[string]string `hcl:"metadata"`
+	Disabled    bool              `hcl:"disabled"`
+}
+
+// ToMap returns the payload for identity/entity/name/<name>
+func (e *IdentityEntity) ToMap() map[string]interface{} {
+	return map[string]interface{}{
+		"policies": nonNilStrings(e.Policies),
+		"metadata": nonNilMap(e.Metadata),
+		"disabled": e.Disabled,
+	}
+}
+
+// IdentityGroup is a Vault identity group, matched by name
+type IdentityGroup struct {
+	Environment       *Environment
+	Namespace         string `hcl:"-"`
+	Name              string
+	Type              string            `hcl:"type"`
+	Policies          []string          `hcl:"policies"`
+	MemberGroupNames  []string          `hcl:"member_group_names"`
+	MemberEntityNames []string          `hcl:"member_entity_names"`
+	Metadata          map[string]string `hcl:"metadata"`
+}
+
+// IsExternal returns true if group membership is managed by an auth backend through a group alias
+func (g *IdentityGroup) IsExternal() bool {
+	return g.Type == "external"
+}
+
+// IdentityGroupAlias maps a group from an auth backend (e.g. a GitHub team) to an external identity group
+type IdentityGroupAlias struct {
+	Environment *Environment
+	Namespace   string `hcl:"-"`
+	Name        string
+	Auth        string `hcl:"auth"`
+	Group       string `hcl:"group"`
+}
+
+// VaultIdentityEntities ...
+type VaultIdentityEntities []*IdentityEntity
+
+// add returns false if an entity with the same name already exist in the Vault namespace
+func (e *VaultIdentityEntities) add(entity *IdentityEntity) bool {
+	for _, existing := range *e {
+		if existing.Namespace == entity.Namespace && existing.Name == entity.Name {
+			return false
+		}
+	}
+
+	*e = append(*e, entity)
+	return true
+}
+
+// VaultIdentityGroups ...
+type VaultIdentityGroups []*IdentityGroup
+
+// add returns false if a group with the same name already exist in the Vault namespace
+func (g *VaultIdentityGroups) add(group *IdentityGroup) bool {
+	for _, existing := range *g {
+		if existing.Namespace == group.Namespace && existing.Name == group.Name {
+			return false
+		}
+	}
+
+	*g = append(*g, group)
+	return true
+}
+
+// Find returns the group with name, or nil
+func (g VaultIdentityGroups) Find(name string) *IdentityGroup {
+	for _, existing := range g {
+		if existing.Name == name {
+			return existing
+		}
+	}
+
+	return nil
+}
+
+// Sorted returns the groups ordered so member groups come before the groups they are a member of
+func (g VaultIdentityGroups) Sorted() (VaultIdentityGroups, error) {
+	result := make(VaultIdentityGroups, 0, len(g))
+	state := make(map[string]int) // 1 = visiting, 2 = done
+
+	var visit func(group *IdentityGroup) error
+	visit = func(group *IdentityGroup) error {
+		switch state[group.Name] {
+		case 1:
+			return fmt.Errorf("identity_group %s is (indirectly) a member of itself", group.Name)
+		case 2:
+			return nil
+		}
+
+		state[group.Name] = 1
+		for _, name := range group.MemberGroupNames {
+			// groups not in config must already exist in Vault
+			if member := g.Find(name); member != nil {
+				if err := visit(member); err != nil {
+					return err
+				}
+			}
+		}
+		state[group.Name] = 2
+
+		result = append(result, group)
+		return nil
+	}
+
+	for _, group := range g {
+		if err := visit(group); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// VaultIdentityGroupAliases ...
+type VaultIdentityGroupAliases []*IdentityGroupAlias
+
+// add returns false if an alias with the same name and auth backend already exist in the Vault namespace
+func (a *VaultIdentityGroupAliases) add(alias *IdentityGroupAlias) bool {
+	for _, existing := range *a {
+		if existing.Namespace == alias.Namespace && existing.Name == alias.Name && existing.Auth == alias.Auth {
+			return false
+		}
+	}
+
+	*a = append(*a, alias)
+	return true
+}
+
+// parseVaultIdentityEntityStanza
+// parse out `environment -> identity_entity {}`
+func (c *Config) parseVaultIdentityEntityStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d identity_entity{}", len(list.Items))
+	for _, entityAST := range list.Items {
+		if len(entityAST.Keys) != 1 {
+			return fmt.Errorf("Missing identity_entity name in line %+v", entityAST.Pos())
+		}
+
+		valid := []string{"policies", "metadata", "disabled", overrideKey}
+		if err := c.checkHCLKeys(entityAST.Val, valid); err != nil {
+			return err
+		}
+
+		var entity IdentityEntity
+		if err := hcl.DecodeObject(&entity, entityAST.Val); err != nil {
+			return err
+		}
+
+		entity.Name = entityAST.Keys[0].Token.Value().(string)
+		entity.Environment = env
+		entity.Namespace = c.vaultNamespace
+
+		if err := c.markOverride(entityAST.Val, "identity_entity", entity.Name); err != nil {
+			return err
+		}
+
+		if !c.VaultIdentityEntities.add(&entity) && !c.overridden("identity_entity", entity.Name) {
+			c.logger.Warnf("Ignored duplicate identity_entity '%s' -> '%s' in line %s", env.Name, entity.Name, entityAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+// parseVaultIdentityGroupStanza
+// parse out `environment -> identity_group {}`
+func (c *Config) parseVaultIdentityGroupStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d identity_group{}", len(list.Items))
+	for _, groupAST := range list.Items {
+		if len(groupAST.Keys) != 1 {
+			return fmt.Errorf("Missing identity_group name in line %+v", groupAST.Pos())
+		}
+
+		valid := []string{"type", "policies", "member_group_names", "member_entity_names", "metadata", overrideKey}
+		if err := c.checkHCLKeys(groupAST.Val, valid); err != nil {
+			return err
+		}
+
+		var group IdentityGroup
+		if err := hcl.DecodeObject(&group, groupAST.Val); err != nil {
+			return err
+		}
+
+		group.Name = groupAST.Keys[0].Token.Value().(string)
+		group.Environment = env
+		group.Namespace = c.vaultNamespace
+
+		switch group.Type {
+		case "":
+			group.Type = "internal"
+		case "internal", "external":
+		default:
+			return fmt.Errorf("Invalid type '%s' in identity_group %s -> %s, must be internal or external", group.Type, env.Name, group.Name)
+		}
+
+		if group.IsExternal() && (len(group.MemberGroupNames) > 0 || len(group.MemberEntityNames) > 0) {
+			return fmt.Errorf("identity_group %s -> %s is external, members are managed by its identity_group_alias", env.Name, group.Name)
+		}
+
+		if err := c.markOverride(groupAST.Val, "identity_group", group.Name); err != nil {
+			return err
+		}
+
+		if !c.VaultIdentityGroups.add(&group) && !c.overridden("identity_group", group.Name) {
+			c.logger.Warnf("Ignored duplicate identity_group '%s' -> '%s' in line %s", env.Name, group.Name, groupAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+// parseVaultIdentityGroupAliasStanza
+// parse out `environment -> identity_group_alias {}`
+func (c *Config) parseVaultIdentityGroupAliasStanza(list *ast.ObjectList, env *Environment) error {
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	c.logger.Debugf("Found %d identity_group_alias{}", len(list.Items))
+	for _, aliasAST := range list.Items {
+		if len(aliasAST.Keys) != 1 {
+			return fmt.Errorf("Missing identity_group_alias name in line %+v", aliasAST.Pos())
+		}
+
+		valid := []string{"auth", "group", overrideKey}
+		if err := c.checkHCLKeys(aliasAST.Val, valid); err != nil {
+			return err
+		}
+
+		var alias IdentityGroupAlias
+		if err := hcl.DecodeObject(&alias, aliasAST.Val); err != nil {
+			return err
+		}
+
+		alias.Name = aliasAST.Keys[0].Token.Value().(string)
+		alias.Environment = env
+		alias.Namespace = c.vaultNamespace
+
+		if alias.Auth == "" || alias.Group == "" {
+			return fmt.Errorf("identity_group_alias %s -> %s requires both auth and group", env.Name, alias.Name)
+		}
+
+		if err := c.markOverride(aliasAST.Val, "identity_group_alias", alias.Name); err != nil {
+			return err
+		}
+
+		if !c.VaultIdentityGroupAliases.add(&alias) && !c.overridden("identity_group_alias", alias.Name) {
+			c.logger.Warnf("Ignored duplicate identity_group_alias '%s' -> '%s' in line %s", env.Name, alias.Name, aliasAST.Keys[0].Token.Pos)
+		}
+	}
+
+	return nil
+}
+
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+
+	return list
+}
+
+func nonNilMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+
+	return m
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/hcl/hcl/ast"
@@ -11,30 +14,298 @@ import (
 
 // Mount struct ...
 type Mount struct {
-	Environment     *Environment
-	Name            string
-	Type            string
-	Description     string
-	DefaultLeaseTTL string
-	MaxLeaseTTL     string
-	ForceNoCache    bool
-	Config          []*MountConfig
-	Roles           MountRoles
+	MountSettings
+
+	Environment    *Environment
+	Namespace      string
+	Name           string
+	Type           string
+	PreventDestroy bool
+	PreviousPaths  []string
+	Config         []*MountConfig
+	Roles          MountRoles
+	Source         Source
+}
+
+// IsPlaceholder returns true if the mount only exist to be protected from pruning
+func (m *Mount) IsPlaceholder() bool {
+	return m.PreventDestroy && m.Type == ""
+}
+
+// merge merges the type, prevent_destroy, settings and previous_paths of another declaration of the mount
+// into it, and returns the keys both declare with a different value. previous_paths never conflict
+func (m *Mount) merge(mountType string, preventDestroy bool, settings MountSettings, previousPaths []string) []string {
+	conflicts := m.MountSettings.merge(settings)
+	m.PreviousPaths = mergePreviousPaths(m.PreviousPaths, previousPaths)
+
+	if m.Type == "" {
+		m.Type = mountType
+	} else if mountType != "" && mountType != m.Type {
+		conflicts = append([]string{"type"}, conflicts...)
+	}
+
+	m.PreventDestroy = m.PreventDestroy || preventDestroy
+	return conflicts
+}
+
+// mergePreviousPaths returns paths with the paths of other it doesn't contain yet, a mount can
+// be moved from any previous path declared for it
+func mergePreviousPaths(paths, other []string) []string {
+	for _, path := range other {
+		if !containsString(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
 }
 
 // MountInput ...
 func (m *Mount) MountInput() *api.MountInput {
+	return m.MountSettings.mountInput(m.Type)
+}
+
+// MountSettings are the settings shared by mounts and auth backends. All of them are sent when
+// the mount is enabled, and the tunable ones are updated on existing mounts
+type MountSettings struct {
+	Description               string            `hcl:"description"`
+	DefaultLeaseTTL           string            `hcl:"default_lease_ttl"`
+	MaxLeaseTTL               string            `hcl:"max_lease_ttl"`
+	ForceNoCache              bool              `hcl:"force_no_cache"`
+	Options                   map[string]string `hcl:"options"`
+	SealWrap                  bool              `hcl:"seal_wrap"`
+	Local                     bool              `hcl:"local"`
+	AuditNonHMACRequestKeys   []string          `hcl:"audit_non_hmac_request_keys"`
+	AuditNonHMACResponseKeys  []string          `hcl:"audit_non_hmac_response_keys"`
+	ListingVisibility         string            `hcl:"listing_visibility"`
+	PassthroughRequestHeaders []string          `hcl:"passthrough_request_headers"`
+	AllowedResponseHeaders    []string          `hcl:"allowed_response_headers"`
+	TokenType                 string            `hcl:"token_type"`
+	PluginVersion             string            `hcl:"plugin_version"`
+}
+
+// mountSettingKeys are the HCL keys of MountSettings
+var mountSettingKeys = []string{
+	"description", "default_lease_ttl", "max_lease_ttl", "force_no_cache", "options", "seal_wrap", "local",
+	"audit_non_hmac_request_keys", "audit_non_hmac_response_keys", "listing_visibility",
+	"passthrough_request_headers", "allowed_response_headers", "token_type", "plugin_version",
+}
+
+// mountInput returns the input enabling a mount of mountType with the settings. plugin_version
+// is not known by the Vault api client, and must be added to the request by the caller
+func (s MountSettings) mountInput(mountType string) *api.MountInput {
 	return &api.MountInput{
-		Type:        m.Type,
-		Description: m.Description,
+		Type:        mountType,
+		Description: s.Description,
+		Local:       s.Local,
+		SealWrap:    s.SealWrap,
+		Options:     s.Options,
 		Config: api.MountConfigInput{
-			DefaultLeaseTTL: m.DefaultLeaseTTL,
-			MaxLeaseTTL:     m.MaxLeaseTTL,
-			ForceNoCache:    m.ForceNoCache,
+			DefaultLeaseTTL:           s.DefaultLeaseTTL,
+			MaxLeaseTTL:               s.MaxLeaseTTL,
+			ForceNoCache:              s.ForceNoCache,
+			AuditNonHMACRequestKeys:   s.AuditNonHMACRequestKeys,
+			AuditNonHMACResponseKeys:  s.AuditNonHMACResponseKeys,
+			ListingVisibility:         s.ListingVisibility,
+			PassthroughRequestHeaders: s.PassthroughRequestHeaders,
+			AllowedResponseHeaders:    s.AllowedResponseHeaders,
+			TokenType:                 s.TokenType,
 		},
 	}
 }
 
+// TuneData returns the tunable settings set in the configuration, in the format of sys/mounts/<path>/tune.
+// force_no_cache, seal_wrap and local can only be set when the mount is enabled
+func (s MountSettings) TuneData() map[string]interface{} {
+	data := make(map[string]interface{})
+
+	values := map[string]string{
+		"description":        s.Description,
+		"default_lease_ttl":  s.DefaultLeaseTTL,
+		"max_lease_ttl":      s.MaxLeaseTTL,
+		"listing_visibility": s.ListingVisibility,
+		"token_type":         s.TokenType,
+		"plugin_version":     s.PluginVersion,
+	}
+	for key, value := range values {
+		if value != "" {
+			data[key] = value
+		}
+	}
+
+	lists := map[string][]string{
+		"audit_non_hmac_request_keys":  s.AuditNonHMACRequestKeys,
+		"audit_non_hmac_response_keys": s.AuditNonHMACResponseKeys,
+		"passthrough_request_headers":  s.PassthroughRequestHeaders,
+		"allowed_response_headers":     s.AllowedResponseHeaders,
+	}
+	for key, value := range lists {
+		if value != nil {
+			data[key] = value
+		}
+	}
+
+	if len(s.Options) > 0 {
+		options := make(map[string]interface{}, len(s.Options))
+		for key, value := range s.Options {
+			options[key] = value
+		}
+		data["options"] = options
+	}
+
+	return data
+}
+
+// merge fills the settings that are not set with the ones of other, and returns the keys of the settings
+// that both set to a different value. force_no_cache, seal_wrap and local are enabled if either enables them
+func (s *MountSettings) merge(other MountSettings) []string {
+	conflicts := make([]string, 0)
+
+	values := []struct {
+		key   string
+		value *string
+		other string
+	}{
+		{"description", &s.Description, other.Description},
+		{"default_lease_ttl", &s.DefaultLeaseTTL, other.DefaultLeaseTTL},
+		{"max_lease_ttl", &s.MaxLeaseTTL, other.MaxLeaseTTL},
+		{"listing_visibility", &s.ListingVisibility, other.ListingVisibility},
+		{"token_type", &s.TokenType, other.TokenType},
+		{"plugin_version", &s.PluginVersion, other.PluginVersion},
+	}
+	for _, v := range values {
+		switch {
+		case v.other == "" || v.other == *v.value:
+		case *v.value == "":
+			*v.value = v.other
+		default:
+			conflicts = append(conflicts, v.key)
+		}
+	}
+
+	lists := []struct {
+		key   string
+		value *[]string
+		other []string
+	}{
+		{"audit_non_hmac_request_keys", &s.AuditNonHMACRequestKeys, other.AuditNonHMACRequestKeys},
+		{"audit_non_hmac_response_keys", &s.AuditNonHMACResponseKeys, other.AuditNonHMACResponseKeys},
+		{"passthrough_request_headers", &s.PassthroughRequestHeaders, other.PassthroughRequestHeaders},
+		{"allowed_response_headers", &s.AllowedResponseHeaders, other.AllowedResponseHeaders},
+	}
+	for _, l := range lists {
+		switch {
+		case l.other == nil || reflect.DeepEqual(l.other, *l.value):
+		case *l.value == nil:
+			*l.value = l.other
+		default:
+			conflicts = append(conflicts, l.key)
+		}
+	}
+
+	s.ForceNoCache = s.ForceNoCache || other.ForceNoCache
+	s.SealWrap = s.SealWrap || other.SealWrap
+	s.Local = s.Local || other.Local
+
+	for key, value := range other.Options {
+		if s.Options == nil {
+			s.Options = make(map[string]string)
+		}
+
+		if existing, ok := s.Options[key]; !ok {
+			s.Options[key] = value
+		} else if existing != value {
+			conflicts = append(conflicts, "options -> "+key)
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// mergeData adds the keys of other that data does not have, and returns the keys both have with a different value
+func mergeData(data *map[string]interface{}, other map[string]interface{}) []string {
+	conflicts := make([]string, 0)
+
+	for key, value := range other {
+		if *data == nil {
+			*data = make(map[string]interface{})
+		}
+
+		if existing, ok := (*data)[key]; !ok {
+			(*data)[key] = value
+		} else if !reflect.DeepEqual(existing, value) {
+			conflicts = append(conflicts, key)
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// ImmutableChanges returns the settings that differ from the remote mount, but can't be tuned
+func (s MountSettings) ImmutableChanges(remote *api.MountOutput) []string {
+	changed := make([]string, 0)
+
+	if s.SealWrap != remote.SealWrap {
+		changed = append(changed, "seal_wrap")
+	}
+
+	if s.Local != remote.Local {
+		changed = append(changed, "local")
+	}
+
+	if s.ForceNoCache != remote.Config.ForceNoCache {
+		changed = append(changed, "force_no_cache")
+	}
+
+	return changed
+}
+
+// parsePreviousPaths decodes the previous_paths of a mount or auth stanza, the paths it was mounted at before
+func (c *Config) parsePreviousPaths(x *ast.ObjectList, kind, name string, environment *Environment) ([]string, error) {
+	list := x.Filter("previous_paths")
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+
+	if len(list.Items) > 1 {
+		return nil, fmt.Errorf("You can only specify previous_paths once per %s in %s -> %s", kind, environment.Name, name)
+	}
+
+	var paths []string
+	if err := hcl.DecodeObject(&paths, list.Items[0].Val); err != nil {
+		return nil, fmt.Errorf("previous_paths must be a list of strings in %s -> %s: %s", environment.Name, name, err)
+	}
+
+	for i, path := range paths {
+		paths[i] = strings.Trim(path, "/")
+		if paths[i] == strings.Trim(name, "/") {
+			return nil, fmt.Errorf("previous_paths of %s -> %s can't contain its own path", environment.Name, name)
+		}
+	}
+
+	return paths, nil
+}
+
+// parseMountSettings decodes the MountSettings of a mount or auth stanza
+func (c *Config) parseMountSettings(item *ast.ObjectItem, kind, name string, environment *Environment) (MountSettings, error) {
+	var settings MountSettings
+
+	x := item.Val.(*ast.ObjectType).List
+	for _, key := range mountSettingKeys {
+		if len(x.Filter(key).Items) > 1 {
+			return settings, fmt.Errorf("You can only specify %s once per %s in %s -> %s", key, kind, environment.Name, name)
+		}
+	}
+
+	if err := hcl.DecodeObject(&settings, item.Val); err != nil {
+		return settings, fmt.Errorf("invalid %s settings in %s -> %s: %s", kind, environment.Name, name, err)
+	}
+
+	return settings, nil
+}
+
 // MountRoles ...
 type MountRoles []*MountRole
 
@@ -43,6 +314,17 @@ func (r *MountRoles) Add(role *MountRole) {
 	*r = append(*r, role)
 }
 
+// Find returns the role with name, or nil
+func (r MountRoles) Find(name string) *MountRole {
+	for _, role := range r {
+		if role.Name == name {
+			return role
+		}
+	}
+
+	return nil
+}
+
 // VaultMounts struct
 //
 // environment
@@ -53,10 +335,15 @@ func (m *VaultMounts) Add(mount *Mount) {
 	*m = append(*m, mount)
 }
 
-// Find ...
+// Find returns the mount with name in the root namespace
 func (m *VaultMounts) Find(name string) *Mount {
+	return m.FindInNamespace("", name)
+}
+
+// FindInNamespace returns the mount with name in the Vault namespace
+func (m *VaultMounts) FindInNamespace(namespace, name string) *Mount {
 	for _, mount := range *m {
-		if mount.Name == name {
+		if mount.Namespace == namespace && mount.Name == name {
 			return mount
 		}
 	}
@@ -64,16 +351,29 @@ func (m *VaultMounts) Find(name string) *Mount {
 	return nil
 }
 
+// findConfig returns the config with name, or nil
+func (m *Mount) findConfig(name string) *MountConfig {
+	for _, config := range m.Config {
+		if config.Name == name {
+			return config
+		}
+	}
+
+	return nil
+}
+
 // MountConfig ...
 type MountConfig struct {
-	Name string
-	Data map[string]interface{}
+	Name   string
+	Data   map[string]interface{}
+	Source Source
 }
 
 // MountRole ...
 type MountRole struct {
-	Name string
-	Data map[string]interface{}
+	Name   string
+	Data   map[string]interface{}
+	Source Source
 }
 
 func (c *Config) parseVaultMountStanza(list *ast.ObjectList, environment *Environment) error {
@@ -86,7 +386,7 @@ func (c *Config) parseVaultMountStanza(list *ast.ObjectList, environment *Enviro
 	for _, mountAST := range list.Items {
 		x := mountAST.Val.(*ast.ObjectType).List
 
-		valid := []string{"config", "role", "type", "path", "max_lease_ttl", "default_lease_ttl", "force_no_cache", "description"}
+		valid := append([]string{"config", "role", "type", "path", "prevent_destroy", "previous_paths", overrideKey}, mountSettingKeys...)
 		if err := c.checkHCLKeys(x, valid); err != nil {
 			return err
 		}
@@ -97,94 +397,107 @@ func (c *Config) parseVaultMountStanza(list *ast.ObjectList, environment *Enviro
 
 		mountName := mountAST.Keys[0].Token.Value().(string)
 
-		mount := c.VaultMounts.Find(mountName)
-		existing := true
-		if mount == nil {
-			existing = false
-			typeAST := x.Filter("type")
-			if len(typeAST.Items) != 1 {
-				return fmt.Errorf("missing mount type in %s -> %s", environment.Name, mountName)
+		mountPreventDestroy := false
+		preventDestroyAST := x.Filter("prevent_destroy")
+		if len(preventDestroyAST.Items) == 1 {
+			literal, ok := preventDestroyAST.Items[0].Val.(*ast.LiteralType)
+			if !ok {
+				return fmt.Errorf("prevent_destroy must be a boolean in %s -> %s", environment.Name, mountName)
 			}
-			mountType := typeAST.Items[0].Val.(*ast.LiteralType).Token.Value().(string)
-
-			mountMaxLeaseTTL := ""
-			maxTTLAST := x.Filter("max_lease_ttl")
-			if len(maxTTLAST.Items) == 1 {
-				v := maxTTLAST.Items[0].Val.(*ast.LiteralType).Token.Value()
-				switch t := v.(type) {
-				default:
-					return fmt.Errorf("unexpected type %T for %s -> %s -> max_lease_ttl", environment.Name, mountName, t)
-				case string:
-					mountMaxLeaseTTL = v.(string)
-				}
-			} else if len(maxTTLAST.Items) > 1 {
-				return fmt.Errorf("You can only specify max_lease_ttl once per mount in %s -> %s", environment.Name, mountName)
+
+			v := literal.Token.Value()
+			switch t := v.(type) {
+			default:
+				return fmt.Errorf("unexpected type %T for %s -> %s -> prevent_destroy", t, environment.Name, mountName)
+			case bool:
+				mountPreventDestroy = t
 			}
+		} else if len(preventDestroyAST.Items) > 1 {
+			return fmt.Errorf("You can only specify prevent_destroy once per mount in %s -> %s", environment.Name, mountName)
+		}
 
-			mountDefaultLeaseTTL := ""
-			defaultTTLAST := x.Filter("default_lease_ttl")
-			if len(defaultTTLAST.Items) == 1 {
-				v := defaultTTLAST.Items[0].Val.(*ast.LiteralType).Token.Value()
-				switch t := v.(type) {
-				default:
-					return fmt.Errorf("unexpected type %T for %s -> %s -> default_lease_ttl", environment.Name, mountName, t)
-				case string:
-					mountDefaultLeaseTTL = v.(string)
-				}
-			} else if len(defaultTTLAST.Items) > 1 {
-				return fmt.Errorf("You can only specify default_lease_ttl once per mount in %s -> %s", environment.Name, mountName)
+		mountType := ""
+		typeAST := x.Filter("type")
+		if len(typeAST.Items) == 1 {
+			literal, ok := typeAST.Items[0].Val.(*ast.LiteralType)
+			if !ok {
+				return fmt.Errorf("mount type must be a string in %s -> %s", environment.Name, mountName)
 			}
 
-			mountForceNoCache := false
-			forceNoCacheAST := x.Filter("force_no_cache")
-			if len(forceNoCacheAST.Items) == 1 {
-				v := forceNoCacheAST.Items[0].Val.(*ast.LiteralType).Token.Value()
-				switch t := v.(type) {
-				default:
-					return fmt.Errorf("unexpected type %T for %s -> %s -> force_no_cache", environment.Name, mountName, t)
-				case bool:
-					mountForceNoCache = v.(bool)
-				}
-			} else if len(forceNoCacheAST.Items) > 1 {
-				return fmt.Errorf("You can only specify force_no_cache once per mount in %s -> %s", environment.Name, mountName)
+			if mountType, ok = literal.Token.Value().(string); !ok {
+				return fmt.Errorf("mount type must be a string in %s -> %s", environment.Name, mountName)
 			}
-			description := ""
-			descriptionAST := x.Filter("description")
-			if len(descriptionAST.Items) == 1 {
-				v := descriptionAST.Items[0].Val.(*ast.LiteralType).Token.Value()
-				switch t := v.(type) {
-				default:
-					return fmt.Errorf("unexpected type %T for %s -> %s -> description", environment.Name, mountName, t)
-				case string:
-					description = v.(string)
-				}
-			} else if len(descriptionAST.Items) > 1 {
-				return fmt.Errorf("You can only specify description once per mount in %s -> %s", environment.Name, mountName)
+		} else if len(typeAST.Items) > 1 {
+			return fmt.Errorf("You can only specify type once per mount in %s -> %s", environment.Name, mountName)
+		}
+
+		settings, err := c.parseMountSettings(mountAST, "mount", mountName, environment)
+		if err != nil {
+			return err
+		}
+
+		if err := c.markOverride(mountAST.Val, "mount", mountName); err != nil {
+			return err
+		}
+
+		previousPaths, err := c.parsePreviousPaths(x, "mount", mountName, environment)
+		if err != nil {
+			return err
+		}
+
+		mount := c.VaultMounts.FindInNamespace(c.vaultNamespace, mountName)
+		existing := true
+		if mount == nil {
+			existing = false
+
+			// a mount with only prevent_destroy is a placeholder protecting a mount
+			// managed outside of hashi-helper, and does not need a type
+			if mountType == "" && !mountPreventDestroy {
+				return fmt.Errorf("missing mount type in %s -> %s", environment.Name, mountName)
 			}
 
 			mount = &Mount{
-				Name:            mountName,
-				Type:            mountType,
-				Environment:     environment,
-				MaxLeaseTTL:     mountMaxLeaseTTL,
-				DefaultLeaseTTL: mountDefaultLeaseTTL,
-				ForceNoCache:    mountForceNoCache,
-				Description:     description,
+				MountSettings:  settings,
+				Name:           mountName,
+				Type:           mountType,
+				Environment:    environment,
+				Namespace:      c.vaultNamespace,
+				PreventDestroy: mountPreventDestroy,
+				PreviousPaths:  previousPaths,
+				Source:         c.source(mountAST.Keys[0].Token.Pos),
+			}
+		} else if conflicts := mount.merge(mountType, mountPreventDestroy, settings, previousPaths); len(conflicts) > 0 {
+			// a mount declared more than once is merged, the values declared first win over
+			// inherited ones, but must agree within the same environment
+			if !c.overridden("mount", mountName) {
+				return fmt.Errorf("mount %s -> %s is declared more than once with a different %s", environment.Name, mountName, strings.Join(conflicts, ", "))
 			}
 		}
 
 		configAST := x.Filter("config")
-		if len(configAST.Items) > 0 && existing {
-			return fmt.Errorf("You are modifying an existing mount (%s), can't change config", mountName)
-		}
-
 		if len(configAST.Items) > 0 {
-			config, err := c.parseMountConfig(configAST)
+			configs, err := c.parseMountConfig(configAST, mountName)
 			if err != nil {
 				return err
 			}
 
-			mount.Config = config
+			// config inherited from another environment is overridden by name, config declared
+			// more than once in the same environment is merged
+			for _, config := range configs {
+				current := mount.findConfig(config.Name)
+				if current == nil {
+					mount.Config = append(mount.Config, config)
+					continue
+				}
+
+				if c.overridden("mount config", mountName+" -> "+config.Name) {
+					continue
+				}
+
+				if conflicts := mergeData(&current.Data, config.Data); len(conflicts) > 0 {
+					return fmt.Errorf("mount config %s -> %s is declared more than once in %s with a different %s", mountName, config.Name, environment.Name, strings.Join(conflicts, ", "))
+				}
+			}
 		}
 
 		roleAST := x.Filter("role")
@@ -203,7 +516,7 @@ func (c *Config) parseVaultMountStanza(list *ast.ObjectList, environment *Enviro
 	return nil
 }
 
-func (c *Config) parseMountConfig(list *ast.ObjectList) ([]*MountConfig, error) {
+func (c *Config) parseMountConfig(list *ast.ObjectList, mountName string) ([]*MountConfig, error) {
 	configs := make([]*MountConfig, 0)
 
 	for _, mountConfigAST := range list.Items {
@@ -212,12 +525,17 @@ func (c *Config) parseMountConfig(list *ast.ObjectList) ([]*MountConfig, error)
 		}
 
 		var m map[string]interface{}
-		if err := hcl.DecodeObject(&m, mountConfigAST.Val); err != nil {
+		if err := hcl.DecodeObject(&m, withoutOverride(mountConfigAST.Val)); err != nil {
 			return nil, err
 		}
 
 		var config MountConfig
 		config.Name = mountConfigAST.Keys[0].Token.Value().(string)
+		config.Source = c.source(mountConfigAST.Keys[0].Token.Pos)
+
+		if err := c.markOverride(mountConfigAST.Val, "mount config", mountName+" -> "+config.Name); err != nil {
+			return nil, err
+		}
 
 		if err := mapstructure.WeakDecode(m, &config.Data); err != nil {
 			return nil, err
@@ -236,18 +554,37 @@ func (c *Config) parseMountRole(list *ast.ObjectList, mount *Mount) error {
 		}
 
 		var m map[string]interface{}
-		if err := hcl.DecodeObject(&m, config.Val); err != nil {
+		if err := hcl.DecodeObject(&m, withoutOverride(config.Val)); err != nil {
 			return err
 		}
 
 		var role MountRole
 		role.Name = config.Keys[0].Token.Value().(string)
+		role.Source = c.source(config.Keys[0].Token.Pos)
+
+		if err := c.markOverride(config.Val, "mount role", mount.Name+" -> "+role.Name); err != nil {
+			return err
+		}
 
 		if err := mapstructure.WeakDecode(m, &role.Data); err != nil {
 			return err
 		}
 
-		mount.Roles.Add(&role)
+		// roles inherited from another environment are overridden by name, roles declared
+		// more than once in the same environment are merged
+		current := mount.Roles.Find(role.Name)
+		if current == nil {
+			mount.Roles.Add(&role)
+			continue
+		}
+
+		if c.overridden("mount role", mount.Name+" -> "+role.Name) {
+			continue
+		}
+
+		if conflicts := mergeData(&current.Data, role.Data); len(conflicts) > 0 {
+			return fmt.Errorf("mount role %s -> %s is declared more than once in %s with a different %s", mount.Name, role.Name, mount.Environment.Name, strings.Join(conflicts, ", "))
+		}
 	}
 
 	return nil
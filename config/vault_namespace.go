@@ -0,0 +1,263 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// VaultNamespace is a Vault Enterprise namespace, Path is the full path from the root namespace
+type VaultNamespace struct {
+	Environment *Environment
+	Path        string
+}
+
+// Name returns the last segment of the namespace path
+func (n *VaultNamespace) Name() string {
+	return n.Path[strings.LastIndex(n.Path, "/")+1:]
+}
+
+// Parent returns the path of the parent namespace, "" being the root namespace
+func (n *VaultNamespace) Parent() string {
+	if i := strings.LastIndex(n.Path, "/"); i >= 0 {
+		return n.Path[:i]
+	}
+
+	return ""
+}
+
+// VaultNamespaces ...
+type VaultNamespaces []*VaultNamespace
+
+// add will add the namespace and all its parents, if they don't exist already
+func (n *VaultNamespaces) add(namespace *VaultNamespace) {
+	parts := strings.Split(namespace.Path, "/")
+
+	for i := range parts {
+		path := strings.Join(parts[:i+1], "/")
+		if n.Contains(path) {
+			continue
+		}
+
+		*n = append(*n, &VaultNamespace{Environment: namespace.Environment, Path: path})
+	}
+}
+
+// Contains ...
+func (n VaultNamespaces) Contains(path string) bool {
+	for _, existing := range n {
+		if existing.Path == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VaultNamespacePaths returns the root namespace ("") and all namespaces used in config,
+// sorted so parents always come before their children
+func (c *Config) VaultNamespacePaths() []string {
+	result := []string{""}
+	for _, namespace := range c.VaultNamespaces {
+		result = append(result, namespace.Path)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// HasVaultResources returns true if config contains any Vault mount, auth backend, policy, secret,
+// audit device or identity resource. Use ForVaultNamespace first to check a single namespace
+func (c *Config) HasVaultResources() bool {
+	return len(c.VaultMounts) > 0 || len(c.VaultAuths) > 0 || len(c.VaultPolicies) > 0 ||
+		len(c.VaultSecrets) > 0 || len(c.VaultAudits) > 0 || len(c.VaultIdentityEntities) > 0 ||
+		len(c.VaultIdentityGroups) > 0 || len(c.VaultIdentityGroupAliases) > 0
+}
+
+// ForVaultNamespace returns a copy of the config only containing the Vault mounts, auth backends,
+// policies, secrets, audit devices and identity resources in namespace
+func (c *Config) ForVaultNamespace(namespace string) *Config {
+	result := *c
+
+	result.VaultMounts = VaultMounts{}
+	for _, mount := range c.VaultMounts {
+		if mount.Namespace == namespace {
+			result.VaultMounts = append(result.VaultMounts, mount)
+		}
+	}
+
+	result.VaultAuths = VaultAuths{}
+	for _, auth := range c.VaultAuths {
+		if auth.Namespace == namespace {
+			result.VaultAuths = append(result.VaultAuths, auth)
+		}
+	}
+
+	result.VaultPolicies = VaultPolicies{}
+	for _, policy := range c.VaultPolicies {
+		if policy.Namespace == namespace {
+			result.VaultPolicies = append(result.VaultPolicies, policy)
+		}
+	}
+
+	result.VaultSecrets = VaultSecrets{}
+	for _, secret := range c.VaultSecrets {
+		if secret.Namespace == namespace {
+			result.VaultSecrets = append(result.VaultSecrets, secret)
+		}
+	}
+
+	result.VaultAudits = VaultAudits{}
+	for _, audit := range c.VaultAudits {
+		if audit.Namespace == namespace {
+			result.VaultAudits = append(result.VaultAudits, audit)
+		}
+	}
+
+	result.VaultIdentityEntities = VaultIdentityEntities{}
+	for _, entity := range c.VaultIdentityEntities {
+		if entity.Namespace == namespace {
+			result.VaultIdentityEntities = append(result.VaultIdentityEntities, entity)
+		}
+	}
+
+	result.VaultIdentityGroups = VaultIdentityGroups{}
+	for _, group := range c.VaultIdentityGroups {
+		if group.Namespace == namespace {
+			result.VaultIdentityGroups = append(result.VaultIdentityGroups, group)
+		}
+	}
+
+	result.VaultIdentityGroupAliases = VaultIdentityGroupAliases{}
+	for _, alias := range c.VaultIdentityGroupAliases {
+		if alias.Namespace == namespace {
+			result.VaultIdentityGroupAliases = append(result.VaultIdentityGroupAliases, alias)
+		}
+	}
+
+	return &result
+}
+
+// parseVaultEnvironmentNamespace reads the `environment -> namespace = "<path>"` attribute,
+// which is the default namespace for all Vault resources in the environment stanza
+func (c *Config) parseVaultEnvironmentNamespace(list *ast.ObjectList, env *Environment) (string, error) {
+	namespace := ""
+
+	for _, item := range list.Items {
+		literal, ok := item.Val.(*ast.LiteralType)
+		if !ok {
+			continue
+		}
+
+		if namespace != "" {
+			return "", fmt.Errorf("You can only specify namespace once per environment in %s", env.Name)
+		}
+
+		value, ok := literal.Token.Value().(string)
+		if !ok || strings.Trim(value, "/") == "" {
+			return "", fmt.Errorf("namespace must be a non-empty string in environment %s", env.Name)
+		}
+
+		namespace = strings.Trim(value, "/")
+		c.VaultNamespaces.add(&VaultNamespace{Environment: env, Path: namespace})
+	}
+
+	return namespace, nil
+}
+
+// parseVaultNamespaceStanza
+// parse out `environment -> namespace "<name>" {}`, the namespace name is relative to
+// the current namespace, and blocks can be nested
+func (c *Config) parseVaultNamespaceStanza(list *ast.ObjectList, env *Environment) error {
+	parent := c.vaultNamespace
+	defer func() {
+		c.vaultNamespace = parent
+	}()
+
+	for _, namespaceAST := range list.Items {
+		objectType, ok := namespaceAST.Val.(*ast.ObjectType)
+		if !ok {
+			// namespace = "<path>" attribute, handled by parseVaultEnvironmentNamespace
+			continue
+		}
+
+		if len(namespaceAST.Keys) != 1 {
+			return fmt.Errorf("Missing namespace name in line %+v", namespaceAST.Pos())
+		}
+
+		name := strings.Trim(namespaceAST.Keys[0].Token.Value().(string), "/")
+		if name == "" {
+			return fmt.Errorf("Namespace name can't be empty in line %+v", namespaceAST.Pos())
+		}
+
+		path := name
+		if parent != "" {
+			path = parent + "/" + name
+		}
+
+		x := objectType.List
+		valid := []string{"application", "auth", "audit", "policy", "policy_test", "mount", "secret", "secrets", "namespace",
+			"identity_entity", "identity_group", "identity_group_alias"}
+		if err := c.checkHCLKeys(x, valid); err != nil {
+			return err
+		}
+
+		c.logger.Debugf("Found namespace %s", path)
+		c.VaultNamespaces.add(&VaultNamespace{Environment: env, Path: path})
+		c.vaultNamespace = path
+
+		if err := c.parseVaultAuditStanza(x.Filter("audit"), env); err != nil {
+			return err
+		}
+
+		if err := c.parseApplicationStanza(x.Filter("application"), env); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultAuthStanza(x.Filter("auth"), env); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultSecretStanza(x.Filter("secret"), env, nil); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultSecretsStanza(x.Filter("secrets"), env, nil); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultPolicyStanza(x.Filter("policy"), env, nil); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultPolicyTestStanza(x.Filter("policy_test"), env); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultMountStanza(x.Filter("mount"), env); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultIdentityEntityStanza(x.Filter("identity_entity"), env); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultIdentityGroupStanza(x.Filter("identity_group"), env); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultIdentityGroupAliasStanza(x.Filter("identity_group_alias"), env); err != nil {
+			return err
+		}
+
+		if err := c.parseVaultNamespaceStanza(x.Filter("namespace"), env); err != nil {
+			return err
+		}
+
+		c.vaultNamespace = parent
+	}
+
+	return nil
+}
@@ -13,11 +13,19 @@ import (
 // Policy is used to represent the policy specified by
 // an ACL configuration.
 type Policy struct {
-	Environment *Environment
-	Application *Application
-	Name        string              `hcl:"name"`
-	Paths       []*PathCapabilities `hcl:"-"`
-	Raw         string
+	Environment    *Environment
+	Application    *Application
+	Namespace      string              `hcl:"-"`
+	Name           string              `hcl:"name"`
+	Paths          []*PathCapabilities `hcl:"-"`
+	PreventDestroy bool                `hcl:"prevent_destroy"`
+	Raw            string
+	Source         Source `hcl:"-"`
+}
+
+// IsPlaceholder returns true if the policy only exist to be protected from pruning
+func (p *Policy) IsPlaceholder() bool {
+	return p.PreventDestroy && strings.TrimSpace(p.Raw) == ""
 }
 
 // Equal ...
@@ -32,6 +40,11 @@ func (p *Policy) Equal(o *Policy) bool {
 		return false
 	}
 
+	// namespace must be same
+	if p.Namespace != o.Namespace {
+		return false
+	}
+
 	// @todo check Application
 
 	return true
@@ -72,7 +85,7 @@ func (c *Config) parseVaultPolicyStanza(list *ast.ObjectList, environment *Envir
 		x := policyAST.Val.(*ast.ObjectType).List
 
 		// Check for invalid top-level keys
-		valid := []string{"name", "path"}
+		valid := []string{"name", "path", "prevent_destroy", overrideKey}
 		if err := c.checkHCLKeys(x, valid); err != nil {
 			return fmt.Errorf("Failed to parse policy: %s", err)
 		}
@@ -82,12 +95,22 @@ func (c *Config) parseVaultPolicyStanza(list *ast.ObjectList, environment *Envir
 			Name:        policyAST.Keys[0].Token.Value().(string),
 			Environment: environment,
 			Application: application,
+			Namespace:   c.vaultNamespace,
+			Source:      c.source(policyAST.Keys[0].Token.Pos),
 		}
 
 		// Convert the HCL AST back to text so we can send it to the Vault API
+		// prevent_destroy and override are hashi-helper attributes, and not understood by Vault
+		rules := &ast.ObjectList{}
+		for _, item := range x.Children().Items {
+			if key := item.Keys[0].Token.Value().(string); key != "prevent_destroy" && key != overrideKey {
+				rules.Add(item)
+			}
+		}
+
 		buf := new(bytes.Buffer)
 		printer := printer.Config{}
-		printer.Fprint(buf, x.Children())
+		printer.Fprint(buf, rules)
 		policy.Raw = buf.String()
 
 		// Replace environment and maybe app name placeholders
@@ -100,13 +123,25 @@ func (c *Config) parseVaultPolicyStanza(list *ast.ObjectList, environment *Envir
 			return fmt.Errorf("Failed to parse policy: %s", err)
 		}
 
-		if o := list.Filter("path"); len(o.Items) > 0 {
+		if o := x.Filter("path"); len(o.Items) > 0 {
 			if err := c.parsePaths(policy, o); err != nil {
-				return fmt.Errorf("Failed to parse policy: %s", err)
+				return fmt.Errorf("Failed to parse policy %s: %s", policy.Name, err)
 			}
 		}
 
-		if c.VaultPolicies.Add(policy) == false {
+		// Replace the placeholders in the parsed paths as well
+		for _, path := range policy.Paths {
+			path.Prefix = strings.Replace(path.Prefix, "__ENV__", environment.Name, -1)
+			if application != nil {
+				path.Prefix = strings.Replace(path.Prefix, "__APP__", application.Name, -1)
+			}
+		}
+
+		if err := c.markOverride(policyAST.Val, "policy", policy.Name); err != nil {
+			return err
+		}
+
+		if c.VaultPolicies.Add(policy) == false && !c.overridden("policy", policy.Name) {
 			if application != nil {
 				c.logger.Warnf("      Ignored duplicate policy '%s' -> '%s' -> '%s' in line %s", environment.Name, application.Name, policy.Name, policyAST.Keys[0].Token.Pos)
 			} else {
@@ -71,6 +71,18 @@ type PathCapabilities struct {
 	MaxWrappingTTLHCL    interface{}              `hcl:"max_wrapping_ttl"`
 	AllowedParametersHCL map[string][]interface{} `hcl:"allowed_parameters"`
 	DeniedParametersHCL  map[string][]interface{} `hcl:"denied_parameters"`
+
+	// Source is where the path is defined, it's not part of the Vault struct
+	Source Source `hcl:"-"`
+}
+
+// Path returns the path as written in the policy, without a leading '/'
+func (pc *PathCapabilities) Path() string {
+	if pc.Glob {
+		return pc.Prefix + "*"
+	}
+
+	return pc.Prefix
 }
 
 type Permissions struct {
@@ -85,8 +97,10 @@ func (c *Config) parsePaths(result *Policy, list *ast.ObjectList) error {
 	paths := make([]*PathCapabilities, 0, len(list.Items))
 	for _, item := range list.Items {
 		key := "path"
+		var source Source
 		if len(item.Keys) > 0 {
 			key = item.Keys[0].Token.Value().(string)
+			source = c.source(item.Keys[0].Token.Pos)
 		}
 		valid := []string{
 			"policy",
@@ -106,6 +120,7 @@ func (c *Config) parsePaths(result *Policy, list *ast.ObjectList) error {
 		pc.Permissions = new(Permissions)
 
 		pc.Prefix = key
+		pc.Source = source
 		if err := hcl.DecodeObject(&pc, item.Val); err != nil {
 			return multierror.Prefix(err, fmt.Sprintf("path %q:", key))
 		}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// PolicyTest pins the access a set of policies must grant or deny, it's evaluated with ACL
+type PolicyTest struct {
+	Environment  *Environment
+	Namespace    string
+	Name         string
+	Policies     []string
+	Expectations []*PolicyExpectation
+	Source       Source
+}
+
+// PolicyExpectation is the capabilities that must be allowed and denied on a path
+type PolicyExpectation struct {
+	Path   string   `hcl:"-"`
+	Allow  []string `hcl:"allow"`
+	Deny   []string `hcl:"deny"`
+	Source Source   `hcl:"-"`
+}
+
+// VaultPolicyTests ...
+type VaultPolicyTests []*PolicyTest
+
+// find returns the test with name in the Vault namespace, or nil
+func (t VaultPolicyTests) find(namespace, name string) *PolicyTest {
+	for _, test := range t {
+		if test.Namespace == namespace && test.Name == name {
+			return test
+		}
+	}
+
+	return nil
+}
+
+// PolicyTestCase is the outcome of checking a single capability of an expectation
+type PolicyTestCase struct {
+	Path       string
+	Capability string
+	Expected   bool
+	Result     *ACLResult
+	Source     Source
+}
+
+// Passed returns true if the capability was allowed or denied as expected
+func (tc *PolicyTestCase) Passed() bool {
+	return tc.Result.Allowed == tc.Expected
+}
+
+// Name returns a readable name for the test case, like "allow read on secret/foo"
+func (tc *PolicyTestCase) Name() string {
+	verb := "deny"
+	if tc.Expected {
+		verb = "allow"
+	}
+
+	return fmt.Sprintf("%s %s on %s", verb, tc.Capability, tc.Path)
+}
+
+// PolicyTestResult is the outcome of running a PolicyTest
+type PolicyTestResult struct {
+	Test  *PolicyTest
+	Cases []*PolicyTestCase
+
+	// Error is set if the test could not run, e.g. because of an unknown policy
+	Error error
+}
+
+// Failures returns the number of failed test cases
+func (r *PolicyTestResult) Failures() int {
+	failures := 0
+	for _, tc := range r.Cases {
+		if !tc.Passed() {
+			failures++
+		}
+	}
+
+	return failures
+}
+
+// RunPolicyTests evaluates all policy_test{} stanza against the policies in config
+func (c *Config) RunPolicyTests() []*PolicyTestResult {
+	results := make([]*PolicyTestResult, 0, len(c.VaultPolicyTests))
+	for _, test := range c.VaultPolicyTests {
+		results = append(results, c.runPolicyTest(test))
+	}
+
+	return results
+}
+
+func (c *Config) runPolicyTest(test *PolicyTest) *PolicyTestResult {
+	result := &PolicyTestResult{Test: test, Cases: make([]*PolicyTestCase, 0)}
+
+	policies := make([]*Policy, 0, len(test.Policies))
+	for _, name := range test.Policies {
+		if name == "root" {
+			policies = append(policies, &Policy{Name: name})
+			continue
+		}
+
+		found := false
+		for _, policy := range c.VaultPolicies {
+			if policy.Name == name && policy.Namespace == test.Namespace && policy.Environment.equal(test.Environment) {
+				policies = append(policies, policy)
+				found = true
+			}
+		}
+
+		if !found {
+			result.Error = fmt.Errorf("policy_test %s references unknown policy %s", test.Name, name)
+			return result
+		}
+	}
+
+	acl := NewACL(policies...)
+	for _, expectation := range test.Expectations {
+		for _, expected := range []bool{true, false} {
+			capabilities := expectation.Deny
+			if expected {
+				capabilities = expectation.Allow
+			}
+
+			for _, capability := range capabilities {
+				check, err := acl.Check(expectation.Path, capability)
+				if err != nil {
+					result.Error = fmt.Errorf("policy_test %s: %s", test.Name, err)
+					return result
+				}
+
+				result.Cases = append(result.Cases, &PolicyTestCase{
+					Path:       expectation.Path,
+					Capability: capability,
+					Expected:   expected,
+					Result:     check,
+					Source:     expectation.Source,
+				})
+			}
+		}
+	}
+
+	return result
+}
+
+func (c *Config) parseVaultPolicyTestStanza(list *ast.ObjectList, environment *Environment) error {
+	if len(list.Items) < 1 {
+		return nil
+	}
+
+	c.logger = c.logger.WithField("stanza", "policy_test")
+	c.logger.Debugf("Found %d policy_test{}", len(list.Items))
+	for _, testAST := range list.Items {
+		if len(testAST.Keys) != 1 {
+			return fmt.Errorf("Missing policy_test name in line %+v", testAST.Pos())
+		}
+
+		x := testAST.Val.(*ast.ObjectType).List
+		if err := c.checkHCLKeys(x, []string{"policies", "expect", overrideKey}); err != nil {
+			return err
+		}
+
+		test := &PolicyTest{
+			Environment: environment,
+			Namespace:   c.vaultNamespace,
+			Name:        testAST.Keys[0].Token.Value().(string),
+			Source:      c.source(testAST.Keys[0].Token.Pos),
+		}
+
+		var data struct {
+			Policies []string `hcl:"policies"`
+		}
+		if err := hcl.DecodeObject(&data, testAST.Val); err != nil {
+			return fmt.Errorf("Failed to parse policy_test %s: %s", test.Name, err)
+		}
+		test.Policies = data.Policies
+
+		if len(test.Policies) == 0 {
+			return fmt.Errorf("policy_test %s must have at least one policy", test.Name)
+		}
+
+		for _, expectAST := range x.Filter("expect").Items {
+			if len(expectAST.Keys) != 1 {
+				return fmt.Errorf("Missing expect path in policy_test %s", test.Name)
+			}
+
+			if err := c.checkHCLKeys(expectAST.Val, []string{"allow", "deny"}); err != nil {
+				return err
+			}
+
+			expectation := &PolicyExpectation{}
+			if err := hcl.DecodeObject(expectation, expectAST.Val); err != nil {
+				return fmt.Errorf("Failed to parse policy_test %s: %s", test.Name, err)
+			}
+
+			expectation.Path = strings.Replace(expectAST.Keys[0].Token.Value().(string), "__ENV__", environment.Name, -1)
+			expectation.Source = c.source(expectAST.Keys[0].Token.Pos)
+			test.Expectations = append(test.Expectations, expectation)
+		}
+
+		if err := c.markOverride(testAST.Val, "policy_test", test.Name); err != nil {
+			return err
+		}
+
+		if c.inheritedFrom != "" && c.VaultPolicyTests.find(test.Namespace, test.Name) != nil && c.overridden("policy_test", test.Name) {
+			continue
+		}
+
+		c.VaultPolicyTests = append(c.VaultPolicyTests, test)
+	}
+
+	return nil
+}
@@ -14,11 +14,29 @@ type Secret struct {
 	secret      *Secret
 	Application *Application
 	Environment *Environment
+	Namespace   string
 	Path        string
 	Key         string
 	VaultSecret *vault.Secret
+	KVOptions   *KVOptions
+	Source      Source
 }
 
+// KVOptions are the KV version 2 settings of a secret, they are not part of the secret data
+type KVOptions struct {
+	CAS            *int              `hcl:"cas"`
+	MaxVersions    int               `hcl:"max_versions"`
+	CustomMetadata map[string]string `hcl:"custom_metadata"`
+}
+
+// HasMetadata returns true if the secret metadata (max_versions, custom_metadata) should be written
+func (o *KVOptions) HasMetadata() bool {
+	return o != nil && (o.MaxVersions > 0 || len(o.CustomMetadata) > 0)
+}
+
+// kvOptionKeys are the keys in a secret stanza used for KVOptions rather than secret data
+var kvOptionKeys = []string{"cas", "max_versions", "custom_metadata"}
+
 // Equal ...
 func (s *Secret) Equal(o *Secret) bool {
 	if s.Application != nil && o.Application != nil {
@@ -27,7 +45,7 @@ func (s *Secret) Equal(o *Secret) bool {
 		}
 	}
 
-	return s.Path == o.Path && s.Key == o.Key
+	return s.Namespace == o.Namespace && s.Path == o.Path && s.Key == o.Key
 }
 
 // VaultSecrets struct
@@ -103,23 +121,36 @@ func (c *Config) parseVaultSecretStanza(list *ast.ObjectList, env *Environment,
 		}
 
 		var m map[string]interface{}
-		if err := hcl.DecodeObject(&m, secretData.Val); err != nil {
+		if err := hcl.DecodeObject(&m, withoutOverride(secretData.Val)); err != nil {
 			return err
 		}
 
 		secretName := secretData.Keys[0].Token.Value().(string)
 
+		kvOptions, err := parseKVOptions(secretData, m)
+		if err != nil {
+			return fmt.Errorf("Invalid KV options in secret %s -> %s: %s", env.Name, secretName, err)
+		}
+
 		secret := &Secret{
 			Application: app,
 			Environment: env,
+			Namespace:   c.vaultNamespace,
 			Path:        secretName,
 			Key:         secretName,
+			KVOptions:   kvOptions,
+			Source:      c.source(secretData.Keys[0].Token.Pos),
 			VaultSecret: &vault.Secret{
 				Data: m,
 			},
 		}
 
-		if c.VaultSecrets.Add(secret) == false {
+		if err := c.markOverride(secretData.Val, "secret", secret.Path); err != nil {
+			return err
+		}
+
+		if c.VaultSecrets.Add(secret) == false && !c.overridden("secret", secret.Path) {
+			c.duplicateSecrets = append(c.duplicateSecrets, secret)
 			if secret.Application != nil {
 				c.logger.Warnf("Ignored duplicate secret '%s' -> '%s' -> '%s' in line %s", secret.Environment.Name, secret.Application.Name, secret.Key, secretData.Keys[0].Token.Pos)
 			} else {
@@ -143,7 +174,7 @@ func (c *Config) parseVaultSecretsStanza(list *ast.ObjectList, env *Environment,
 		}
 
 		var m map[string]string
-		if err := hcl.DecodeObject(&m, secretData.Val); err != nil {
+		if err := hcl.DecodeObject(&m, withoutOverride(secretData.Val)); err != nil {
 			return err
 		}
 
@@ -151,14 +182,21 @@ func (c *Config) parseVaultSecretsStanza(list *ast.ObjectList, env *Environment,
 			secret := &Secret{
 				Application: app,
 				Environment: env,
+				Namespace:   c.vaultNamespace,
 				Path:        k,
 				Key:         k,
+				Source:      c.source(secretData.Pos()),
 				VaultSecret: &vault.Secret{
 					Data: map[string]interface{}{"value": v},
 				},
 			}
 
-			if c.VaultSecrets.Add(secret) == false {
+			if err := c.markOverride(secretData.Val, "secret", secret.Path); err != nil {
+				return err
+			}
+
+			if c.VaultSecrets.Add(secret) == false && !c.overridden("secret", secret.Path) {
+				c.duplicateSecrets = append(c.duplicateSecrets, secret)
 				if secret.Application != nil {
 					c.logger.Warnf("Ignored duplicate secret '%s' -> '%s' -> '%s' in line %s", secret.Environment.Name, secret.Application.Name, secret.Key, secretData.Pos())
 				} else {
@@ -170,3 +208,34 @@ func (c *Config) parseVaultSecretsStanza(list *ast.ObjectList, env *Environment,
 
 	return nil
 }
+
+// parseKVOptions decodes cas, max_versions and custom_metadata from a secret stanza
+// and removes them from the secret data
+func parseKVOptions(secretData *ast.ObjectItem, data map[string]interface{}) (*KVOptions, error) {
+	found := false
+	for _, key := range kvOptionKeys {
+		if _, ok := data[key]; ok {
+			found = true
+			delete(data, key)
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	var options KVOptions
+	if err := hcl.DecodeObject(&options, secretData.Val); err != nil {
+		return nil, err
+	}
+
+	if options.CAS != nil && *options.CAS < 0 {
+		return nil, fmt.Errorf("cas must be 0 or greater")
+	}
+
+	if options.MaxVersions < 0 {
+		return nil, fmt.Errorf("max_versions must be 0 or greater")
+	}
+
+	return &options, nil
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"runtime"
 	"sort"
 
 	allCommand "github.com/seatgeek/hashi-helper/command"
 	consulCommand "github.com/seatgeek/hashi-helper/command/consul"
+	nomadCommand "github.com/seatgeek/hashi-helper/command/nomad"
 	profileCommand "github.com/seatgeek/hashi-helper/command/profile"
+	"github.com/seatgeek/hashi-helper/command/report"
+	secretsCommand "github.com/seatgeek/hashi-helper/command/secrets"
+	"github.com/seatgeek/hashi-helper/command/snapshot"
 	vaultCommand "github.com/seatgeek/hashi-helper/command/vault"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/urfave/cli.v1"
@@ -65,18 +70,181 @@ func main() {
 			Name:  "variable-file, var-file, varf",
 			Usage: "List of files to load as variable sources",
 		},
+		cli.StringFlag{
+			Name:   "template-fixtures",
+			Usage:  "JSON file to serve the key, ls, tree, secret, services and catalogService template lookups from, instead of Consul and Vault",
+			EnvVar: "TEMPLATE_FIXTURES",
+		},
 		cli.BoolFlag{
 			Name: "lint",
 		},
+		cli.StringFlag{
+			Name:   "age-identity-file",
+			Usage:  "age identity file used to decrypt ENC[age,...] values and files",
+			EnvVar: "AGE_IDENTITY_FILE",
+		},
+		cli.StringFlag{
+			Name:   "pgp-secret-keyring",
+			Usage:  "OpenPGP secret keyring used to decrypt ENC[pgp,...] values and files (passphrase from ENV[PGP_PASSPHRASE])",
+			EnvVar: "PGP_SECRET_KEYRING",
+		},
+		cli.StringFlag{
+			Name:   "output",
+			Value:  "text",
+			Usage:  "Output format for push results (text, json). json writes one record per resource and a summary to stdout",
+			EnvVar: "OUTPUT",
+		},
+		cli.StringFlag{
+			Name:   "report-file",
+			Usage:  "Write all push results and a summary as JSON to this file",
+			EnvVar: "REPORT_FILE",
+		},
+		cli.StringFlag{
+			Name:   "snapshot-dir",
+			Value:  "snapshots",
+			Usage:  "Directory to write the encrypted snapshot of the remote state a push modifies to, empty to disable",
+			EnvVar: "SNAPSHOT_DIR",
+		},
+		cli.StringSliceFlag{
+			Name:   "snapshot-age-recipient",
+			Usage:  "age public key to encrypt snapshots for (repeatable)",
+			EnvVar: "SNAPSHOT_AGE_RECIPIENTS",
+		},
+		cli.StringFlag{
+			Name:   "snapshot-pgp-public-keyring",
+			Usage:  "OpenPGP keyring with the public keys to encrypt snapshots for, used if no age recipient is provided",
+			EnvVar: "SNAPSHOT_PGP_PUBLIC_KEYRING",
+		},
+		cli.BoolFlag{
+			Name:   "atomic",
+			Usage:  "Roll back all changes a push already made if any resource fails",
+			EnvVar: "ATOMIC",
+		},
+		cli.BoolFlag{
+			Name:   "keep-going",
+			Usage:  "Keep pushing the other resources after a resource failed, and report all failures at the end",
+			EnvVar: "KEEP_GOING",
+		},
+		cli.IntFlag{
+			Name:   "retries",
+			Value:  3,
+			Usage:  "How often to retry a request that failed with a connection error, 429 or 5xx, waiting exponentially longer between attempts",
+			EnvVar: "RETRIES",
+		},
+		cli.BoolFlag{
+			Name:   "force",
+			Usage:  "Write every resource on push, even if the remote value already matches the config",
+			EnvVar: "FORCE",
+		},
 	}
+	// shared by all push commands able to create Consul ACL tokens
+	aclFlags := []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "keybase",
+			Usage: "Keybase users to encrypt the secret of newly created Consul ACL tokens for",
+		},
+	}
+	// shared by all push commands able to delete remote resources missing from config
+	pruneFlags := []cli.Flag{
+		cli.BoolFlag{
+			Name:  "prune",
+			Usage: "Delete remote resources that do not exist in config",
+		},
+		cli.BoolFlag{
+			Name:  "yes",
+			Usage: "Do not ask for confirmation before pruning",
+		},
+	}
+	// shared by commands encrypting secret values
+	encryptFlags := []cli.Flag{
+		cli.StringSliceFlag{
+			Name:   "age-recipient",
+			Usage:  "age public key to encrypt values for (repeatable)",
+			EnvVar: "AGE_RECIPIENTS",
+		},
+		cli.StringFlag{
+			Name:   "pgp-public-keyring",
+			Usage:  "OpenPGP keyring with the public keys to encrypt values for, used if no age recipient is provided",
+			EnvVar: "PGP_PUBLIC_KEYRING",
+		},
+	}
+	// shared by commands reading remote secrets
+	versionFlag := cli.IntFlag{
+		Name:  "version",
+		Usage: "Version of KV version 2 secrets to read (default: latest)",
+	}
+
 	app.Commands = []cli.Command{
 		{
 			Name:  "push-all",
 			Usage: "push all consul and vault settings",
+			Flags: aclFlags,
 			Action: func(c *cli.Context) error {
 				return allCommand.PushAll(c)
 			},
 		},
+		{
+			Name:  "plan",
+			Usage: "Show the changes push-all would make to consul and vault",
+			Action: func(c *cli.Context) error {
+				return allCommand.Plan(c)
+			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "detailed-exitcode",
+					Usage: "Exit with code 2 if the plan contains any changes",
+				},
+				cli.BoolFlag{
+					Name:  "prune",
+					Usage: "Also plan deleting remote resources that do not exist in config, like push --prune",
+				},
+			},
+		},
+		{
+			Name:        "validate",
+			Usage:       "Check the configuration for references to missing resources and other semantic problems",
+			Description: "Rules:\n" + allCommand.ValidationRulesUsage(),
+			Action: func(c *cli.Context) error {
+				return allCommand.Validate(c)
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "format",
+					Value: "text",
+					Usage: "Output format (text, json, sarif)",
+				},
+				cli.StringSliceFlag{
+					Name:  "enable-rule",
+					Usage: "Only run these rules (repeatable, default: all rules)",
+				},
+				cli.StringSliceFlag{
+					Name:  "disable-rule",
+					Usage: "Do not run these rules (repeatable)",
+				},
+			},
+		},
+		{
+			Name:  "test",
+			Usage: "Run the policy_test{} stanza against the local Vault policies",
+			Action: func(c *cli.Context) error {
+				return allCommand.Test(c)
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "junit-file",
+					Usage:  "Write the results as JUnit XML to this file",
+					EnvVar: "JUNIT_FILE",
+				},
+			},
+		},
+		{
+			Name:      "rollback",
+			Usage:     "Restore the remote resources in a snapshot to their state before the push",
+			ArgsUsage: "<snapshot>",
+			Action: func(c *cli.Context) error {
+				return allCommand.Rollback(c)
+			},
+		},
 		{
 			Name:  "profile-use",
 			Usage: "Change your current vault env profile",
@@ -91,6 +259,43 @@ func main() {
 				return profileCommand.EditProfile(c)
 			},
 		},
+		{
+			Name:      "secrets-encrypt",
+			Usage:     "Encrypt plaintext secret values in HCL files in place",
+			ArgsUsage: "<file> [<file>...]",
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "whole-file",
+					Usage: "Encrypt the whole file as an armored message, rather than each secret value",
+				},
+			}, encryptFlags...),
+			Action: func(c *cli.Context) error {
+				return secretsCommand.Encrypt(c)
+			},
+		},
+		{
+			Name:      "secrets-decrypt",
+			Usage:     "Print HCL files with all encrypted values decrypted",
+			ArgsUsage: "<file> [<file>...]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "in-place",
+					Usage: "Write the decrypted content back to the file instead of printing it",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return secretsCommand.Decrypt(c)
+			},
+		},
+		{
+			Name:      "secrets-edit",
+			Usage:     "Decrypt a HCL file, open it in $EDITOR and encrypt it again",
+			ArgsUsage: "<file>",
+			Flags:     encryptFlags,
+			Action: func(c *cli.Context) error {
+				return secretsCommand.Edit(c)
+			},
+		},
 		{
 			Name:  "vault-unseal-keybase",
 			Usage: "Unseal Vault with keybase encrypted unseal tokens",
@@ -141,11 +346,13 @@ func main() {
 					Usage:  "Only show keys, or also expand and show the secret values (highly sensitive!)",
 					EnvVar: "DETAILED",
 				},
+				versionFlag,
 			},
 		},
 		{
 			Name:  "vault-import-secrets",
 			Usage: "Write remote secrets to local disk (legacy)",
+			Flags: []cli.Flag{versionFlag},
 			Action: func(c *cli.Context) error {
 				return vaultCommand.SecretsImport(c)
 			},
@@ -154,7 +361,62 @@ func main() {
 			Name:  "vault-push-all",
 			Usage: "Push all known resources to remote Vault",
 			Action: func(c *cli.Context) error {
-				return vaultCommand.PushAll(c)
+				return vaultCommand.PushAllFromCLI(c)
+			},
+		},
+		{
+			Name:  "vault-pull-all",
+			Usage: "Write remote Vault mounts, auth backends, policies and audit devices of the root namespace to local disk",
+			Action: func(c *cli.Context) error {
+				return vaultCommand.PullAll(c)
+			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "overwrite",
+					Usage: "Overwrite existing files in the config directory",
+				},
+			},
+		},
+		{
+			Name:  "vault-plan",
+			Usage: "Show the changes vault-push-all would make to remote Vault",
+			Action: func(c *cli.Context) error {
+				return vaultCommand.Plan(c)
+			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "detailed-exitcode",
+					Usage: "Exit with code 2 if the plan contains any changes",
+				},
+				cli.BoolFlag{
+					Name:  "prune",
+					Usage: "Also plan deleting remote resources that do not exist in config, like push --prune",
+				},
+			},
+		},
+		{
+			Name:  "vault-policy-check",
+			Usage: "Check if local policies grant a capability on a path, using Vault's path matching rules",
+			Action: func(c *cli.Context) error {
+				return vaultCommand.PolicyCheck(c)
+			},
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "policy",
+					Usage: "Name of a local policy attached to the token, can be repeated",
+				},
+				cli.StringSliceFlag{
+					Name:  "path",
+					Usage: "Vault path to check, can be repeated",
+				},
+				cli.StringSliceFlag{
+					Name:  "capability",
+					Usage: "Capability to check (create, read, update, delete, list or sudo), can be repeated (default: read)",
+				},
+				cli.StringFlag{
+					Name:  "namespace",
+					Usage: "Vault namespace of the policies",
+				},
 			},
 		},
 		{
@@ -173,13 +435,29 @@ func main() {
 		{
 			Name:  "vault-push-policies",
 			Usage: "Write application read-only policies to remote Vault instance",
+			Flags: pruneFlags,
 			Action: func(c *cli.Context) error {
 				return vaultCommand.PoliciesPush(c)
 			},
 		},
+		{
+			Name:  "vault-push-namespaces",
+			Usage: "Create missing Vault Enterprise namespaces in remote Vault instance",
+			Action: func(c *cli.Context) error {
+				return vaultCommand.NamespacesPush(c)
+			},
+		},
+		{
+			Name:  "vault-push-identity",
+			Usage: "Write identity entities, groups and group aliases to remote Vault instance",
+			Action: func(c *cli.Context) error {
+				return vaultCommand.IdentityPush(c)
+			},
+		},
 		{
 			Name:  "vault-push-audit",
 			Usage: "Write audit configuration to remote Vault instance",
+			Flags: pruneFlags,
 			Action: func(c *cli.Context) error {
 				return vaultCommand.AuditPush(c)
 			},
@@ -187,6 +465,7 @@ func main() {
 		{
 			Name:  "vault-push-mounts",
 			Usage: "Write vault mounts to remote Vault instance",
+			Flags: pruneFlags,
 			Action: func(c *cli.Context) error {
 				return vaultCommand.MountsPush(c)
 			},
@@ -194,6 +473,7 @@ func main() {
 		{
 			Name:  "vault-push-auth",
 			Usage: "Write vault auth backends to remote Vault instance",
+			Flags: pruneFlags,
 			Action: func(c *cli.Context) error {
 				return vaultCommand.AuthPush(c)
 			},
@@ -264,8 +544,37 @@ func main() {
 		{
 			Name:  "consul-push-all",
 			Usage: "Push all known consul configs to remote Consul cluster",
+			Flags: aclFlags,
+			Action: func(c *cli.Context) error {
+				return consulCommand.PushAllFromCLI(c)
+			},
+		},
+		{
+			Name:  "consul-plan",
+			Usage: "Show the changes consul-push-all would make to remote Consul cluster",
+			Action: func(c *cli.Context) error {
+				return consulCommand.Plan(c)
+			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "detailed-exitcode",
+					Usage: "Exit with code 2 if the plan contains any changes",
+				},
+			},
+		},
+		{
+			Name:  "consul-push-acl",
+			Usage: "Push all known consul ACL policies, roles, tokens and binding rules to remote Consul cluster",
+			Flags: aclFlags,
+			Action: func(c *cli.Context) error {
+				return consulCommand.ACLPush(c)
+			},
+		},
+		{
+			Name:  "consul-push-config-entries",
+			Usage: "Push all known consul config entries and intentions to remote Consul cluster",
 			Action: func(c *cli.Context) error {
-				return consulCommand.PushAll(c)
+				return consulCommand.ConfigEntriesPush(c)
 			},
 		},
 		{
@@ -282,7 +591,72 @@ func main() {
 				return consulCommand.KVPush(c)
 			},
 		},
+		{
+			Name:  "consul-pull-kv",
+			Usage: "Write remote Consul KV to local disk",
+			Action: func(c *cli.Context) error {
+				return consulCommand.KVPull(c)
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "prefix",
+					Usage: "Only pull keys below this prefix (relative to the application with --application)",
+				},
+				cli.BoolFlag{
+					Name:  "applications",
+					Usage: "Use the first path segment of each key as application name",
+				},
+				cli.BoolFlag{
+					Name:  "overwrite",
+					Usage: "Overwrite existing files in the config directory",
+				},
+			},
+		},
+		{
+			Name:  "consul-pull-services",
+			Usage: "Write remote Consul services registered on external nodes to local disk",
+			Action: func(c *cli.Context) error {
+				return consulCommand.ServicesPull(c)
+			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "overwrite",
+					Usage: "Overwrite existing files in the config directory",
+				},
+			},
+		},
+		{
+			Name:  "nomad-push-all",
+			Usage: "Push all known Nomad quotas, namespaces and ACL policies to remote Nomad cluster",
+			Action: func(c *cli.Context) error {
+				return nomadCommand.PushAllFromCLI(c)
+			},
+		},
+		{
+			Name:  "nomad-push-quotas",
+			Usage: "Push all known Nomad quotas to remote Nomad cluster (Nomad Enterprise)",
+			Action: func(c *cli.Context) error {
+				return nomadCommand.QuotasPush(c)
+			},
+		},
+		{
+			Name:  "nomad-push-namespaces",
+			Usage: "Push all known Nomad namespaces to remote Nomad cluster",
+			Action: func(c *cli.Context) error {
+				return nomadCommand.NamespacesPush(c)
+			},
+		},
+		{
+			Name:  "nomad-push-acl-policies",
+			Usage: "Push all known Nomad ACL policies to remote Nomad cluster",
+			Action: func(c *cli.Context) error {
+				return nomadCommand.ACLPoliciesPush(c)
+			},
+		},
 	}
+	// recorder collects the state of everything the command modifies, it's set up once the flags are parsed
+	var recorder *snapshot.Recorder
+
 	app.Before = func(c *cli.Context) error {
 		// convert the human passed log level into logrus levels
 		level, err := log.ParseLevel(c.String("log-level"))
@@ -291,11 +665,31 @@ func main() {
 		}
 		log.SetLevel(level)
 
-		return nil
+		if c.GlobalBool("atomic") && c.GlobalBool("keep-going") {
+			return fmt.Errorf("--atomic and --keep-going can't be used together")
+		}
+
+		recorder = snapshot.Configure(c)
+		return report.Configure(c)
 	}
 
 	sort.Sort(cli.FlagsByName(app.Flags))
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+	err := app.Run(os.Args)
+	if snapshotErr := recorder.Finish(err != nil || report.ExitCode(0) != 0); snapshotErr != nil {
+		log.Error(snapshotErr)
+	}
+
+	if reportErr := report.Finish(err); reportErr != nil {
+		log.Error(reportErr)
+	}
+
+	if err != nil {
+		log.Error(err)
+		os.Exit(report.ExitCode(1))
+	}
+
+	// some resources failed without failing the command
+	if code := report.ExitCode(0); code != 0 {
+		os.Exit(code)
 	}
 }
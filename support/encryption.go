@@ -0,0 +1,304 @@
+package support
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"golang.org/x/crypto/openpgp"
+	pgparmor "golang.org/x/crypto/openpgp/armor"
+)
+
+const pgpMessageHeader = "-----BEGIN PGP MESSAGE-----"
+
+// encryptedValuePattern matches an ENC[<type>,<base64 ciphertext>] value
+var encryptedValuePattern = regexp.MustCompile(`^ENC\[(age|pgp),([A-Za-z0-9+/=]+)\]$`)
+
+// quotedEncryptedValuePattern matches an encrypted value used as a quoted HCL string
+var quotedEncryptedValuePattern = regexp.MustCompile(`"ENC\[(age|pgp),([A-Za-z0-9+/=]+)\]"`)
+
+// IsEncryptedValue returns true if value is an ENC[age,...] or ENC[pgp,...] value
+func IsEncryptedValue(value string) bool {
+	return encryptedValuePattern.MatchString(value)
+}
+
+// IsEncryptedFile returns true if the whole content is an armored age or OpenPGP message
+func IsEncryptedFile(content string) bool {
+	content = strings.TrimSpace(content)
+	return strings.HasPrefix(content, agearmor.Header) || strings.HasPrefix(content, pgpMessageHeader)
+}
+
+// Decrypter decrypts encrypted values and files, keys are only loaded once something needs decrypting
+type Decrypter struct {
+	AgeIdentityFile  string
+	PGPSecretKeyring string
+	PGPPassphrase    string
+
+	ageIdentities []age.Identity
+	pgpKeyring    openpgp.EntityList
+}
+
+// DecryptFile decrypts content if it's an armored age or OpenPGP message, otherwise it's returned as-is
+func (d *Decrypter) DecryptFile(content string) (string, error) {
+	if !IsEncryptedFile(content) {
+		return content, nil
+	}
+
+	trimmed := strings.TrimSpace(content)
+	if strings.HasPrefix(trimmed, agearmor.Header) {
+		return d.decryptAge(agearmor.NewReader(strings.NewReader(trimmed)))
+	}
+
+	block, err := pgparmor.Decode(strings.NewReader(trimmed))
+	if err != nil {
+		return "", err
+	}
+
+	return d.decryptPGP(block.Body)
+}
+
+// DecryptValues replaces all quoted ENC[...] strings in HCL content with the plaintext, quoted for HCL
+func (d *Decrypter) DecryptValues(content string) (string, error) {
+	var result error
+
+	content = quotedEncryptedValuePattern.ReplaceAllStringFunc(content, func(quoted string) string {
+		plaintext, err := d.Decrypt(strings.Trim(quoted, `"`))
+		if err != nil {
+			if result == nil {
+				result = err
+			}
+			return quoted
+		}
+
+		return quoteHCLString(plaintext)
+	})
+
+	return content, result
+}
+
+// Decrypt returns the plaintext of an ENC[...] value
+func (d *Decrypter) Decrypt(value string) (string, error) {
+	match := encryptedValuePattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", fmt.Errorf("Not an encrypted value, expected ENC[age,...] or ENC[pgp,...]")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(match[2])
+	if err != nil {
+		return "", fmt.Errorf("Invalid base64 in encrypted value: %s", err)
+	}
+
+	if match[1] == "age" {
+		return d.decryptAge(bytes.NewReader(ciphertext))
+	}
+
+	return d.decryptPGP(bytes.NewReader(ciphertext))
+}
+
+func (d *Decrypter) decryptAge(ciphertext io.Reader) (string, error) {
+	if d.ageIdentities == nil {
+		if d.AgeIdentityFile == "" {
+			return "", fmt.Errorf("Decrypting age values requires an identity file (--age-identity-file or ENV[AGE_IDENTITY_FILE])")
+		}
+
+		f, err := os.Open(d.AgeIdentityFile)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		identities, err := age.ParseIdentities(f)
+		if err != nil {
+			return "", fmt.Errorf("Could not read age identities from %s: %s", d.AgeIdentityFile, err)
+		}
+		d.ageIdentities = identities
+	}
+
+	r, err := age.Decrypt(ciphertext, d.ageIdentities...)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+func (d *Decrypter) decryptPGP(ciphertext io.Reader) (string, error) {
+	if d.pgpKeyring == nil {
+		if d.PGPSecretKeyring == "" {
+			return "", fmt.Errorf("Decrypting pgp values requires a secret keyring (--pgp-secret-keyring or ENV[PGP_SECRET_KEYRING])")
+		}
+
+		keyring, err := readPGPKeyring(d.PGPSecretKeyring)
+		if err != nil {
+			return "", err
+		}
+		d.pgpKeyring = keyring
+	}
+
+	// openpgp calls prompt until the private key is decrypted, so only try the passphrase once
+	prompted := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if prompted || symmetric || d.PGPPassphrase == "" {
+			return nil, errors.New("Could not decrypt the pgp private key, is ENV[PGP_PASSPHRASE] correct?")
+		}
+		prompted = true
+
+		for _, key := range keys {
+			if key.PrivateKey != nil && key.PrivateKey.Encrypted {
+				if err := key.PrivateKey.Decrypt([]byte(d.PGPPassphrase)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return nil, nil
+	}
+
+	md, err := openpgp.ReadMessage(ciphertext, d.pgpKeyring, prompt, nil)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// Encrypter encrypts values and files for age recipients or, if there are none, the keys in an OpenPGP keyring
+type Encrypter struct {
+	AgeRecipients    []string
+	PGPPublicKeyring string
+}
+
+// Encrypt returns plaintext as an ENC[...] value
+func (e *Encrypter) Encrypt(plaintext string) (string, error) {
+	var buf bytes.Buffer
+
+	kind, err := e.encrypt(&buf, plaintext, false)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("ENC[%s,%s]", kind, base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// EncryptFile returns content as an armored age or OpenPGP message
+func (e *Encrypter) EncryptFile(content string) (string, error) {
+	var buf bytes.Buffer
+
+	if _, err := e.encrypt(&buf, content, true); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func (e *Encrypter) encrypt(dst io.Writer, plaintext string, armored bool) (string, error) {
+	if len(e.AgeRecipients) > 0 {
+		recipients := make([]age.Recipient, 0, len(e.AgeRecipients))
+		for _, r := range e.AgeRecipients {
+			recipient, err := age.ParseX25519Recipient(r)
+			if err != nil {
+				return "", fmt.Errorf("Invalid age recipient %s: %s", r, err)
+			}
+			recipients = append(recipients, recipient)
+		}
+
+		var armor io.WriteCloser
+		if armored {
+			armor = agearmor.NewWriter(dst)
+			dst = armor
+		}
+
+		w, err := age.Encrypt(dst, recipients...)
+		if err != nil {
+			return "", err
+		}
+
+		if err := writeAndClose(w, plaintext, armor); err != nil {
+			return "", err
+		}
+
+		return "age", nil
+	}
+
+	if e.PGPPublicKeyring != "" {
+		keyring, err := readPGPKeyring(e.PGPPublicKeyring)
+		if err != nil {
+			return "", err
+		}
+
+		var armor io.WriteCloser
+		if armored {
+			armor, err = pgparmor.Encode(dst, "PGP MESSAGE", nil)
+			if err != nil {
+				return "", err
+			}
+			dst = armor
+		}
+
+		w, err := openpgp.Encrypt(dst, keyring, nil, nil, nil)
+		if err != nil {
+			return "", err
+		}
+
+		if err := writeAndClose(w, plaintext, armor); err != nil {
+			return "", err
+		}
+
+		return "pgp", nil
+	}
+
+	return "", fmt.Errorf("Encrypting requires at least one --age-recipient or a --pgp-public-keyring")
+}
+
+func writeAndClose(w io.WriteCloser, plaintext string, armor io.WriteCloser) error {
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if armor != nil {
+		return armor.Close()
+	}
+
+	return nil
+}
+
+// readPGPKeyring reads an armored or binary OpenPGP keyring
+func readPGPKeyring(file string) (openpgp.EntityList, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(content)); err == nil {
+		return keyring, nil
+	}
+
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("Could not read pgp keyring %s: %s", file, err)
+	}
+
+	return keyring, nil
+}
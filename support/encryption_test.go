@@ -0,0 +1,103 @@
+package support
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/hashicorp/hcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryption_AgeRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	identityFile := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, ioutil.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600))
+
+	encrypter := &Encrypter{AgeRecipients: []string{identity.Recipient().String()}}
+	decrypter := &Decrypter{AgeIdentityFile: identityFile}
+
+	value, err := encrypter.Encrypt("multi\nline \"value\"")
+	require.NoError(t, err)
+	require.True(t, IsEncryptedValue(value))
+
+	content, err := decrypter.DecryptValues(`value = "` + value + `"`)
+	require.NoError(t, err)
+	require.Equal(t, `value = "multi\nline \"value\""`, content)
+
+	file, err := encrypter.EncryptFile(`environment "test" {}`)
+	require.NoError(t, err)
+	require.True(t, IsEncryptedFile(file))
+
+	plaintext, err := decrypter.DecryptFile(file)
+	require.NoError(t, err)
+	require.Equal(t, `environment "test" {}`, plaintext)
+
+	_, err = (&Decrypter{}).Decrypt(value)
+	require.Error(t, err)
+}
+
+func TestDecryptValues_HCL(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	identityFile := filepath.Join(t.TempDir(), "keys.txt")
+	require.NoError(t, ioutil.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600))
+
+	encrypter := &Encrypter{AgeRecipients: []string{identity.Recipient().String()}}
+	decrypter := &Decrypter{AgeIdentityFile: identityFile}
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{
+			name:      "quotes and backslashes",
+			plaintext: `say "hi" C:\path\x41`,
+		},
+		{
+			name:      "control characters",
+			plaintext: "nul\x00 bell\a escape\x1b del\x7f\r\n\t",
+		},
+		{
+			name:      "not UTF-8",
+			plaintext: "\xff\xfe binary \xc3",
+		},
+		{
+			name:      "multibyte characters",
+			plaintext: "caf\u00e9 \U0001F600 \u2028",
+		},
+		{
+			name:      "interpolation",
+			plaintext: `${var.password}`,
+		},
+		{
+			name:      "unbalanced interpolation",
+			plaintext: `pa$${ss"word`,
+		},
+		{
+			name:      "dollar without brace",
+			plaintext: `$$ and $`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := encrypter.Encrypt(tt.plaintext)
+			require.NoError(t, err)
+
+			content, err := decrypter.DecryptValues(`value = "` + value + `"`)
+			require.NoError(t, err)
+			require.NotContains(t, content, "${")
+
+			var decoded struct {
+				Value string `hcl:"value"`
+			}
+			require.NoError(t, hcl.Decode(&decoded, content))
+			require.Equal(t, tt.plaintext, decoded.Value)
+		})
+	}
+}
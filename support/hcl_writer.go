@@ -0,0 +1,208 @@
+package support
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/hcl/printer"
+)
+
+var hclIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_\-\.]*$`)
+
+// HCLWriter builds HCL documents that can be parsed by the config package again
+type HCLWriter struct {
+	buf bytes.Buffer
+}
+
+// NewHCLWriter ...
+func NewHCLWriter() *HCLWriter {
+	return &HCLWriter{}
+}
+
+// Block opens a new stanza, e.g. Block("mount", "db") will write 'mount "db" {'
+func (w *HCLWriter) Block(name string, labels ...string) {
+	w.buf.WriteString(name)
+	for _, label := range labels {
+		w.buf.WriteString(" " + quoteHCLString(label))
+	}
+	w.buf.WriteString(" {\n")
+}
+
+// End closes the most recently opened stanza
+func (w *HCLWriter) End() {
+	w.buf.WriteString("}\n\n")
+}
+
+// Comment writes a single line comment
+func (w *HCLWriter) Comment(format string, args ...interface{}) {
+	for _, line := range strings.Split(fmt.Sprintf(format, args...), "\n") {
+		w.buf.WriteString("# " + line + "\n")
+	}
+}
+
+// Raw writes content as-is, it must already be valid HCL
+func (w *HCLWriter) Raw(content string) {
+	w.buf.WriteString(content)
+	w.buf.WriteString("\n")
+}
+
+// Attribute writes a single 'key = value' pair, nil values are skipped
+func (w *HCLWriter) Attribute(key string, value interface{}) {
+	if value == nil {
+		return
+	}
+
+	w.buf.WriteString(quoteHCLKey(key))
+	w.buf.WriteString(" = ")
+	w.writeValue(value)
+	w.buf.WriteString("\n")
+}
+
+// Attributes writes all key/value pairs in data, sorted by key
+func (w *HCLWriter) Attributes(data map[string]interface{}) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		w.Attribute(k, data[k])
+	}
+}
+
+// Bytes returns the HCL document, formatted by the HCL printer
+func (w *HCLWriter) Bytes() ([]byte, error) {
+	return printer.Format(w.buf.Bytes())
+}
+
+// WriteFile formats the HCL document and writes it to file, creating any missing directories
+func (w *HCLWriter) WriteFile(file string, overwrite bool) error {
+	if _, err := os.Stat(file); err == nil && !overwrite {
+		return fmt.Errorf("File %s already exist, refusing to overwrite it", file)
+	}
+
+	content, err := w.Bytes()
+	if err != nil {
+		return fmt.Errorf("Could not format HCL for %s: %s", file, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, content, 0644)
+}
+
+func (w *HCLWriter) writeValue(value interface{}) {
+	switch v := value.(type) {
+	case string:
+		w.buf.WriteString(quoteHCLString(v))
+	case bool, int, int64, float64:
+		w.buf.WriteString(fmt.Sprintf("%v", v))
+	case json.Number:
+		w.buf.WriteString(v.String())
+	case []string:
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			items = append(items, item)
+		}
+		w.writeValue(items)
+	case []interface{}:
+		w.buf.WriteString("[")
+		for i, item := range v {
+			if i > 0 {
+				w.buf.WriteString(", ")
+			}
+			w.writeValue(item)
+		}
+		w.buf.WriteString("]")
+	case map[string]string:
+		items := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			items[k] = item
+		}
+		w.writeValue(items)
+	case map[string]interface{}:
+		w.buf.WriteString("{\n")
+		w.Attributes(v)
+		w.buf.WriteString("}")
+	default:
+		w.buf.WriteString(quoteHCLString(fmt.Sprintf("%v", v)))
+	}
+}
+
+// IsEmptyValue returns true for values that carry no information and can be left
+// out of a generated HCL file (nil, empty strings, lists and maps)
+func IsEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case []string:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	case map[string]string:
+		return len(v) == 0
+	}
+
+	return false
+}
+
+func quoteHCLKey(key string) string {
+	if hclIdentifier.MatchString(key) {
+		return key
+	}
+
+	return quoteHCLString(key)
+}
+
+// quoteHCLString quotes s with only the escapes the HCL1 lexer accepts. Control characters and bytes that
+// aren't valid UTF-8 are written as \x escapes, which HCL unquotes to the raw byte, and the $ of a ${ is
+// escaped too, as HCL copies everything within ${} without unquoting it
+func quoteHCLString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, s[i])
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '$' && strings.HasPrefix(s[i+size:], "{"):
+			b.WriteString(`\x24`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteString(s[i : i+size])
+		}
+
+		i += size
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
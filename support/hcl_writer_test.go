@@ -0,0 +1,40 @@
+package support
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHCLWriter_RoundTrip(t *testing.T) {
+	w := NewHCLWriter()
+	w.Block("environment", "test")
+	w.Block("mount", "db")
+	w.Attributes(map[string]interface{}{
+		"type":                "database",
+		"escaped":             "quote \" backslash \\ tab \t",
+		"creation_statements": "CREATE USER '{{name}}';\nGRANT ALL;",
+		"allowed_roles":       []interface{}{"a", "b"},
+		"verify_connection":   true,
+		"ttl":                 3600,
+		"key/with/slash":      "value",
+	})
+	w.End()
+	w.End()
+
+	content, err := w.Bytes()
+	require.NoError(t, err)
+
+	var out map[string]interface{}
+	require.NoError(t, hcl.Decode(&out, string(content)))
+
+	mount := out["environment"].([]map[string]interface{})[0]["test"].([]map[string]interface{})[0]["mount"].([]map[string]interface{})[0]["db"].([]map[string]interface{})[0]
+	require.Equal(t, "database", mount["type"])
+	require.Equal(t, "quote \" backslash \\ tab \t", mount["escaped"])
+	require.Equal(t, "CREATE USER '{{name}}';\nGRANT ALL;", mount["creation_statements"])
+	require.Equal(t, []interface{}{"a", "b"}, mount["allowed_roles"])
+	require.Equal(t, true, mount["verify_connection"])
+	require.Equal(t, 3600, mount["ttl"])
+	require.Equal(t, "value", mount["key/with/slash"])
+}
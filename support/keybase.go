@@ -0,0 +1,30 @@
+package support
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// KeybaseEncrypt encrypts message for the keybase users in recipients, using the keybase CLI
+func KeybaseEncrypt(recipients []string, message string) (string, error) {
+	args := make([]string, 0)
+	args = append(args, "encrypt")
+	args = append(args, recipients...)
+
+	cmd := exec.Command("keybase", args...)
+	cmd.Stdin = strings.NewReader(message)
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("Failed to run keybase encrypt: %s - %s", err, stderr.String())
+	}
+
+	return stdout.String(), nil
+}
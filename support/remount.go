@@ -0,0 +1,60 @@
+package support
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// RemountPollInterval is how often the status of a remount is checked
+var RemountPollInterval = time.Second
+
+// Remount moves the Vault mount at from to to, auth backends are prefixed with "auth/". Since Vault 1.10
+// the data is moved in the background, and the migration status is polled until it finished
+func Remount(ctx context.Context, client *api.Client, from, to string) error {
+	secret, err := client.Logical().Write("sys/remount", map[string]interface{}{"from": from, "to": to})
+	if err != nil {
+		return err
+	}
+
+	// older Vault versions move the mount before responding, and don't return a migration
+	if secret == nil || secret.Data == nil {
+		return nil
+	}
+
+	id, _ := secret.Data["migration_id"].(string)
+	if id == "" {
+		return nil
+	}
+
+	ticker := time.NewTicker(RemountPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := client.Logical().Read("sys/remount/status/" + id)
+		if err != nil {
+			return err
+		}
+
+		if status != nil && status.Data != nil {
+			info, _ := status.Data["migration_info"].(map[string]interface{})
+			switch info["status"] {
+			case "success":
+				return nil
+			case "failure":
+				return fmt.Errorf("Moving %s to %s failed, see the Vault server logs for migration %s", from, to, id)
+			}
+		}
+
+		log.Debugf("  Waiting for %s to be moved to %s (migration %s)", from, to, id)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package support
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemount(t *testing.T) {
+	RemountPollInterval = time.Millisecond
+
+	tests := []struct {
+		name      string
+		migration bool
+		statuses  []string
+		expectErr string
+		polls     int
+	}{
+		{
+			name: "older Vault moves before responding",
+		},
+		{
+			name:      "polls until the migration succeeded",
+			migration: true,
+			statuses:  []string{"in-progress", "in-progress", "success"},
+			polls:     3,
+		},
+		{
+			name:      "failed migration",
+			migration: true,
+			statuses:  []string{"in-progress", "failure"},
+			expectErr: "Moving old to new failed, see the Vault server logs for migration m-1",
+			polls:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			polls := 0
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/sys/remount":
+					require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+					if !tt.migration {
+						w.WriteHeader(http.StatusNoContent)
+						return
+					}
+
+					json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"migration_id": "m-1"}})
+				case "/v1/sys/remount/status/m-1":
+					status := tt.statuses[polls]
+					polls++
+
+					json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+						"migration_id":   "m-1",
+						"migration_info": map[string]interface{}{"status": status},
+					}})
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			client, err := api.NewClient(&api.Config{Address: server.URL})
+			require.NoError(t, err)
+
+			err = Remount(context.Background(), client, "old", "new")
+			if tt.expectErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tt.expectErr)
+			}
+
+			require.Equal(t, map[string]interface{}{"from": "old", "to": "new"}, body)
+			require.Equal(t, tt.polls, polls)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package support
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RetryTransport retries idempotent requests that failed because the connection was refused or timed out,
+// or with a 429 or 5xx response, waiting twice as long after every attempt
+type RetryTransport struct {
+	// Base sends the requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+
+	// Retries is how often a request is retried before the last error or response is returned
+	Retries int
+
+	// MinBackoff is the wait before the first retry (default: 250ms)
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries, including waits requested with a Retry-After header (default: 10s)
+	MaxBackoff time.Duration
+}
+
+type withoutRetriesKey struct{}
+
+// WithoutRetries marks the requests sent with ctx as not safe to retry, for requests with an idempotent
+// method that still create a new object every time, like a Consul ACL token
+func WithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutRetriesKey{}, true)
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Retries == 0 || !isIdempotent(req) {
+		return t.base().RoundTrip(req)
+	}
+
+	// the body is buffered, so it can be sent again
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if body != nil {
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := t.base().RoundTrip(attemptReq)
+		if attempt >= t.Retries || req.Context().Err() != nil || !IsRetryable(resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+
+		reason := fmt.Sprint(err)
+		if resp != nil {
+			reason = resp.Status
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		log.Warnf("%s %s failed (%s), retrying in %s", req.Method, req.URL.Path, reason, wait)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// isIdempotent returns true if sending req more than once has the same effect as sending it once
+func isIdempotent(req *http.Request) bool {
+	if without, _ := req.Context().Value(withoutRetriesKey{}).(bool); without {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// IsRetryable returns true if a request failed because the connection was refused or timed out, or the
+// server is overloaded or temporarily unavailable. Other errors, like TLS and certificate errors, won't
+// go away by trying again
+func IsRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &netErr) && netErr.Timeout())
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented)
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Base
+}
+
+// backoff returns how long to wait before retrying after attempt, using Retry-After if the server sent it
+func (t *RetryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	min, max := t.MinBackoff, t.MaxBackoff
+	if min == 0 {
+		min = 250 * time.Millisecond
+	}
+	if max == 0 {
+		max = 10 * time.Second
+	}
+
+	wait := max
+	if attempt < 16 && min<<uint(attempt) < max {
+		wait = min << uint(attempt)
+	}
+
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if wait > max {
+		return max
+	}
+
+	return wait
+}
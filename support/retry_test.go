@@ -0,0 +1,166 @@
+package support
+
+import (
+	"context"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		ctx      context.Context
+		statuses []int
+		retries  int
+		expected int
+		attempts int
+	}{
+		{
+			name:     "retries 5xx until success",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			retries:  3,
+			expected: http.StatusOK,
+			attempts: 3,
+		},
+		{
+			name:     "retries 429",
+			statuses: []int{http.StatusTooManyRequests, http.StatusOK},
+			retries:  3,
+			expected: http.StatusOK,
+			attempts: 2,
+		},
+		{
+			name:     "returns last response when out of retries",
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			retries:  2,
+			expected: http.StatusInternalServerError,
+			attempts: 3,
+		},
+		{
+			name:     "does not retry client errors",
+			statuses: []int{http.StatusBadRequest, http.StatusOK},
+			retries:  3,
+			expected: http.StatusBadRequest,
+			attempts: 1,
+		},
+		{
+			name:     "does not retry 501",
+			statuses: []int{http.StatusNotImplemented, http.StatusOK},
+			retries:  3,
+			expected: http.StatusNotImplemented,
+			attempts: 1,
+		},
+		{
+			name:     "does not retry non-idempotent methods",
+			method:   http.MethodPost,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			retries:  3,
+			expected: http.StatusServiceUnavailable,
+			attempts: 1,
+		},
+		{
+			name:     "does not retry requests marked without retries",
+			ctx:      WithoutRetries(context.Background()),
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			retries:  3,
+			expected: http.StatusServiceUnavailable,
+			attempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, `{"policy":"read"}`, string(body))
+
+				w.WriteHeader(tt.statuses[attempts])
+				attempts++
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: &RetryTransport{Retries: tt.retries, MinBackoff: time.Millisecond}}
+
+			method, ctx := tt.method, tt.ctx
+			if method == "" {
+				method = http.MethodPut
+			}
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			req, err := http.NewRequestWithContext(ctx, method, server.URL, strings.NewReader(`{"policy":"read"}`))
+			require.NoError(t, err)
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			require.Equal(t, tt.expected, resp.StatusCode)
+			require.Equal(t, tt.attempts, attempts)
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "connection refused",
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expected: true,
+		},
+		{
+			name:     "timeout",
+			err:      &net.DNSError{Err: "i/o timeout", IsTimeout: true},
+			expected: true,
+		},
+		{
+			name:     "certificate error",
+			err:      x509.UnknownAuthorityError{},
+			expected: false,
+		},
+		{
+			name:     "connection reset",
+			err:      &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, IsRetryable(nil, tt.err))
+		})
+	}
+}
+
+func TestRetryTransport_backoff(t *testing.T) {
+	transport := &RetryTransport{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	require.Equal(t, 100*time.Millisecond, transport.backoff(0, nil))
+	require.Equal(t, 400*time.Millisecond, transport.backoff(2, nil))
+	require.Equal(t, time.Second, transport.backoff(10, nil))
+	require.Equal(t, time.Second, transport.backoff(100, nil))
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"0"}}}
+	require.Equal(t, time.Duration(0), transport.backoff(3, resp))
+
+	resp.Header.Set("Retry-After", "120")
+	require.Equal(t, time.Second, transport.backoff(0, resp))
+}